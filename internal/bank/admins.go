@@ -0,0 +1,135 @@
+package bank
+
+import (
+	"errors"
+
+	"mybot/internal/storage"
+)
+
+// Роли администраторов.
+const (
+	RoleOwner     = "owner"
+	RoleFinance   = "finance"
+	RoleModerator = "moderator"
+	RoleSupport   = "support"
+)
+
+type Permission string
+
+const (
+	PermFinance    Permission = "finance"    // заявки на вывод/пополнение, /deposit, /treasury_fund, уведомления об инвестициях
+	PermBonds      Permission = "bonds"      // /create_bond, /edit_bond, /pause_bond, /resume_bond, /retire_bond, /set_lock
+	PermModerate   Permission = "moderate"   // /ban, /unban
+	PermBroadcast  Permission = "broadcast"  // /broadcast, /schedule, /set_info
+	PermComplaints Permission = "complaints" // жалобы игроков, /tickets, /ticket
+	PermReports    Permission = "reports"    // /all_bonds, /market, /cash_all_file, /ledger, /reconcile, /treasury
+	PermAdmins     Permission = "admins"     // /add_admin, /remove_admin
+	PermSettings   Permission = "settings"   // /setting, /fees, /set_limit
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleOwner:     {PermFinance, PermBonds, PermModerate, PermBroadcast, PermComplaints, PermReports, PermAdmins, PermSettings},
+	RoleFinance:   {PermFinance, PermBonds, PermReports},
+	RoleModerator: {PermModerate, PermBroadcast, PermComplaints},
+	RoleSupport:   {PermComplaints, PermReports},
+}
+
+// ErrBootstrapOwner — владельцев из конфигурации нельзя снять через бота,
+// иначе можно остаться без единого владельца.
+var ErrBootstrapOwner = errors.New("владелец задан в конфигурации")
+
+func IsRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func roleHas(role string, p Permission) bool {
+	for _, rp := range rolePermissions[role] {
+		if rp == p {
+			return true
+		}
+	}
+	return false
+}
+
+// SeedOwners добавляет владельцев из конфигурации в admins.
+func (b *Bank) SeedOwners() error {
+	return b.tx(func(tx storage.Tx) error {
+		for id := range b.owners {
+			if err := tx.AddAdminIfMissing(storage.Admin{ID: id, Role: RoleOwner, AddedBy: "config", AddedAt: b.Now()}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Role возвращает роль администратора или "" для обычного игрока.
+func (b *Bank) Role(id string) string {
+	if b.owners[id] {
+		return RoleOwner
+	}
+	var a storage.Admin
+	_ = b.tx(func(tx storage.Tx) error {
+		var err error
+		a, err = tx.Admin(id)
+		return err
+	})
+	return a.Role
+}
+
+func (b *Bank) Can(id string, p Permission) bool {
+	return roleHas(b.Role(id), p)
+}
+
+func (b *Bank) Admins() ([]storage.Admin, error) {
+	var res []storage.Admin
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		res, err = tx.Admins()
+		return err
+	})
+	for i := range res {
+		if b.owners[res[i].ID] {
+			res[i].Role = RoleOwner
+		}
+	}
+	return res, err
+}
+
+// AdminsWith возвращает ID всех администраторов с правом p.
+func (b *Bank) AdminsWith(p Permission) ([]string, error) {
+	admins, err := b.Admins()
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, a := range admins {
+		if roleHas(a.Role, p) {
+			ids = append(ids, a.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (b *Bank) SetAdmin(id, role, by string) error {
+	if b.owners[id] {
+		return ErrBootstrapOwner
+	}
+	return b.tx(func(tx storage.Tx) error {
+		return tx.SetAdmin(storage.Admin{ID: id, Role: role, AddedBy: by, AddedAt: b.Now()})
+	})
+}
+
+func (b *Bank) RemoveAdmin(id string) (bool, error) {
+	if b.owners[id] {
+		return false, ErrBootstrapOwner
+	}
+	var removed bool
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		removed, err = tx.RemoveAdmin(id)
+		return err
+	})
+	return removed, err
+}
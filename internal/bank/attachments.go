@@ -0,0 +1,92 @@
+package bank
+
+import (
+	"errors"
+	"time"
+
+	"mybot/internal/storage"
+)
+
+// ErrNoEvidenceTarget — у игрока нет ни ожидающей заявки на пополнение, ни
+// открытого обращения, к которым можно приложить файл.
+var ErrNoEvidenceTarget = errors.New("нет заявки на пополнение или открытой жалобы, к которой можно приложить файл")
+
+// Evidence — приложенный файл и заявка или обращение, к которому он
+// относится; заполнено одно из Request и Ticket в зависимости от OwnerKind.
+type Evidence struct {
+	storage.Attachment
+	Request storage.MoneyRequest
+	Ticket  storage.Complaint
+}
+
+// AttachEvidence прикладывает файл игрока к его последней ожидающей заявке
+// на пополнение или последнему открытому обращению — к тому, что создано
+// позже.
+func (b *Bank) AttachEvidence(uid, fileID, fileType string) (Evidence, error) {
+	e := Evidence{Attachment: storage.Attachment{UserID: uid, FileID: fileID, FileType: fileType, CreatedAt: b.Now()}}
+	err := b.tx(func(tx storage.Tx) error {
+		requests, err := tx.PendingRequests(uid)
+		if err != nil {
+			return err
+		}
+		for _, r := range requests {
+			if r.Kind == storage.RequestDeposit && r.CreatedAt.After(e.Request.CreatedAt) {
+				e.Request = r
+			}
+		}
+		tickets, err := tx.Complaints(storage.ComplaintFilter{UserID: uid, Statuses: []string{storage.TicketOpen, storage.TicketInProgress}, Limit: 1})
+		if err != nil {
+			return err
+		}
+		switch {
+		case len(tickets) > 0 && !tickets[0].CreatedAt.Before(e.Request.CreatedAt):
+			e.Ticket = tickets[0]
+			e.OwnerKind, e.OwnerID = storage.AttachTicket, e.Ticket.ID
+		case e.Request.ID != 0:
+			e.OwnerKind, e.OwnerID = storage.AttachRequest, e.Request.ID
+		default:
+			return ErrNoEvidenceTarget
+		}
+		return tx.AddAttachment(&e.Attachment)
+	})
+	return e, err
+}
+
+// Attachments — файлы, приложенные к заявке (storage.AttachRequest) или
+// обращению (storage.AttachTicket).
+func (b *Bank) Attachments(ownerKind string, ownerID int64) ([]storage.Attachment, error) {
+	var res []storage.Attachment
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		res, err = tx.Attachments(ownerKind, ownerID)
+		return err
+	})
+	return res, err
+}
+
+// EvidenceWindow — сколько заявка на пополнение ждёт скриншот; 0 — без срока.
+func (b *Bank) EvidenceWindow() (time.Duration, error) {
+	var hours int
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		hours, err = settingInt(tx, SettingEvidenceHours)
+		return err
+	})
+	return time.Duration(hours) * time.Hour, err
+}
+
+// ExpireUnverified закрывает заявки на пополнение, к которым за
+// SettingEvidenceHours не приложили ни одного файла.
+func (b *Bank) ExpireUnverified() ([]storage.MoneyRequest, error) {
+	var res []storage.MoneyRequest
+	err := b.tx(func(tx storage.Tx) error {
+		hours, err := settingInt(tx, SettingEvidenceHours)
+		if err != nil || hours == 0 {
+			return err
+		}
+		now := b.Now()
+		res, err = tx.ExpireWithoutEvidence(storage.RequestDeposit, now.Add(-time.Duration(hours)*time.Hour), now)
+		return err
+	})
+	return res, err
+}
@@ -0,0 +1,373 @@
+// Package bank — бизнес-логика банка: переводы, вклады, заявки, роли
+// администраторов. Состояние хранится в storage.Store, Telegram и HTTP
+// сюда не проникают.
+package bank
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+var (
+	// ErrInsufficientFunds — проводка увела бы баланс игрока в минус.
+	ErrInsufficientFunds = errors.New("недостаточно средств")
+	ErrBondLocked        = errors.New("вклад заморожен")
+	// ErrBelowMinimum — сумма покупки меньше минимальной цены облигации.
+	ErrBelowMinimum = errors.New("сумма меньше минимальной")
+)
+
+type Bank struct {
+	store  storage.Store
+	owners map[string]bool
+
+	// Now — источник времени; тесты подменяют его, чтобы проверять начисление процентов.
+	Now func() time.Time
+}
+
+// New создаёт банк поверх store. owners — владельцы из конфигурации
+// (AdminID/AdminID2): они всегда owner и не снимаются через бота.
+func New(store storage.Store, owners ...string) *Bank {
+	b := &Bank{store: store, owners: map[string]bool{}, Now: time.Now}
+	for _, id := range owners {
+		b.owners[id] = true
+	}
+	return b
+}
+
+func (b *Bank) tx(fn func(tx storage.Tx) error) error {
+	return b.store.Tx(context.Background(), fn)
+}
+
+// post записывает транзакцию в ledger и применяет её проводки к балансам
+// игроков в той же транзакции хранилища. Перед изменением строки балансов
+// блокируются; если списание уводит баланс в минус, возвращается
+// ErrInsufficientFunds и вызывающий должен откатить транзакцию.
+func (b *Bank) post(tx storage.Tx, t storage.Transaction) (int64, error) {
+	var sum money.Money
+	accounts := make([]string, 0, len(t.Entries))
+	for _, e := range t.Entries {
+		sum += e.Amount
+		accounts = append(accounts, e.Account)
+	}
+	if sum != 0 {
+		return 0, fmt.Errorf("несбалансированная транзакция %s: сумма проводок %s", t.Kind, sum)
+	}
+
+	balances, err := tx.LockBalances(accounts...)
+	if err != nil {
+		return 0, err
+	}
+	for i, e := range t.Entries {
+		if storage.IsSystemAccount(e.Account) {
+			continue
+		}
+		if e.Amount < 0 && balances[e.Account]+e.Amount < 0 {
+			return 0, ErrInsufficientFunds
+		}
+		balances[e.Account] += e.Amount
+		after := balances[e.Account]
+		t.Entries[i].BalanceAfter = &after
+	}
+
+	t.CreatedAt = b.Now()
+	if err := tx.InsertTransaction(&t); err != nil {
+		return 0, err
+	}
+	for _, e := range t.Entries {
+		if storage.IsSystemAccount(e.Account) {
+			continue
+		}
+		if err := tx.AddBalance(e.Account, e.Amount); err != nil {
+			return 0, err
+		}
+	}
+	return t.ID, nil
+}
+
+// withFee дополняет проводки списанием комиссии fee с payer в казну.
+func withFee(entries []storage.Entry, payer string, fee money.Money) []storage.Entry {
+	if fee == 0 {
+		return entries
+	}
+	return append(entries, transferEntries(payer, storage.AccountTreasury, fee)...)
+}
+
+// transferEntries — проводки перемещения amount со счёта from на счёт to.
+func transferEntries(from, to string, amount money.Money) []storage.Entry {
+	return []storage.Entry{{Account: from, Amount: -amount}, {Account: to, Amount: amount}}
+}
+
+func (b *Bank) Balance(uid string) (money.Money, error) {
+	var a money.Money
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		a, err = tx.Balance(uid)
+		return err
+	})
+	return a, err
+}
+
+func (b *Bank) User(uid string) (storage.User, error) {
+	var u storage.User
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		u, err = tx.User(uid)
+		return err
+	})
+	return u, err
+}
+
+func (b *Bank) IsBanned(uid string) bool {
+	u, _ := b.User(uid)
+	return u.Banned
+}
+
+// Register заводит игрока вместе со счётом или меняет ник уже
+// зарегистрированного; занятый другим игроком ник отклоняется. Роль выбирается один раз из SettingPlayerRoles:
+// по ней подбираются лимиты, поэтому повторная регистрация её не меняет.
+func (b *Bank) Register(uid, nick, role string) error {
+	return b.tx(func(tx storage.Tx) error {
+		if _, err := tx.User(uid); errors.Is(err, storage.ErrNotFound) {
+			roles, err := setting(tx, SettingPlayerRoles)
+			if err != nil {
+				return err
+			}
+			if !parseRoles(roles)[role] {
+				return ErrBadRole
+			}
+		} else if err != nil {
+			return err
+		}
+		// Ник адресует переводы, поэтому у двух игроков он совпадать не может.
+		same, err := tx.UsersByNick(nick)
+		if err != nil {
+			return err
+		}
+		for _, u := range same {
+			if u.ID != uid {
+				return ErrNickTaken
+			}
+		}
+		if err := tx.UpsertUser(storage.User{ID: uid, Nick: nick, Role: role}); err != nil {
+			return err
+		}
+		_, err = tx.LockBalances(uid)
+		return err
+	})
+}
+
+// Users — незаблокированные игроки, отсортированные по нику.
+func (b *Bank) Users() ([]storage.User, error) {
+	var res []storage.User
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		res, err = tx.Users(false)
+		return err
+	})
+	return res, err
+}
+
+func (b *Bank) SetBanned(uid string, banned bool) (bool, error) {
+	var ok bool
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		ok, err = tx.SetBanned(uid, banned)
+		return err
+	})
+	return ok, err
+}
+
+type Transfer struct {
+	// ID — номер перевода в pending_transfers.
+	ID       int64
+	TxID     int64
+	FromID   string
+	FromNick string
+	ToID     string
+	ToNick   string
+	Amount   money.Money
+	// Fee — комиссия, списанная с отправителя сверх суммы перевода.
+	Fee     money.Money
+	Comment string
+	// Review — лимит, из-за которого перевод ушёл на проверку администрации
+	// вместо проведения; nil, если перевод проведён.
+	Review *LimitBreach
+}
+
+// transfer проводит перевод проверенному получателю to.
+func (b *Bank) transfer(tx storage.Tx, from string, to storage.User, amount, fee money.Money, comment string) (Transfer, error) {
+	sender, _ := tx.User(from)
+	res := Transfer{FromID: from, FromNick: sender.Nick, ToID: to.ID, ToNick: to.Nick, Amount: amount, Fee: fee, Comment: comment}
+	memo := fmt.Sprintf("%s → %s", sender.Nick, to.Nick)
+	if comment != "" {
+		memo += ": " + comment
+	}
+	var err error
+	res.TxID, err = b.post(tx, storage.Transaction{
+		Kind:      storage.TxTransfer,
+		Initiator: from,
+		Memo:      memo,
+		Entries:   withFee(transferEntries(from, to.ID, amount), from, fee),
+	})
+	return res, err
+}
+
+// AdminDeposit зачисляет amount из казны на счёт зарегистрированного игрока
+// от имени администратора.
+func (b *Bank) AdminDeposit(adminID, uid string, amount money.Money) error {
+	if err := CheckAmount(amount); err != nil {
+		return err
+	}
+	return b.tx(func(tx storage.Tx) error {
+		if _, err := tx.User(uid); errors.Is(err, storage.ErrNotFound) {
+			return ErrUnknownRecipient
+		} else if err != nil {
+			return err
+		}
+		_, err := b.post(tx, storage.Transaction{
+			Kind:      storage.TxAdminDeposit,
+			Initiator: adminID,
+			Memo:      "Пополнение администратором",
+			Entries:   transferEntries(storage.AccountTreasury, uid, amount),
+		})
+		return err
+	})
+}
+
+func (b *Bank) Balances() ([]storage.AccountBalance, error) {
+	var res []storage.AccountBalance
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		res, err = tx.Balances()
+		return err
+	})
+	return res, err
+}
+
+// Statement возвращает последние limit проводок по счёту и его баланс.
+// У системных счетов нет строки в balances, их баланс — сумма проводок.
+func (b *Bank) Statement(account string, limit int) ([]storage.StatementLine, money.Money, error) {
+	var lines []storage.StatementLine
+	var balance money.Money
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		if lines, err = tx.Statement(account, limit); err != nil {
+			return err
+		}
+		if storage.IsSystemAccount(account) {
+			balance, err = tx.AccountTotal(account)
+			return err
+		}
+		balance, err = tx.Balance(account)
+		return err
+	})
+	return lines, balance, err
+}
+
+type Mismatch struct {
+	UserID  string
+	Balance money.Money
+	Ledger  money.Money
+}
+
+// Reconcile сверяет balances с суммой проводок по каждому счёту игрока.
+func (b *Bank) Reconcile() ([]Mismatch, error) {
+	var res []Mismatch
+	err := b.tx(func(tx storage.Tx) error {
+		totals, err := tx.LedgerTotals()
+		if err != nil {
+			return err
+		}
+		balances, err := tx.Balances()
+		if err != nil {
+			return err
+		}
+		seen := map[string]bool{}
+		for _, bal := range balances {
+			seen[bal.UserID] = true
+			if bal.Amount != totals[bal.UserID] {
+				res = append(res, Mismatch{UserID: bal.UserID, Balance: bal.Amount, Ledger: totals[bal.UserID]})
+			}
+		}
+		for account, total := range totals {
+			if seen[account] {
+				continue
+			}
+			bal, err := tx.Balance(account)
+			if err != nil {
+				return err
+			}
+			if bal != total {
+				res = append(res, Mismatch{UserID: account, Balance: bal, Ledger: total})
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+// InfoLine — информационная строка, которую сейчас показывает WebApp.
+func (b *Bank) InfoLine() (string, error) {
+	var s string
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		s, err = b.infoLine(tx)
+		return err
+	})
+	return s, err
+}
+
+// SetInfoLine меняет постоянную информационную строку; она показывается,
+// когда в расписании нет действующих строк.
+func (b *Bank) SetInfoLine(text string) error {
+	return b.tx(func(tx storage.Tx) error {
+		return tx.SetInfoLine(text)
+	})
+}
+
+// Overview — данные личного кабинета WebApp.
+type Overview struct {
+	Balance     money.Money            `json:"balance"`
+	Info        string                 `json:"info"`
+	Bonds       []BondView             `json:"bonds"`
+	CanComplain bool                   `json:"can_complain"`
+	Requests    []storage.MoneyRequest `json:"requests"`
+	// Limits — ограничения игрока и остаток по ним; пусто, если их нет.
+	Limits []Allowance `json:"limits"`
+}
+
+func (b *Bank) Overview(uid string) (Overview, error) {
+	var o Overview
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		if o.Balance, err = tx.Balance(uid); err != nil {
+			return err
+		}
+		if o.Info, err = b.infoLine(tx); err != nil {
+			return err
+		}
+		bonds, err := tx.Bonds(uid)
+		if err != nil {
+			return err
+		}
+		for _, bond := range bonds {
+			o.Bonds = append(o.Bonds, b.view(bond))
+		}
+		wait, err := b.complaintWait(tx, uid)
+		if err != nil {
+			return err
+		}
+		o.CanComplain = wait <= 0
+		if o.Requests, err = tx.PendingRequests(uid); err != nil {
+			return err
+		}
+		o.Limits, err = b.allowances(tx, uid)
+		return err
+	})
+	return o, err
+}
@@ -0,0 +1,1362 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"mybot/internal/money"
+	"mybot/internal/storage"
+	"mybot/internal/storage/memory"
+)
+
+const owner = "1"
+
+type testClock struct{ t time.Time }
+
+func (c *testClock) Now() time.Time          { return c.t }
+func (c *testClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestBank(t *testing.T) (*Bank, *testClock) {
+	t.Helper()
+	clock := &testClock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	b := New(memory.New(), owner)
+	b.Now = clock.Now
+	for _, u := range []struct{ id, nick string }{{"100", "alice"}, {"200", "bob"}} {
+		if err := b.Register(u.id, u.nick, "player"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b, clock
+}
+
+// fund зачисляет игроку gold из казны, предварительно пополнив её на ту же
+// сумму, чтобы резервы казны в тестах не уходили в минус.
+func fund(t *testing.T, b *Bank, uid string, gold int64) {
+	t.Helper()
+	if err := b.FundTreasury(owner, money.FromInt(gold), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AdminDeposit(owner, uid, money.FromInt(gold)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wantBalance(t *testing.T, b *Bank, uid string, want money.Money) {
+	t.Helper()
+	got, err := b.Balance(uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("balance %s = %s, want %s", uid, got, want)
+	}
+}
+
+func wantReconciled(t *testing.T, b *Bank) {
+	t.Helper()
+	m, err := b.Reconcile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 0 {
+		t.Errorf("reconcile: %+v", m)
+	}
+}
+
+// transferNow готовит перевод и сразу подтверждает его от имени отправителя.
+func transferNow(b *Bank, from, to string, amount money.Money) (Transfer, error) {
+	d, err := b.PrepareTransfer(from, to, amount, "")
+	if err != nil {
+		return Transfer{}, err
+	}
+	return b.ConfirmTransfer(from, d.ID)
+}
+
+func TestTransfer(t *testing.T) {
+	b, _ := newTestBank(t)
+	fund(t, b, "100", 100)
+
+	tr, err := transferNow(b, "100", "200", money.FromInt(30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.FromNick != "alice" || tr.ToNick != "bob" {
+		t.Errorf("nicks = %q → %q", tr.FromNick, tr.ToNick)
+	}
+	wantBalance(t, b, "100", money.FromInt(70))
+	wantBalance(t, b, "200", money.FromInt(30))
+	wantReconciled(t, b)
+}
+
+func TestTransferInsufficientFunds(t *testing.T) {
+	b, _ := newTestBank(t)
+	fund(t, b, "100", 10)
+
+	if _, err := transferNow(b, "100", "200", money.FromInt(11)); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("err = %v, want ErrInsufficientFunds", err)
+	}
+	wantBalance(t, b, "100", money.FromInt(10))
+	wantBalance(t, b, "200", 0)
+
+	lines, _, err := b.Statement("100", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 {
+		t.Errorf("failed transfer left %d ledger lines, want only the deposit", len(lines)-1)
+	}
+}
+
+func TestBondInterest(t *testing.T) {
+	b, clock := newTestBank(t)
+	fund(t, b, "100", 100)
+	p, err := b.CreateProduct(owner, storage.Product{Name: "SE-1", Price: money.FromInt(50), Rate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.BuyBond("100", p.ID, money.FromInt(40)); !errors.Is(err, ErrBelowMinimum) {
+		t.Fatalf("buy below price: err = %v", err)
+	}
+	bond, err := b.BuyBond("100", p.ID, money.FromInt(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBalance(t, b, "100", 0)
+
+	clock.Advance(10*24*time.Hour + time.Hour)
+	bonds, err := b.Bonds("100")
+	if err != nil || len(bonds) != 1 {
+		t.Fatalf("bonds = %v, %v", bonds, err)
+	}
+	if want := money.Money(11046); bonds[0].CurrentValue != want {
+		t.Errorf("value after 10 days = %s, want %s", bonds[0].CurrentValue, want)
+	}
+
+	if _, err := b.SellBond("100", bond.ID); !errors.Is(err, ErrBondLocked) {
+		t.Fatalf("sell locked: err = %v", err)
+	}
+	if ok, err := b.SetBondLock(bond.ID, true); !ok || err != nil {
+		t.Fatalf("unlock = %v, %v", ok, err)
+	}
+	if _, err := b.SellBond("200", bond.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("sell someone else's bond: err = %v", err)
+	}
+	sale, err := b.SellBond("100", bond.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sale.Paid != 11046 || sale.Fee != 0 {
+		t.Errorf("sold for %+v", sale)
+	}
+	wantBalance(t, b, "100", 11046)
+	if _, err := b.SellBond("100", bond.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("second sell: err = %v", err)
+	}
+	wantReconciled(t, b)
+}
+
+func TestBondMaturity(t *testing.T) {
+	b, clock := newTestBank(t)
+	fund(t, b, "100", 100)
+	fund(t, b, "200", 100)
+	term, _ := b.CreateProduct(owner, storage.Product{Name: "SE-10", Price: money.FromInt(10), Rate: 1, TermDays: 10})
+	auto, _ := b.CreateProduct(owner, storage.Product{Name: "SE-10A", Price: money.FromInt(10), Rate: 1, TermDays: 10, AutoRedeem: true})
+
+	held, err := b.BuyBond("100", term.ID, money.FromInt(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.BuyBond("200", auto.ID, money.FromInt(100)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.SellBond("100", held.ID); !errors.Is(err, ErrBondLocked) {
+		t.Fatalf("sell before maturity: err = %v", err)
+	}
+
+	clock.Advance(30 * 24 * time.Hour)
+	bonds, _ := b.Bonds("100")
+	if len(bonds) != 1 || bonds[0].CurrentValue != 11046 || !bonds[0].CanWithdraw {
+		t.Fatalf("matured bond = %+v", bonds)
+	}
+
+	matured, err := b.MatureBonds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matured) != 2 {
+		t.Fatalf("matured = %+v", matured)
+	}
+	for _, m := range matured {
+		if m.Redeemed != (m.Bond.UserID == "200") || m.Value != 11046 {
+			t.Errorf("maturity = %+v", m)
+		}
+	}
+	wantBalance(t, b, "200", 11046)
+	if again, _ := b.MatureBonds(); len(again) != 0 {
+		t.Errorf("second run = %+v", again)
+	}
+
+	if _, err := b.SellBond("100", held.ID); err != nil {
+		t.Fatal(err)
+	}
+	wantBalance(t, b, "100", 11046)
+	wantReconciled(t, b)
+}
+
+func TestInterestModels(t *testing.T) {
+	cases := []struct {
+		model string
+		want  money.Money
+	}{
+		{storage.InterestDaily, 13478},   // 100 · 1.01^30
+		{storage.InterestSimple, 13000},  // 100 + 30 · 1%
+		{storage.InterestMonthly, 10100}, // один полный период
+		{storage.InterestCoupon, 10100},  // один невыплаченный купон
+	}
+	for _, c := range cases {
+		b, clock := newTestBank(t)
+		fund(t, b, "100", 100)
+		p, err := b.CreateProduct(owner, storage.Product{Name: c.model, Price: money.FromInt(1), Rate: 1, InterestModel: c.model})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.BuyBond("100", p.ID, money.FromInt(100)); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(30*24*time.Hour + time.Hour)
+		bonds, _ := b.Bonds("100")
+		if bonds[0].CurrentValue != c.want {
+			t.Errorf("%s: value = %s, want %s", c.model, bonds[0].CurrentValue, c.want)
+		}
+	}
+
+	b, _ := newTestBank(t)
+	if _, err := b.CreateProduct(owner, storage.Product{Name: "X", InterestModel: "weekly"}); !errors.Is(err, ErrBadModel) {
+		t.Errorf("unknown model: err = %v", err)
+	}
+	if _, err := b.CreateProduct(owner, storage.Product{Name: "X", EarlyPolicy: storage.EarlyPenalty, PenaltyPct: 150}); !errors.Is(err, ErrBadEarlyPolicy) {
+		t.Errorf("penalty > 100%%: err = %v", err)
+	}
+}
+
+func TestPayCoupons(t *testing.T) {
+	b, clock := newTestBank(t)
+	fund(t, b, "100", 100)
+	p, _ := b.CreateProduct(owner, storage.Product{Name: "CPN", Price: money.FromInt(1), Rate: 2, TermDays: 90, InterestModel: storage.InterestCoupon})
+	bond, err := b.BuyBond("100", p.ID, money.FromInt(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(61 * 24 * time.Hour)
+	paid, err := b.PayCoupons()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paid) != 1 || paid[0].Count != 2 || paid[0].Amount != money.FromInt(4) {
+		t.Fatalf("paid = %+v", paid)
+	}
+	wantBalance(t, b, "100", money.FromInt(4))
+	if again, _ := b.PayCoupons(); len(again) != 0 {
+		t.Errorf("second run = %+v", again)
+	}
+
+	bonds, _ := b.Bonds("100")
+	if v := bonds[0]; v.CurrentValue != money.FromInt(100) || v.AtMaturity != money.FromInt(102) {
+		t.Errorf("after coupons: value %s, at maturity %s", v.CurrentValue, v.AtMaturity)
+	}
+
+	clock.Advance(60 * 24 * time.Hour)
+	if _, err := b.SellBond("100", bond.ID); err != nil {
+		t.Fatal(err)
+	}
+	wantBalance(t, b, "100", money.FromInt(106))
+	wantReconciled(t, b)
+}
+
+func TestEarlyRedemption(t *testing.T) {
+	cases := []struct {
+		policy string
+		pct    float64
+		want   money.Money
+		err    error
+	}{
+		{storage.EarlyForbidden, 0, 0, ErrBondLocked},
+		{storage.EarlyForfeit, 0, money.FromInt(100), nil},
+		{storage.EarlyPenalty, 10, 9941, nil}, // 110.46 − 11.05
+	}
+	for _, c := range cases {
+		b, clock := newTestBank(t)
+		fund(t, b, "100", 100)
+		p, err := b.CreateProduct(owner, storage.Product{Name: c.policy, Price: money.FromInt(1), Rate: 1, TermDays: 30, EarlyPolicy: c.policy, PenaltyPct: c.pct})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bond, _ := b.BuyBond("100", p.ID, money.FromInt(100))
+		clock.Advance(10*24*time.Hour + time.Hour)
+
+		bonds, _ := b.Bonds("100")
+		if v := bonds[0]; v.CanRedeem != (c.err == nil) || c.err == nil && v.RedeemNow != c.want || v.AtMaturity != 13478 {
+			t.Errorf("%s: view = %+v", c.policy, v)
+		}
+		got, err := b.SellBond("100", bond.ID)
+		if !errors.Is(err, c.err) || got.Paid != c.want {
+			t.Errorf("%s: sell = %s, %v; want %s, %v", c.policy, got.Paid, err, c.want, c.err)
+		}
+		wantReconciled(t, b)
+	}
+}
+
+func TestIssuanceCaps(t *testing.T) {
+	b, clock := newTestBank(t)
+	fund(t, b, "100", 100)
+	fund(t, b, "200", 100)
+	p, err := b.CreateProduct(owner, storage.Product{
+		Name: "CAP", Price: money.FromInt(1), Rate: 1,
+		MaxSupply: money.FromInt(100), PerUserMax: money.FromInt(60),
+		OpensAt: clock.Now().Add(time.Hour), ClosesAt: clock.Now().Add(48 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.BuyBond("100", p.ID, money.FromInt(10)); !errors.Is(err, ErrSubscriptionClosed) {
+		t.Fatalf("before window: err = %v", err)
+	}
+	clock.Advance(2 * time.Hour)
+	if _, err := b.BuyBond("100", p.ID, money.FromInt(50)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.BuyBond("100", p.ID, money.FromInt(20)); !errors.Is(err, ErrHoldingLimit) {
+		t.Fatalf("over per-user limit: err = %v", err)
+	}
+	if _, err := b.BuyBond("200", p.ID, money.FromInt(60)); !errors.Is(err, ErrSupplyExhausted) {
+		t.Fatalf("over supply: err = %v", err)
+	}
+	if _, err := b.BuyBond("200", p.ID, money.FromInt(50)); err != nil {
+		t.Fatal(err)
+	}
+
+	market, err := b.Market("100")
+	if err != nil || len(market) != 1 {
+		t.Fatalf("market = %+v, %v", market, err)
+	}
+	if m := market[0]; *m.Remaining != 0 || *m.UserRemaining != money.FromInt(10) || !m.Open {
+		t.Errorf("market item = remaining %s, user %s, open %v", *m.Remaining, *m.UserRemaining, m.Open)
+	}
+	wantBalance(t, b, "100", money.FromInt(50))
+	wantBalance(t, b, "200", money.FromInt(50))
+
+	clock.Advance(48 * time.Hour)
+	if market, _ := b.Market("100"); market[0].Open {
+		t.Error("subscription still open after closes_at")
+	}
+}
+
+func TestProductLifecycle(t *testing.T) {
+	b, _ := newTestBank(t)
+	fund(t, b, "100", 100)
+	p, _ := b.CreateProduct(owner, storage.Product{Name: "SE-1", Price: money.FromInt(10), Rate: 1})
+	bond, err := b.BuyBond("100", p.ID, money.FromInt(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edits, err := b.EditProduct("2", p.ID, func(p *storage.Product) error {
+		p.Rate = 2
+		return nil
+	})
+	if err != nil || len(edits) != 1 || edits[0] != (ProductEdit{"rate", "1", "2"}) {
+		t.Fatalf("edit = %+v, %v", edits, err)
+	}
+	if bonds, _ := b.Bonds("100"); bonds[0].Rate != 1 {
+		t.Errorf("holding rate changed to %v", bonds[0].Rate)
+	}
+
+	if _, err := b.SetProductStatus(owner, p.ID, storage.ProductPaused); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.BuyBond("100", p.ID, money.FromInt(10)); !errors.Is(err, ErrNotOnSale) {
+		t.Fatalf("buy paused: err = %v", err)
+	}
+	if market, _ := b.Market("100"); len(market) != 1 || market[0].Open {
+		t.Errorf("paused market = %+v", market)
+	}
+
+	if _, err := b.SetProductStatus(owner, p.ID, storage.ProductRetired); err != nil {
+		t.Fatal(err)
+	}
+	if market, _ := b.Market("100"); len(market) != 0 {
+		t.Errorf("retired product still on market: %+v", market)
+	}
+	if _, err := b.SetProductStatus(owner, p.ID, storage.ProductActive); !errors.Is(err, ErrProductRetired) {
+		t.Errorf("resume retired: err = %v", err)
+	}
+	if all, _ := b.AllProducts(); len(all) != 1 {
+		t.Errorf("all products = %+v", all)
+	}
+
+	changes, err := b.ProductChanges(p.ID, 10)
+	if err != nil || len(changes) != 4 {
+		t.Fatalf("changes = %+v, %v", changes, err)
+	}
+	if c := changes[len(changes)-2]; c.AdminID != "2" || c.Field != "rate" {
+		t.Errorf("rate change = %+v", c)
+	}
+
+	if _, err := b.SellBond("100", bond.ID); !errors.Is(err, ErrBondLocked) {
+		t.Errorf("holding lost its lock after retire: err = %v", err)
+	}
+}
+
+func TestBondTrade(t *testing.T) {
+	b, _ := newTestBank(t)
+	fund(t, b, "100", 100)
+	fund(t, b, "200", 100)
+	p, _ := b.CreateProduct(owner, storage.Product{Name: "SE-1", Price: money.FromInt(10), Rate: 1})
+	bond, _ := b.BuyBond("100", p.ID, money.FromInt(50))
+
+	if _, err := b.ListBond("100", bond.ID, money.FromInt(60)); !errors.Is(err, ErrBondLocked) {
+		t.Fatalf("list locked bond: err = %v", err)
+	}
+	if err := b.SetSetting(owner, SettingMarketAllowLocked, "yes"); !errors.Is(err, ErrBadSetting) {
+		t.Fatalf("bad bool: err = %v", err)
+	}
+	if err := b.SetSetting(owner, SettingMarketAllowLocked, "true"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetSetting(owner, SettingMarketFeePct, "5"); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := b.ListBond("100", bond.ID, money.FromInt(60))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.ListBond("100", bond.ID, money.FromInt(70)); !errors.Is(err, ErrAlreadyListed) {
+		t.Errorf("second listing: err = %v", err)
+	}
+	if _, err := b.BuyListing("100", l.ID); !errors.Is(err, ErrOwnListing) {
+		t.Errorf("buy own listing: err = %v", err)
+	}
+	if listings, _ := b.Listings("200"); len(listings) != 1 || listings[0].Own || listings[0].SellerNick != "alice" {
+		t.Errorf("listings = %+v", listings)
+	}
+
+	// Запрет на продажу заблокированных вкладов действует и на уже выставленные.
+	b.SetSetting(owner, SettingMarketAllowLocked, "false")
+	if _, err := b.BuyListing("200", l.ID); !errors.Is(err, ErrBondLocked) {
+		t.Errorf("buy locked bond after the market was closed to them: err = %v", err)
+	}
+	b.SetSetting(owner, SettingMarketAllowLocked, "true")
+
+	tr, err := b.BuyListing("200", l.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Fee != money.FromInt(3) || tr.BuyerNick != "bob" {
+		t.Errorf("trade = %+v", tr)
+	}
+	wantBalance(t, b, "100", money.FromInt(107))
+	wantBalance(t, b, "200", money.FromInt(40))
+	if _, err := b.BuyListing("200", l.ID); !errors.Is(err, storage.ErrListingClosed) {
+		t.Errorf("buy sold listing: err = %v", err)
+	}
+	if bonds, _ := b.Bonds("200"); len(bonds) != 1 || bonds[0].ID != bond.ID {
+		t.Errorf("buyer bonds = %+v", bonds)
+	}
+	if listings, _ := b.Listings("200"); len(listings) != 0 {
+		t.Errorf("listings after sale = %+v", listings)
+	}
+
+	// Выставленный вклад, закрытый владельцем, снимается с продажи.
+	b.SetBondLock(bond.ID, true)
+	if _, err := b.ListBond("200", bond.ID, money.FromInt(80)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.SellBond("200", bond.ID); err != nil {
+		t.Fatal(err)
+	}
+	if listings, _ := b.Listings("100"); len(listings) != 0 {
+		t.Errorf("listing of a closed bond = %+v", listings)
+	}
+	wantReconciled(t, b)
+}
+
+func TestPartialRedemptionAndTopUp(t *testing.T) {
+	b, clock := newTestBank(t)
+	fund(t, b, "100", 200)
+	p, _ := b.CreateProduct(owner, storage.Product{Name: "SE-1", Price: money.FromInt(10), Rate: 1})
+	bond, _ := b.BuyBond("100", p.ID, money.FromInt(100))
+
+	if _, err := b.WithdrawFromBond("100", bond.ID, money.FromInt(5)); !errors.Is(err, ErrBondLocked) {
+		t.Fatalf("withdraw from locked: err = %v", err)
+	}
+	b.SetBondLock(bond.ID, true)
+	clock.Advance(10*24*time.Hour + 12*time.Hour)
+
+	// 110.46 = 100 тела + 10.46 процентов: 20 снимается как 10.46 процентов и 9.54 тела.
+	v, err := b.WithdrawFromBond("100", bond.ID, money.FromInt(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Principal != 9046 || v.Accrued != 0 || v.CurrentValue != 9046 {
+		t.Fatalf("after withdraw: principal %s, accrued %s, value %s", v.Principal, v.Accrued, v.CurrentValue)
+	}
+	if _, err := b.WithdrawFromBond("100", bond.ID, 9046); !errors.Is(err, ErrPartialTooLarge) {
+		t.Errorf("withdraw everything: err = %v", err)
+	}
+
+	// Незавершённые полдня не теряются: через 12 часов начисляется новый день.
+	clock.Advance(12 * time.Hour)
+	if bonds, _ := b.Bonds("100"); bonds[0].CurrentValue != money.Money(9046).Grow(1, 1) {
+		t.Errorf("value after a day = %s", bonds[0].CurrentValue)
+	}
+
+	// Пополнение подчиняется тем же условиям продажи, что и покупка.
+	b.SetProductStatus(owner, p.ID, storage.ProductPaused)
+	if _, err := b.TopUpBond("100", bond.ID, money.FromInt(50)); !errors.Is(err, ErrNotOnSale) {
+		t.Errorf("top-up of a paused bond: err = %v", err)
+	}
+	b.SetProductStatus(owner, p.ID, storage.ProductActive)
+
+	v, err = b.TopUpBond("100", bond.ID, money.FromInt(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := money.Money(9046).Grow(1, 1) + money.FromInt(50); v.CurrentValue != want || v.Principal != 14046 {
+		t.Errorf("after top-up: value %s (want %s), principal %s", v.CurrentValue, want, v.Principal)
+	}
+	clock.Advance(24 * time.Hour)
+	want := (money.Money(9046).Grow(1, 1) + money.FromInt(50)).Grow(1, 1)
+	sale, err := b.SellBond("100", bond.ID)
+	if err != nil || sale.Paid != want {
+		t.Fatalf("sell = %s, %v; want %s", sale.Paid, err, want)
+	}
+	wantBalance(t, b, "100", money.FromInt(200)-money.FromInt(150)+money.FromInt(20)+want)
+	wantReconciled(t, b)
+}
+
+func TestTreasury(t *testing.T) {
+	b, clock := newTestBank(t)
+	fund(t, b, "100", 200)
+	fund(t, b, "200", 100)
+	p, _ := b.CreateProduct(owner, storage.Product{Name: "SE-1", Price: money.FromInt(10), Rate: 1})
+	first, _ := b.BuyBond("100", p.ID, money.FromInt(100))
+	b.BuyBond("100", p.ID, money.FromInt(50))
+	b.SetBondLock(first.ID, true)
+	clock.Advance(2 * 24 * time.Hour)
+
+	tr, err := b.Treasury()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Bonds != 2 || tr.Principal != money.FromInt(150) || tr.Interest != 302 || tr.Reserves != 0 {
+		t.Fatalf("treasury = %+v", tr)
+	}
+	if tr.Liabilities() != 15302 || tr.Shortfall() != 302 {
+		t.Errorf("liabilities %s, shortfall %s", tr.Liabilities(), tr.Shortfall())
+	}
+
+	// Проценты выплачиваются из казны, комиссия рынка поступает в неё.
+	if _, err := b.SellBond("100", first.ID); err != nil {
+		t.Fatal(err)
+	}
+	b.SetSetting(owner, SettingMarketFeePct, "10")
+	bonds, _ := b.Bonds("100")
+	b.SetBondLock(bonds[0].ID, true)
+	l, _ := b.ListBond("100", bonds[0].ID, money.FromInt(40))
+	if _, err := b.BuyListing("200", l.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.FundTreasury(owner, money.FromInt(10), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.FundTreasury(owner, 0, ""); !errors.Is(err, ErrBadAmount) {
+		t.Errorf("zero funding: err = %v", err)
+	}
+	tr, _ = b.Treasury()
+	if want := money.FromInt(10) - 201 + money.FromInt(4); tr.Reserves != want || tr.Shortfall() != 0 {
+		t.Errorf("reserves = %s, want %s; shortfall %s", tr.Reserves, want, tr.Shortfall())
+	}
+	if _, bal, _ := b.Statement(storage.AccountTreasury, 10); bal != tr.Reserves {
+		t.Errorf("statement balance = %s, reserves %s", bal, tr.Reserves)
+	}
+	wantReconciled(t, b)
+}
+
+func TestParseFeeSchedule(t *testing.T) {
+	cases := []struct {
+		spec    string
+		amounts []money.Money
+		fees    []money.Money
+		err     bool
+	}{
+		{spec: "0", amounts: []money.Money{10000}, fees: []money.Money{0}},
+		{spec: "5", amounts: []money.Money{100, 10000}, fees: []money.Money{500, 500}},
+		{spec: "1.5%", amounts: []money.Money{10000, 33}, fees: []money.Money{150, 0}},
+		{spec: "1000:0.5%, 0:1%, 10000:25", amounts: []money.Money{50000, 100000, 2000000}, fees: []money.Money{500, 500, 2500}},
+		{spec: "100:1", amounts: []money.Money{5000, 10000}, fees: []money.Money{0, 100}},
+		{spec: "abc", err: true},
+		{spec: "101%", err: true},
+		{spec: "0:1,0:2", err: true},
+		{spec: "-1", err: true},
+	}
+	for _, c := range cases {
+		s, err := ParseFeeSchedule(c.spec)
+		if c.err {
+			if !errors.Is(err, ErrBadSetting) {
+				t.Errorf("%q: err = %v", c.spec, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", c.spec, err)
+		}
+		for i, a := range c.amounts {
+			if got := s.Fee(a); got != c.fees[i] {
+				t.Errorf("%q: fee(%s) = %s, want %s", c.spec, a, got, c.fees[i])
+			}
+		}
+	}
+}
+
+func TestFees(t *testing.T) {
+	b, _ := newTestBank(t)
+	fund(t, b, "100", 100)
+	b.SetFee(owner, FeeTransfer, "1%")
+	b.SetFee(owner, FeeWithdraw, "2")
+	b.SetFee(owner, FeeSellBond, "0:10%")
+	if err := b.SetFee(owner, "deposit", "1"); !errors.Is(err, ErrUnknownFeeOp) {
+		t.Errorf("unknown op: err = %v", err)
+	}
+
+	q, _ := b.QuoteFee("100", FeeTransfer, money.FromInt(50))
+	if q.Fee != 50 || q.Total != 5050 {
+		t.Errorf("quote = %+v", q)
+	}
+	tr, err := transferNow(b, "100", "200", money.FromInt(50))
+	if err != nil || tr.Fee != 50 {
+		t.Fatalf("transfer = %+v, %v", tr, err)
+	}
+	wantBalance(t, b, "100", 4950)
+	wantBalance(t, b, "200", money.FromInt(50))
+
+	// Комиссия фиксируется в заявке: смена тарифа её не меняет.
+	r, _ := b.RequestMoney("100", storage.RequestWithdraw, money.FromInt(10))
+	b.SetFee(owner, FeeWithdraw, "5")
+	if _, err := b.DecideRequest(r.ID, owner, true); err != nil {
+		t.Fatal(err)
+	}
+	wantBalance(t, b, "100", 4950-1200)
+
+	p, _ := b.CreateProduct(owner, storage.Product{Name: "SE-1", Price: money.FromInt(10)})
+	bond, _ := b.BuyBond("100", p.ID, money.FromInt(20))
+	b.SetBondLock(bond.ID, true)
+	sale, err := b.SellBond("100", bond.ID)
+	if err != nil || sale.Paid != money.FromInt(18) || sale.Fee != money.FromInt(2) {
+		t.Fatalf("sale = %+v, %v", sale, err)
+	}
+
+	// Освобождённая роль не платит комиссию.
+	if err := b.SetSetting(owner, SettingFeeFreeRoles, "player"); !errors.Is(err, ErrBadSetting) {
+		t.Errorf("player role among admin roles: err = %v", err)
+	}
+	b.SetSetting(owner, SettingFeeFreePlayerRoles, "player")
+	if tr, _ := transferNow(b, "100", "200", money.FromInt(10)); tr.Fee != 0 {
+		t.Errorf("free role paid %s", tr.Fee)
+	}
+
+	tre, _ := b.Treasury()
+	// Комиссии за перевод, вывод и досрочное закрытие плюс сам вывод.
+	if tre.Reserves != 50+200+money.FromInt(2)+money.FromInt(10) {
+		t.Errorf("treasury reserves = %s", tre.Reserves)
+	}
+	wantReconciled(t, b)
+}
+
+func TestFeeFreeAdminRoles(t *testing.T) {
+	b, _ := newTestBank(t)
+	b.SetFee(owner, FeeTransfer, "1")
+	b.SetSetting(owner, SettingFeeFreeRoles, RoleFinance)
+	// Игрок, зарегистрированный с ролью, названной как роль администратора
+	// (так было до проверки ролей при регистрации), льготу не получает.
+	b.tx(func(tx storage.Tx) error {
+		return tx.UpsertUser(storage.User{ID: "300", Nick: "mallory", Role: RoleFinance})
+	})
+	fund(t, b, "300", 100)
+	fund(t, b, "100", 100)
+	if tr, err := transferNow(b, "300", "200", money.FromInt(10)); err != nil || tr.Fee != money.FromInt(1) {
+		t.Errorf("player named after an admin role: transfer = %+v, %v", tr, err)
+	}
+	if err := b.SetAdmin("100", RoleFinance, owner); err != nil {
+		t.Fatal(err)
+	}
+	if tr, err := transferNow(b, "100", "200", money.FromInt(10)); err != nil || tr.Fee != 0 {
+		t.Errorf("finance admin: transfer = %+v, %v", tr, err)
+	}
+}
+
+func TestConfirmTransfer(t *testing.T) {
+	b, clock := newTestBank(t)
+	fund(t, b, "100", 50)
+	if err := b.Register("300", "Bob", "player"); !errors.Is(err, ErrNickTaken) {
+		t.Errorf("register a taken nick: err = %v", err)
+	}
+	if err := b.Register("200", "BOB", "player"); err != nil {
+		t.Errorf("change the case of one's own nick: err = %v", err)
+	}
+	b.Register("200", "bob", "player")
+
+	// Одинаковые ники, заведённые до проверки: отправитель видит их ID.
+	b.tx(func(tx storage.Tx) error { return tx.UpsertUser(storage.User{ID: "300", Nick: "Bob", Role: "player"}) })
+	if _, err := b.PrepareTransfer("100", "bob", money.FromInt(5), ""); !errors.Is(err, ErrAmbiguousRecipient) || !strings.Contains(err.Error(), "200, 300") {
+		t.Errorf("ambiguous nick: err = %v", err)
+	}
+	b.SetBanned("300", true)
+	if _, err := b.PrepareTransfer("100", "300", money.FromInt(5), ""); !errors.Is(err, ErrRecipientBanned) {
+		t.Errorf("banned recipient: err = %v", err)
+	}
+	if _, err := b.PrepareTransfer("100", "100", money.FromInt(5), ""); !errors.Is(err, ErrSelfTransfer) {
+		t.Errorf("self transfer: err = %v", err)
+	}
+	if _, err := b.PrepareTransfer("100", "200", money.FromInt(5), strings.Repeat("я", MaxCommentLen+1)); !errors.Is(err, ErrCommentTooLong) {
+		t.Errorf("long comment: err = %v", err)
+	}
+
+	d, err := b.PrepareTransfer("100", "200", money.FromInt(20), "долг")
+	if err != nil || d.ToNick != "bob" {
+		t.Fatalf("prepare = %+v, %v", d, err)
+	}
+	cancelled, _ := b.PrepareTransfer("100", "200", money.FromInt(30), "")
+	if _, err := b.CancelTransfer("200", cancelled.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("cancel someone else's transfer: err = %v", err)
+	}
+	b.CancelTransfer("100", cancelled.ID)
+	if _, err := b.ConfirmTransfer("100", cancelled.ID); !errors.Is(err, storage.ErrTransferClosed) {
+		t.Errorf("confirm cancelled: err = %v", err)
+	}
+
+	tr, err := b.ConfirmTransfer("100", d.ID)
+	if err != nil || tr.Comment != "долг" || tr.ToID != "200" {
+		t.Fatalf("confirm = %+v, %v", tr, err)
+	}
+	if _, err := b.ConfirmTransfer("100", d.ID); !errors.Is(err, storage.ErrTransferClosed) {
+		t.Errorf("second confirm: err = %v", err)
+	}
+	wantBalance(t, b, "200", money.FromInt(20))
+	if h, _ := b.History("200", storage.HistoryFilter{}); len(h) != 1 || !strings.Contains(h[0].Memo, "долг") {
+		t.Errorf("history = %+v", h)
+	}
+
+	late, _ := b.PrepareTransfer("100", "200", money.FromInt(5), "")
+	clock.Advance(TransferTTL + time.Second)
+	if _, err := b.ConfirmTransfer("100", late.ID); !errors.Is(err, ErrTransferExpired) {
+		t.Errorf("expired: err = %v", err)
+	}
+	if _, err := b.CancelTransfer("100", late.ID); !errors.Is(err, storage.ErrTransferClosed) {
+		t.Errorf("expired transfer stays open: err = %v", err)
+	}
+	wantBalance(t, b, "100", money.FromInt(30))
+	wantReconciled(t, b)
+}
+
+func TestLimits(t *testing.T) {
+	b, clock := newTestBank(t)
+	fund(t, b, "100", 1000)
+
+	if err := b.SetLimit(owner, "role:player", LimitTransfer, money.FromInt(100)); err != nil {
+		t.Fatal(err)
+	}
+	b.SetLimit(owner, ScopeAll, LimitTransfer, money.FromInt(10))
+	b.SetLimit(owner, ScopeAll, LimitDaily, money.FromInt(150))
+	b.SetLimit(owner, ScopeAll, LimitPending, money.FromInt(50))
+	if err := b.SetLimit(owner, "nobody", LimitDaily, 1); !errors.Is(err, ErrBadLimitScope) {
+		t.Errorf("bad scope: err = %v", err)
+	}
+	if err := b.SetLimit(owner, ScopeAll, "weekly", 1); !errors.Is(err, ErrUnknownLimit) {
+		t.Errorf("unknown limit: err = %v", err)
+	}
+
+	// Лимит роли важнее общего.
+	if _, err := transferNow(b, "100", "200", money.FromInt(100)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Сверх суточного лимита перевод уходит на проверку, деньги не двигаются.
+	d, _ := b.PrepareTransfer("100", "200", money.FromInt(60), "")
+	tr, err := b.ConfirmTransfer("100", d.ID)
+	if err != nil || tr.Review == nil || tr.Review.Name != LimitDaily || tr.TxID != 0 {
+		t.Fatalf("confirm over limit = %+v, %v", tr, err)
+	}
+	wantBalance(t, b, "100", money.FromInt(900))
+	if _, err := b.CancelTransfer("100", d.ID); !errors.Is(err, storage.ErrTransferClosed) {
+		t.Errorf("cancel transfer in review: err = %v", err)
+	}
+	if tr, err := b.DecideTransfer(d.ID, owner, true); err != nil || tr.TxID == 0 {
+		t.Fatalf("approve = %+v, %v", tr, err)
+	}
+	if _, err := b.DecideTransfer(d.ID, owner, false); !errors.Is(err, storage.ErrTransferClosed) {
+		t.Errorf("second decision: err = %v", err)
+	}
+	wantBalance(t, b, "200", money.FromInt(160))
+
+	// Лимит игрока важнее лимита роли.
+	b.SetLimit(owner, "100", LimitTransfer, money.FromInt(5))
+	if breach, _ := b.CheckLimits("100", FeeTransfer, money.FromInt(6)); breach == nil || breach.Name != LimitTransfer {
+		t.Errorf("user limit: breach = %+v", breach)
+	}
+	b.DeleteLimit("100", LimitTransfer)
+
+	clock.Advance(24*time.Hour + time.Second)
+	b.RequestMoney("100", storage.RequestWithdraw, money.FromInt(40))
+	if breach, _ := b.CheckLimits("100", FeeWithdraw, money.FromInt(20)); breach == nil || breach.Name != LimitPending || breach.Used != money.FromInt(40) {
+		t.Errorf("pending limit: breach = %+v", breach)
+	}
+
+	o, err := b.Overview("100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Allowance{
+		{LimitTransfer, money.FromInt(100), money.FromInt(100)},
+		{LimitDaily, money.FromInt(150), money.FromInt(150)},
+		{LimitPending, money.FromInt(50), money.FromInt(10)},
+	}
+	if len(o.Limits) != len(want) {
+		t.Fatalf("allowances = %+v", o.Limits)
+	}
+	for i := range want {
+		if o.Limits[i] != want[i] {
+			t.Errorf("allowance %d = %+v, want %+v", i, o.Limits[i], want[i])
+		}
+	}
+	wantReconciled(t, b)
+}
+
+func TestRegisterKeepsRole(t *testing.T) {
+	b, _ := newTestBank(t)
+	if err := b.SetSetting(owner, SettingPlayerRoles, "player,finance"); !errors.Is(err, ErrBadSetting) {
+		t.Errorf("admin role as player role: err = %v", err)
+	}
+	if err := b.SetSetting(owner, SettingPlayerRoles, "player,vip"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Register("300", "carol", "owner"); !errors.Is(err, ErrBadRole) {
+		t.Errorf("register with an admin role: err = %v", err)
+	}
+	b.SetLimit(owner, RoleScope("player"), LimitTransfer, money.FromInt(10))
+	b.SetLimit(owner, RoleScope("vip"), LimitTransfer, money.FromInt(1000))
+
+	// Повторная регистрация меняет ник, но не роль и не её лимиты.
+	if err := b.Register("100", "alice2", "vip"); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := b.User("100")
+	if u.Nick != "alice2" || u.Role != "player" {
+		t.Errorf("user after re-register = %+v", u)
+	}
+	al, err := b.Allowances("100")
+	if err != nil || len(al) != 1 || al[0].Name != LimitTransfer || al[0].Limit != money.FromInt(10) {
+		t.Errorf("allowances = %+v, %v", al, err)
+	}
+}
+
+func TestRejectInvalidAmounts(t *testing.T) {
+	b, _ := newTestBank(t)
+	fund(t, b, "100", 100)
+	p, _ := b.CreateProduct(owner, storage.Product{Name: "SE-1", Rate: 1})
+	bond, _ := b.BuyBond("100", p.ID, money.FromInt(10))
+	b.SetBondLock(bond.ID, true)
+
+	for name, err := range map[string]error{
+		"negative transfer":  func() error { _, err := transferNow(b, "200", "100", money.FromInt(-5)); return err }(),
+		"prepared transfer":  func() error { _, err := b.PrepareTransfer("100", "200", 0, ""); return err }(),
+		"negative withdraw":  func() error { _, err := b.RequestMoney("100", storage.RequestWithdraw, -100); return err }(),
+		"zero bond purchase": func() error { _, err := b.BuyBond("100", p.ID, 0); return err }(),
+		"negative top-up":    func() error { _, err := b.TopUpBond("100", bond.ID, -1); return err }(),
+		"zero listing":       func() error { _, err := b.ListBond("100", bond.ID, 0); return err }(),
+		"negative deposit":   b.AdminDeposit(owner, "100", -1),
+	} {
+		if !errors.Is(err, ErrAmountNotPositive) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+	if _, err := transferNow(b, "100", "200", MaxAmount+1); !errors.Is(err, ErrAmountTooLarge) {
+		t.Errorf("huge transfer: err = %v", err)
+	}
+	if err := b.AdminDeposit(owner, "100500", money.FromInt(5)); !errors.Is(err, ErrUnknownRecipient) {
+		t.Errorf("deposit to unknown player: err = %v", err)
+	}
+	if _, err := b.CreateProduct(owner, storage.Product{Name: "NaN", Rate: math.NaN()}); !errors.Is(err, ErrBadProduct) {
+		t.Errorf("NaN rate: err = %v", err)
+	}
+	if err := b.SetFee(owner, FeeTransfer, "NaN%"); !errors.Is(err, ErrBadSetting) {
+		t.Errorf("NaN fee: err = %v", err)
+	}
+	for _, id := range []string{"", "abc", "-5", "12 34"} {
+		if CheckUserID(id) == nil {
+			t.Errorf("CheckUserID(%q) passed", id)
+		}
+	}
+	wantBalance(t, b, "100", money.FromInt(90))
+	wantBalance(t, b, "200", 0)
+	wantReconciled(t, b)
+}
+
+func TestDecideRequestOnce(t *testing.T) {
+	b, _ := newTestBank(t)
+	fund(t, b, "100", 50)
+
+	r, err := b.RequestMoney("100", storage.RequestWithdraw, money.FromInt(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.DecideRequest(r.ID, owner, true); err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.DecideRequest(r.ID, owner, true)
+	if !errors.Is(err, storage.ErrRequestClosed) {
+		t.Fatalf("second approve: err = %v", err)
+	}
+	if got.Status != storage.StatusApproved {
+		t.Errorf("status = %s", got.Status)
+	}
+	wantBalance(t, b, "100", money.FromInt(30))
+	wantReconciled(t, b)
+}
+
+func TestWithdrawInsufficientStaysPending(t *testing.T) {
+	b, _ := newTestBank(t)
+
+	r, err := b.RequestMoney("100", storage.RequestWithdraw, money.FromInt(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.DecideRequest(r.ID, owner, true); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("err = %v", err)
+	}
+	pending, err := b.PendingRequests("100")
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("pending = %v, %v", pending, err)
+	}
+	if _, err := b.DecideRequest(r.ID, owner, false); err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+}
+
+func TestCancelAndExpire(t *testing.T) {
+	b, clock := newTestBank(t)
+
+	r1, _ := b.RequestMoney("100", storage.RequestDeposit, money.FromInt(5))
+	r2, _ := b.RequestMoney("100", storage.RequestDeposit, money.FromInt(6))
+
+	if _, err := b.CancelRequest("200", r1.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("cancel foreign request: err = %v", err)
+	}
+	if r, err := b.CancelRequest("100", r1.ID); err != nil || r.Status != storage.StatusCancelled {
+		t.Fatalf("cancel = %v, %v", r, err)
+	}
+
+	clock.Advance(RequestTTL + time.Minute)
+	expired, err := b.ExpireRequests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || expired[0].ID != r2.ID {
+		t.Fatalf("expired = %+v", expired)
+	}
+	if _, err := b.DecideRequest(r2.ID, owner, true); !errors.Is(err, storage.ErrRequestClosed) {
+		t.Errorf("approve expired: err = %v", err)
+	}
+	wantBalance(t, b, "100", 0)
+}
+
+func TestHistory(t *testing.T) {
+	b, clock := newTestBank(t)
+	fund(t, b, "100", 100)
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Hour)
+		if _, err := transferNow(b, "100", "200", money.FromInt(10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := b.History("100", storage.HistoryFilter{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 {
+		t.Fatalf("page = %+v", page)
+	}
+	if h := page[0]; h.Kind != storage.TxTransfer || h.Counterparty != "bob" || h.Amount != -money.FromInt(10) || *h.BalanceAfter != money.FromInt(70) {
+		t.Errorf("latest = %+v", h)
+	}
+
+	rest, err := b.History("100", storage.HistoryFilter{Limit: 2, Before: page[1].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 2 || rest[1].Kind != storage.TxAdminDeposit || rest[1].Counterparty != "Казна банка" {
+		t.Errorf("rest = %+v", rest)
+	}
+
+	only, _ := b.History("100", storage.HistoryFilter{Kind: storage.TxAdminDeposit})
+	if len(only) != 1 {
+		t.Errorf("filtered = %+v", only)
+	}
+}
+
+func TestComplaintCooldown(t *testing.T) {
+	b, clock := newTestBank(t)
+
+	if _, _, err := b.Complain("100", "alice", ""); !errors.Is(err, ErrComplaintEmpty) {
+		t.Fatalf("empty: err = %v", err)
+	}
+	if _, _, err := b.Complain("100", "alice", "где мои деньги"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Hour)
+	_, wait, err := b.Complain("100", "alice", "ещё раз")
+	if !errors.Is(err, ErrComplaintCooldown) || wait != 10*time.Hour {
+		t.Fatalf("cooldown = %v, %v", wait, err)
+	}
+	clock.Advance(wait)
+	if _, _, err := b.Complain("100", "alice", "ещё раз"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTickets(t *testing.T) {
+	b, _ := newTestBank(t)
+
+	c, _, err := b.Complain("100", "alice", "  где мои деньги  ")
+	if err != nil || c.ID == 0 || c.Status != storage.TicketOpen || c.Text != "где мои деньги" {
+		t.Fatalf("complain = %+v, %v", c, err)
+	}
+	if _, err := b.ReplyTicket(c.ID, "200", false, "это не моё"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("reply to someone else's ticket: err = %v", err)
+	}
+	if c, err = b.ReplyTicket(c.ID, owner, true, "проверяем"); err != nil || c.Status != storage.TicketInProgress || c.AssignedTo != owner {
+		t.Fatalf("admin reply = %+v, %v", c, err)
+	}
+	b.ReplyTicket(c.ID, "100", false, "спасибо")
+	if c, _ = b.AssignTicket(c.ID, "5"); c.AssignedTo != "5" {
+		t.Errorf("assigned to %q", c.AssignedTo)
+	}
+
+	tk, err := b.Ticket(c.ID)
+	if err != nil || len(tk.Messages) != 2 || !tk.Messages[0].FromAdmin || tk.Messages[1].Text != "спасибо" {
+		t.Fatalf("ticket = %+v, %v", tk, err)
+	}
+	if list, _ := b.Tickets(storage.ComplaintFilter{AssignedTo: "5"}); len(list) != 1 {
+		t.Errorf("assigned tickets = %+v", list)
+	}
+
+	if _, err := b.CloseTicket(c.ID, owner, storage.TicketOpen); !errors.Is(err, ErrBadTicketStatus) {
+		t.Errorf("close as open: err = %v", err)
+	}
+	if c, err = b.CloseTicket(c.ID, owner, storage.TicketResolved); err != nil || !c.Closed() {
+		t.Fatalf("close = %+v, %v", c, err)
+	}
+	if c, err = b.CloseTicket(c.ID, "5", storage.TicketRejected); !errors.Is(err, ErrTicketClosed) || c.Status != storage.TicketResolved {
+		t.Errorf("second close = %+v, %v", c, err)
+	}
+	if _, err := b.ReplyTicket(c.ID, "100", false, "ещё"); !errors.Is(err, ErrTicketClosed) {
+		t.Errorf("reply to closed ticket: err = %v", err)
+	}
+	if list, _ := b.Tickets(storage.ComplaintFilter{Statuses: []string{storage.TicketOpen, storage.TicketInProgress}}); len(list) != 0 {
+		t.Errorf("open tickets = %+v", list)
+	}
+	if list, _ := b.UserTickets("100", 10); len(list) != 1 || len(list[0].Messages) != 2 {
+		t.Errorf("user tickets = %+v", list)
+	}
+}
+
+func TestEvidence(t *testing.T) {
+	b, clock := newTestBank(t)
+
+	if _, err := b.AttachEvidence("100", "f0", storage.FilePhoto); !errors.Is(err, ErrNoEvidenceTarget) {
+		t.Errorf("nothing to attach to: err = %v", err)
+	}
+	verified, _ := b.RequestMoney("100", storage.RequestDeposit, money.FromInt(50))
+	unverified, _ := b.RequestMoney("200", storage.RequestDeposit, money.FromInt(70))
+	e, err := b.AttachEvidence("100", "f1", storage.FilePhoto)
+	if err != nil || e.OwnerKind != storage.AttachRequest || e.Request.ID != verified.ID {
+		t.Fatalf("attach = %+v, %v", e, err)
+	}
+
+	clock.Advance(time.Minute)
+	c, _, _ := b.Complain("100", "alice", "скриншот не тот")
+	if e, _ = b.AttachEvidence("100", "f2", storage.FileDocument); e.OwnerKind != storage.AttachTicket || e.Ticket.ID != c.ID {
+		t.Errorf("newer ticket gets the file: %+v", e)
+	}
+	if files, _ := b.Attachments(storage.AttachRequest, verified.ID); len(files) != 1 || files[0].FileID != "f1" {
+		t.Errorf("request files = %+v", files)
+	}
+
+	clock.Advance(23 * time.Hour)
+	if expired, _ := b.ExpireUnverified(); len(expired) != 0 {
+		t.Errorf("expired too early: %+v", expired)
+	}
+	clock.Advance(time.Hour)
+	expired, err := b.ExpireUnverified()
+	if err != nil || len(expired) != 1 || expired[0].ID != unverified.ID {
+		t.Fatalf("expired = %+v, %v", expired, err)
+	}
+	if _, err := b.DecideRequest(verified.ID, owner, true); err != nil {
+		t.Errorf("request with evidence: %v", err)
+	}
+
+	b.SetSetting(owner, SettingEvidenceHours, "0")
+	b.RequestMoney("200", storage.RequestDeposit, money.FromInt(70))
+	clock.Advance(48 * time.Hour)
+	if expired, _ := b.ExpireUnverified(); len(expired) != 0 {
+		t.Errorf("window 0 expires requests: %+v", expired)
+	}
+}
+
+func TestAdmins(t *testing.T) {
+	b, _ := newTestBank(t)
+	if err := b.SeedOwners(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.SetAdmin(owner, RoleSupport, owner); !errors.Is(err, ErrBootstrapOwner) {
+		t.Fatalf("demote bootstrap owner: err = %v", err)
+	}
+	if err := b.SetAdmin("100", RoleSupport, owner); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Can("100", PermComplaints) || b.Can("100", PermFinance) {
+		t.Error("support permissions")
+	}
+	ids, err := b.AdminsWith(PermFinance)
+	if err != nil || len(ids) != 1 || ids[0] != owner {
+		t.Errorf("finance admins = %v, %v", ids, err)
+	}
+	if ok, err := b.RemoveAdmin("100"); !ok || err != nil {
+		t.Fatalf("remove = %v, %v", ok, err)
+	}
+	if b.Role("100") != "" {
+		t.Error("removed admin still has a role")
+	}
+}
+
+func TestBroadcastQueue(t *testing.T) {
+	b, _ := newTestBank(t)
+
+	if _, err := b.StartBroadcast(owner, storage.Broadcast{Text: "  "}, Segment{}); !errors.Is(err, ErrEmptyBroadcast) {
+		t.Errorf("empty text: err = %v", err)
+	}
+	job, err := b.StartBroadcast(owner, storage.Broadcast{Text: "турнир в субботу"}, Segment{})
+	if err != nil || job.Status != storage.BroadcastDraft || job.Total == 0 {
+		t.Fatalf("start = %+v, %v", job, err)
+	}
+	if _, batch, _ := b.BroadcastBatch(job.ID, 100); len(batch) != 0 {
+		t.Fatalf("draft delivers %d messages", len(batch))
+	}
+	if job, err = b.ConfirmBroadcast(job.ID); err != nil || job.Status != storage.BroadcastQueued {
+		t.Fatalf("confirm = %+v, %v", job, err)
+	}
+	job, batch, err := b.BroadcastBatch(job.ID, 100)
+	if err != nil || job.Status != storage.BroadcastRunning || len(batch) != job.Total {
+		t.Fatalf("batch = %+v, %d deliveries, %v", job, len(batch), err)
+	}
+
+	for _, d := range batch {
+		switch d.UserID {
+		case "100":
+			job, err = b.RecordDelivery(d, storage.DeliveryBlocked, "blocked")
+		case "200":
+			for i := 0; i < MaxDeliveryAttempts-1; i++ {
+				b.RecordDelivery(d, storage.DeliveryFailed, "timeout")
+				d.Attempts++
+			}
+			if _, retry, _ := b.BroadcastBatch(job.ID, 100); len(retry) != 1 || retry[0].UserID != "200" {
+				t.Errorf("retry batch = %+v", retry)
+			}
+			job, err = b.RecordDelivery(d, storage.DeliveryFailed, "timeout")
+		default:
+			job, err = b.RecordDelivery(d, storage.DeliverySent, "")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if job.Sent != job.Total-2 || job.Blocked != 1 || job.Failed != 1 {
+		t.Errorf("counters = %+v", job)
+	}
+	if job, batch, _ = b.BroadcastBatch(job.ID, 100); job.Status != storage.BroadcastDone || len(batch) != 0 {
+		t.Errorf("finished = %+v, %d deliveries", job, len(batch))
+	}
+	if _, err := b.CancelBroadcast(job.ID); !errors.Is(err, ErrBroadcastClosed) {
+		t.Errorf("cancel finished: err = %v", err)
+	}
+
+	next, _ := b.StartBroadcast(owner, storage.Broadcast{Text: "ещё одно"}, Segment{})
+	b.ConfirmBroadcast(next.ID)
+	if next.Total != job.Total-1 {
+		t.Errorf("blocked user still receives broadcasts: total = %d", next.Total)
+	}
+	if next, err = b.CancelBroadcast(0); err != nil || next.Status != storage.BroadcastCancelled {
+		t.Errorf("cancel latest = %+v, %v", next, err)
+	}
+	if _, batch, _ := b.BroadcastBatch(next.ID, 100); len(batch) != 0 {
+		t.Errorf("cancelled broadcast delivers %d messages", len(batch))
+	}
+	if _, err := b.CancelBroadcast(0); !errors.Is(err, ErrNoActiveBroadcast) {
+		t.Errorf("nothing to cancel: err = %v", err)
+	}
+
+	// Игрок, который снова написал боту, возвращается в рассылки.
+	b.Touch("100")
+	if again, _ := b.StartBroadcast(owner, storage.Broadcast{Text: "с возвращением"}, Segment{}); again.Total != job.Total {
+		t.Errorf("unblocked user skipped: total = %d", again.Total)
+	}
+}
+
+func TestBroadcastSegments(t *testing.T) {
+	b, clock := newTestBank(t)
+	fund(t, b, "100", 100)
+	p, _ := b.CreateProduct(owner, storage.Product{Name: "SE-1", Price: money.FromInt(10), Rate: 1})
+	if _, err := b.BuyBond("100", p.ID, money.FromInt(50)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, rest, err := ParseSegment([]string{"balance=5..1", "текст"}); !errors.Is(err, ErrBadSegment) {
+		t.Errorf("reversed range: rest %q, err = %v", rest, err)
+	}
+	seg, rest, err := ParseSegment([]string{fmt.Sprintf("bond=%d", p.ID), "ids=100,200", "текст", "a=b"})
+	if err != nil || len(rest) != 2 || seg.ProductID != p.ID || len(seg.IDs) != 2 {
+		t.Fatalf("segment = %+v, rest %q, err = %v", seg, rest, err)
+	}
+
+	msg := storage.Broadcast{Text: "купон"}
+	if job, err := b.StartBroadcast(owner, msg, seg); err != nil || job.Total != 1 {
+		t.Errorf("bond holders = %+v, %v", job, err)
+	}
+	if _, err := b.StartBroadcast(owner, msg, Segment{ProductID: 99}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("unknown bond: err = %v", err)
+	}
+	low := money.FromInt(60)
+	if _, err := b.StartBroadcast(owner, msg, Segment{MinBalance: &low}); !errors.Is(err, ErrNoRecipients) {
+		t.Errorf("no rich players: err = %v", err)
+	}
+
+	b.Touch("200")
+	clock.Advance(8 * 24 * time.Hour)
+	b.Touch("100")
+	if job, err := b.StartBroadcast(owner, msg, Segment{ActiveDays: 7}); err != nil || job.Total != 1 {
+		t.Errorf("active players = %+v, %v", job, err)
+	}
+
+	// Время активности обновляется не чаще раза в TouchInterval.
+	seen := clock.Now()
+	lastSeen := func() time.Time {
+		var u storage.User
+		b.tx(func(tx storage.Tx) error { u, _ = tx.User("100"); return nil })
+		return u.LastSeenAt
+	}
+	clock.Advance(TouchInterval / 2)
+	b.Touch("100")
+	if got := lastSeen(); !got.Equal(seen) {
+		t.Errorf("touched within the interval: last seen %s, want %s", got, seen)
+	}
+	clock.Advance(TouchInterval)
+	b.Touch("100")
+	if got := lastSeen(); !got.Equal(clock.Now()) {
+		t.Errorf("touched after the interval: last seen %s, want %s", got, clock.Now())
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	b, clock := newTestBank(t)
+	b.SetInfoLine("курс дня")
+	start := clock.Now()
+
+	msg := storage.Schedule{Kind: storage.ScheduleBroadcast, Text: "турнир"}
+	if _, err := b.AddSchedule(owner, msg); !errors.Is(err, ErrScheduleTime) {
+		t.Errorf("no time: err = %v", err)
+	}
+	msg.StartsAt, msg.Every = start.Add(time.Hour), 30*time.Minute
+	if _, err := b.AddSchedule(owner, msg); !errors.Is(err, ErrScheduleEvery) {
+		t.Errorf("every 30m: err = %v", err)
+	}
+	msg.Every, msg.EndsAt, msg.Filters = 24*time.Hour, start.Add(30*time.Hour), "ids=100"
+	daily, err := b.AddSchedule(owner, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	once, _ := b.AddSchedule(owner, storage.Schedule{Kind: storage.ScheduleBroadcast, Text: "разово", StartsAt: start.Add(time.Hour)})
+	if _, err := b.AddSchedule(owner, storage.Schedule{Kind: storage.ScheduleInfo, Text: "x", Every: time.Hour}); !errors.Is(err, ErrBadScheduleKind) {
+		t.Errorf("recurring info line: err = %v", err)
+	}
+
+	b.AddSchedule(owner, storage.Schedule{Kind: storage.ScheduleInfo, Text: "низкий"})
+	b.AddSchedule(owner, storage.Schedule{Kind: storage.ScheduleInfo, Text: "акция А", Priority: 5, StartsAt: start.Add(time.Hour), EndsAt: start.Add(2 * time.Hour)})
+	b.AddSchedule(owner, storage.Schedule{Kind: storage.ScheduleInfo, Text: "акция Б", Priority: 5, StartsAt: start.Add(time.Hour), EndsAt: start.Add(2 * time.Hour)})
+	if info, _ := b.InfoLine(); info != "низкий" {
+		t.Errorf("info before promo = %q", info)
+	}
+
+	// Рассылка, которую не удаётся поставить в очередь, не мешает остальным.
+	broken := storage.Schedule{Kind: storage.ScheduleBroadcast, Text: "сломано", Filters: "bond=999", StartsAt: start.Add(time.Hour)}
+	b.tx(func(tx storage.Tx) error { return tx.CreateSchedule(&broken) })
+
+	if jobs, _, _ := b.RunSchedules(); len(jobs) != 0 {
+		t.Errorf("started too early: %+v", jobs)
+	}
+	clock.Advance(time.Hour)
+	jobs, skipped, err := b.RunSchedules()
+	if err != nil || len(jobs) != 2 || jobs[0].Total != 1 || jobs[1].Total != 2 || jobs[0].Status != storage.BroadcastQueued {
+		t.Fatalf("jobs = %+v, %v", jobs, err)
+	}
+	if len(skipped) != 1 || skipped[0].ID != broken.ID || !errors.Is(skipped[0].Err, storage.ErrNotFound) {
+		t.Errorf("skipped = %+v", skipped)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		info, _ := b.InfoLine()
+		seen[info] = true
+		clock.Advance(InfoRotation)
+	}
+	if !seen["акция А"] || !seen["акция Б"] {
+		t.Errorf("rotation shows %v", seen)
+	}
+
+	list, _ := b.Schedules()
+	if len(list) != 4 {
+		t.Fatalf("schedules = %+v", list)
+	}
+	for _, s := range list {
+		if s.ID == once.ID {
+			t.Error("one-off broadcast stays in schedule")
+		}
+		if s.ID == daily.ID && !s.StartsAt.Equal(start.Add(25*time.Hour)) {
+			t.Errorf("next run = %s", s.StartsAt)
+		}
+	}
+
+	clock.Advance(24 * time.Hour)
+	if jobs, _, _ := b.RunSchedules(); len(jobs) != 1 {
+		t.Errorf("second day jobs = %+v", jobs)
+	}
+	if list, _ := b.Schedules(); len(list) != 1 || list[0].Text != "низкий" {
+		t.Errorf("after expiry = %+v", list)
+	}
+	if err := b.Unschedule(list[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := b.InfoLine(); info != "курс дня" {
+		t.Errorf("fallback info = %q", info)
+	}
+	if err := b.Unschedule(list[0].ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("unschedule twice: err = %v", err)
+	}
+}
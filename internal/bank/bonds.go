@@ -0,0 +1,549 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+// BondView — вклад вместе с его текущей стоимостью.
+type BondView struct {
+	storage.Bond
+	Nick         string      `json:"-"`
+	CurrentValue money.Money `json:"current_value"`
+	Date         string      `json:"date"`
+	// Maturity — дата погашения (дд.мм.гггг), пусто у бессрочных вкладов.
+	Maturity string `json:"maturity"`
+	// RedeemNow — сколько игрок получит, закрыв вклад сейчас; CanRedeem —
+	// можно ли закрыть его сейчас с учётом условий досрочного закрытия.
+	RedeemNow money.Money `json:"redeem_now"`
+	CanRedeem bool        `json:"can_redeem"`
+	// AtMaturity — сколько вклад принесёт в дату погашения; 0 у бессрочных.
+	AtMaturity money.Money `json:"at_maturity,omitempty"`
+	// Principal — тело вклада (без начисленных процентов).
+	Principal money.Money `json:"principal"`
+}
+
+// couponPeriod — длина периода в днях для InterestMonthly и InterestCoupon.
+const couponPeriod = 30
+
+// matured — наступил ли срок погашения вклада.
+func (b *Bank) matured(bond storage.Bond) bool {
+	return !bond.MaturesAt.IsZero() && !b.Now().Before(bond.MaturesAt)
+}
+
+// BondValue — текущая стоимость вклада по его модели начисления процентов.
+// Считаются только полные дни (периоды); после даты погашения проценты не
+// начисляются.
+func (b *Bank) BondValue(bond storage.Bond) money.Money {
+	end := b.Now()
+	if b.matured(bond) {
+		end = bond.MaturesAt
+	}
+	return valueAt(bond, end)
+}
+
+// accruedSince — момент, с которого начисляются проценты на Amount + Accrued.
+func accruedSince(bond storage.Bond) time.Time {
+	if bond.AccruedSince.IsZero() {
+		return bond.CreatedAt
+	}
+	return bond.AccruedSince
+}
+
+// periodDays — длина периода начисления модели в днях.
+func periodDays(model string) int {
+	if model == storage.InterestMonthly || model == storage.InterestCoupon {
+		return couponPeriod
+	}
+	return 1
+}
+
+// periodsAt — сколько полных периодов начисления прошло к моменту end.
+func periodsAt(bond storage.Bond, end time.Time) int {
+	days := int(end.Sub(accruedSince(bond)).Hours() / 24)
+	if days < 0 {
+		return 0
+	}
+	return days / periodDays(bond.InterestModel)
+}
+
+// valueAt — стоимость вклада на момент end: тело, уже начисленные проценты
+// Accrued и проценты за полные периоды с AccruedSince. Простой процент и
+// купон считаются только от тела, сложный — от тела вместе с Accrued. У
+// купонного вклада в стоимость входят ещё не выплаченные купоны.
+func valueAt(bond storage.Bond, end time.Time) money.Money {
+	n := periodsAt(bond, end)
+	base := bond.Amount + bond.Accrued
+	switch bond.InterestModel {
+	case storage.InterestSimple:
+		return base + bond.Amount.Percent(bond.Rate*float64(n))
+	case storage.InterestCoupon:
+		unpaid := max(n-bond.CouponsPaid, 0)
+		return base + bond.Amount.Percent(bond.Rate)*money.Money(unpaid)
+	default:
+		return base.Grow(bond.Rate, n)
+	}
+}
+
+// crystallize переносит проценты за прошедшие полные периоды в Accrued и
+// сдвигает AccruedSince, чтобы после изменения тела вклада стоимость
+// продолжала считаться верно. Неполный период не теряется: AccruedSince
+// сдвигается ровно на целое число периодов.
+func (b *Bank) crystallize(bond *storage.Bond) {
+	end := b.Now()
+	if b.matured(*bond) {
+		end = bond.MaturesAt
+	}
+	n := periodsAt(*bond, end)
+	if n == 0 {
+		return
+	}
+	since := accruedSince(*bond).AddDate(0, 0, n*periodDays(bond.InterestModel))
+	bond.Accrued = valueAt(*bond, since) - bond.Amount
+	bond.AccruedSince = since
+	bond.CouponsPaid = 0
+}
+
+// payout — сколько владелец получит, закрыв вклад сейчас, и можно ли его
+// закрыть. Заблокированный вклад до погашения закрывается по EarlyPolicy.
+func (b *Bank) payout(bond storage.Bond) (money.Money, bool) {
+	val := b.BondValue(bond)
+	if bond.CanWithdraw || b.matured(bond) {
+		return val, true
+	}
+	switch bond.EarlyPolicy {
+	case storage.EarlyForfeit:
+		return bond.Amount, true
+	case storage.EarlyPenalty:
+		return val - val.Percent(bond.PenaltyPct), true
+	}
+	return val, false
+}
+
+func (b *Bank) view(bond storage.Bond) BondView {
+	v := BondView{Bond: bond, CurrentValue: b.BondValue(bond), Date: bond.CreatedAt.Format("02.01.2006")}
+	v.Principal = bond.Amount
+	if !bond.MaturesAt.IsZero() {
+		v.Maturity = bond.MaturesAt.Format("02.01.2006")
+	}
+	// Погашенный вклад доступен к выводу, даже если воркер ещё не снял блокировку.
+	v.CanWithdraw = bond.CanWithdraw || b.matured(bond)
+	v.RedeemNow, v.CanRedeem = b.payout(bond)
+	if !bond.MaturesAt.IsZero() {
+		v.AtMaturity = valueAt(bond, bond.MaturesAt)
+	}
+	return v
+}
+
+func (b *Bank) Bonds(uid string) ([]BondView, error) {
+	var res []BondView
+	err := b.tx(func(tx storage.Tx) error {
+		bonds, err := tx.Bonds(uid)
+		for _, bond := range bonds {
+			res = append(res, b.view(bond))
+		}
+		return err
+	})
+	return res, err
+}
+
+// AllBonds — все открытые вклады, новые первыми, с никами владельцев.
+func (b *Bank) AllBonds() ([]BondView, error) {
+	var res []BondView
+	err := b.tx(func(tx storage.Tx) error {
+		bonds, err := tx.AllBonds()
+		if err != nil {
+			return err
+		}
+		for _, bond := range bonds {
+			v := b.view(bond)
+			u, err := tx.User(bond.UserID)
+			if err != nil {
+				// Вклады без зарегистрированного владельца в отчёт не попадают.
+				continue
+			}
+			v.Nick = u.Nick
+			res = append(res, v)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// BuyBond списывает amount со счёта игрока и открывает вклад по облигации
+// productID. Облигация блокируется на время покупки, поэтому параллельные
+// покупки не превышают лимиты выпуска.
+func (b *Bank) BuyBond(uid string, productID int, amount money.Money) (storage.Bond, error) {
+	bond := storage.Bond{UserID: uid, Amount: amount, ProductID: productID}
+	if err := CheckAmount(amount); err != nil {
+		return bond, err
+	}
+	err := b.tx(func(tx storage.Tx) error {
+		p, err := tx.LockProduct(productID)
+		if err != nil {
+			return err
+		}
+		if p.Status == storage.ProductActive && amount < p.Price {
+			return ErrBelowMinimum
+		}
+		if err := b.checkSubscription(tx, p, uid, amount); err != nil {
+			return err
+		}
+		if _, err := b.post(tx, storage.Transaction{
+			Kind:      storage.TxBuyBond,
+			Initiator: uid,
+			Memo:      p.Name,
+			Entries:   transferEntries(uid, storage.AccountBonds, amount),
+		}); err != nil {
+			return err
+		}
+		bond.Name, bond.Rate, bond.CreatedAt, bond.AutoRedeem = p.Name, p.Rate, b.Now(), p.AutoRedeem
+		bond.AccruedSince = bond.CreatedAt
+		bond.MaturesAt = maturityFor(p, bond.CreatedAt)
+		bond.InterestModel, bond.EarlyPolicy, bond.PenaltyPct = p.InterestModel, p.EarlyPolicy, p.PenaltyPct
+		if err := tx.AddIssued(p.ID, amount); err != nil {
+			return err
+		}
+		return tx.InsertBond(&bond)
+	})
+	return bond, err
+}
+
+// checkSubscription проверяет, что по заблокированной вызывающим облигации
+// p игрок uid может вложить ещё amount: облигация в продаже, подписка
+// открыта, а лимиты выпуска и на игрока не превышены. Общая для покупки и
+// пополнения вклада.
+func (b *Bank) checkSubscription(tx storage.Tx, p storage.Product, uid string, amount money.Money) error {
+	if p.Status != storage.ProductActive {
+		return ErrNotOnSale
+	}
+	if !b.subscriptionOpen(p) {
+		return ErrSubscriptionClosed
+	}
+	if left := capacity(p.MaxSupply, p.Issued); left != nil && amount > *left {
+		return ErrSupplyExhausted
+	}
+	if p.PerUserMax > 0 {
+		held, err := tx.Holdings(uid, p.ID)
+		if err != nil {
+			return err
+		}
+		if amount > *capacity(p.PerUserMax, held) {
+			return ErrHoldingLimit
+		}
+	}
+	return nil
+}
+
+// redeem закрывает заблокированный вызывающим вклад и зачисляет владельцу
+// val за вычетом комиссии fee. Разница между зачисленным и телом
+// списывается с казны (или поступает в неё, если удержаны штраф или комиссия).
+func (b *Bank) redeem(tx storage.Tx, bond storage.Bond, initiator string, val, fee money.Money) (money.Money, error) {
+	memo := fmt.Sprintf("%s #%d", bond.Name, bond.ID)
+	if !bond.CanWithdraw && !b.matured(bond) {
+		memo += " (досрочно)"
+	}
+	paid := val - fee
+	if _, err := b.post(tx, storage.Transaction{
+		Kind:      storage.TxSellBond,
+		Initiator: initiator,
+		Memo:      memo,
+		Entries: []storage.Entry{
+			{Account: storage.AccountBonds, Amount: -bond.Amount},
+			{Account: storage.AccountTreasury, Amount: -(paid - bond.Amount)},
+			{Account: bond.UserID, Amount: paid},
+		},
+	}); err != nil {
+		return 0, err
+	}
+	if err := b.closeListings(tx, bond.ID); err != nil {
+		return 0, err
+	}
+	return paid, tx.DeleteBond(bond.ID)
+}
+
+// BondSale — итог продажи вклада банку.
+type BondSale struct {
+	// Paid — зачислено игроку, Fee — удержанная комиссия FeeSellBond.
+	Paid money.Money
+	Fee  money.Money
+}
+
+// SellBond закрывает вклад и зачисляет игроку его текущую стоимость, а
+// заблокированный до погашения — сумму по условиям досрочного закрытия;
+// комиссия FeeSellBond удерживается из этой суммы. Строка вклада
+// блокируется, чтобы два одновременных запроса не закрыли его дважды.
+func (b *Bank) SellBond(uid string, bondID int) (BondSale, error) {
+	var s BondSale
+	err := b.tx(func(tx storage.Tx) error {
+		bond, err := tx.LockBond(bondID, uid)
+		if err != nil {
+			return err
+		}
+		payout, ok := b.payout(bond)
+		if !ok {
+			return ErrBondLocked
+		}
+		if s.Fee, err = b.fee(tx, uid, FeeSellBond, payout); err != nil {
+			return err
+		}
+		s.Fee = min(s.Fee, payout)
+		s.Paid, err = b.redeem(tx, bond, uid, payout, s.Fee)
+		return err
+	})
+	return s, err
+}
+
+var (
+	// ErrPartialTooLarge — частичное снятие не может забрать весь вклад.
+	ErrPartialTooLarge = errors.New("сумма не меньше стоимости вклада — закройте его целиком")
+	// ErrBondMatured — пополнить вклад после даты погашения нельзя.
+	ErrBondMatured = errors.New("срок вклада истёк")
+)
+
+// WithdrawFromBond снимает часть стоимости разблокированного или погашенного
+// вклада, оставляя остаток вложенным. Сначала снимаются начисленные
+// проценты, затем тело.
+func (b *Bank) WithdrawFromBond(uid string, bondID int, amount money.Money) (BondView, error) {
+	var v BondView
+	if err := CheckAmount(amount); err != nil {
+		return v, err
+	}
+	err := b.tx(func(tx storage.Tx) error {
+		bond, err := tx.LockBond(bondID, uid)
+		if err != nil {
+			return err
+		}
+		if !bond.CanWithdraw && !b.matured(bond) {
+			return ErrBondLocked
+		}
+		b.crystallize(&bond)
+		if amount >= bond.Amount+bond.Accrued {
+			return ErrPartialTooLarge
+		}
+		fromAccrued := min(amount, bond.Accrued)
+		fromPrincipal := amount - fromAccrued
+
+		if _, err := b.post(tx, storage.Transaction{
+			Kind:      storage.TxBondPartial,
+			Initiator: uid,
+			Memo:      fmt.Sprintf("%s #%d", bond.Name, bond.ID),
+			Entries: []storage.Entry{
+				{Account: storage.AccountBonds, Amount: -fromPrincipal},
+				{Account: storage.AccountTreasury, Amount: -fromAccrued},
+				{Account: uid, Amount: amount},
+			},
+		}); err != nil {
+			return err
+		}
+		bond.Amount -= fromPrincipal
+		bond.Accrued -= fromAccrued
+		if err := tx.UpdateBondPosition(bond); err != nil {
+			return err
+		}
+		// Объявление выставлено на прежний размер вклада.
+		if err := b.closeListings(tx, bond.ID); err != nil {
+			return err
+		}
+		v = b.view(bond)
+		return nil
+	})
+	return v, err
+}
+
+// TopUpBond добавляет amount к открытому вкладу под его прежний процент.
+// Пополнить можно только пока облигация в продаже и подписка открыта;
+// пополнение учитывается в лимитах выпуска, как покупка.
+func (b *Bank) TopUpBond(uid string, bondID int, amount money.Money) (BondView, error) {
+	var v BondView
+	if err := CheckAmount(amount); err != nil {
+		return v, err
+	}
+	err := b.tx(func(tx storage.Tx) error {
+		bond, err := tx.LockBond(bondID, uid)
+		if err != nil {
+			return err
+		}
+		if b.matured(bond) {
+			return ErrBondMatured
+		}
+		if bond.ProductID != 0 {
+			p, err := tx.LockProduct(bond.ProductID)
+			if err != nil {
+				return err
+			}
+			if err := b.checkSubscription(tx, p, uid, amount); err != nil {
+				return err
+			}
+			if err := tx.AddIssued(p.ID, amount); err != nil {
+				return err
+			}
+		}
+
+		if _, err := b.post(tx, storage.Transaction{
+			Kind:      storage.TxBondTopUp,
+			Initiator: uid,
+			Memo:      fmt.Sprintf("%s #%d", bond.Name, bond.ID),
+			Entries:   transferEntries(uid, storage.AccountBonds, amount),
+		}); err != nil {
+			return err
+		}
+		b.crystallize(&bond)
+		bond.Amount += amount
+		if err := tx.UpdateBondPosition(bond); err != nil {
+			return err
+		}
+		if err := b.closeListings(tx, bond.ID); err != nil {
+			return err
+		}
+		v = b.view(bond)
+		return nil
+	})
+	return v, err
+}
+
+func (b *Bank) SetBondLock(id int, canWithdraw bool) (bool, error) {
+	var ok bool
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		ok, err = tx.SetBondLock(id, canWithdraw)
+		return err
+	})
+	return ok, err
+}
+
+// Maturity — вклад, обработанный в срок погашения.
+type Maturity struct {
+	Bond storage.Bond
+	// Redeemed — вклад закрыт автоматически и Value зачислено на баланс;
+	// иначе он только разблокирован.
+	Redeemed bool
+	Value    money.Money
+}
+
+// MatureBonds обрабатывает вклады с наступившим сроком: с автопогашением
+// закрывает, остальные разблокирует. Каждый вклад — отдельная транзакция,
+// чтобы ошибка по одному не задерживала остальные: такие ошибки собираются
+// в возвращаемую вместе с обработанными вкладами.
+func (b *Bank) MatureBonds() ([]Maturity, error) {
+	var due []storage.Bond
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		due, err = tx.MaturedBonds(b.Now())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Maturity
+	var errs []error
+	for _, d := range due {
+		m := Maturity{Bond: d}
+		err := b.tx(func(tx storage.Tx) error {
+			// Вклад мог быть закрыт владельцем после выборки.
+			bond, err := tx.LockBond(d.ID, d.UserID)
+			if err != nil {
+				return err
+			}
+			m.Bond = bond
+			if !bond.AutoRedeem {
+				m.Value = b.BondValue(bond)
+				_, err := tx.SetBondLock(bond.ID, true)
+				return err
+			}
+			m.Redeemed = true
+			m.Value, err = b.redeem(tx, bond, "system", b.BondValue(bond), 0)
+			return err
+		})
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("вклад #%d: %w", d.ID, err))
+			continue
+		}
+		res = append(res, m)
+	}
+	return res, errors.Join(errs...)
+}
+
+// CouponPayment — купоны, выплаченные по вкладу на баланс владельца.
+type CouponPayment struct {
+	Bond   storage.Bond
+	Count  int
+	Amount money.Money
+}
+
+// PayCoupons выплачивает наступившие купоны по вкладам с моделью
+// InterestCoupon. Как и MatureBonds, каждый вклад — отдельная транзакция,
+// а ошибки по вкладам возвращаются вместе с выплаченными купонами.
+func (b *Bank) PayCoupons() ([]CouponPayment, error) {
+	var bonds []storage.Bond
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		bonds, err = tx.CouponBonds()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var res []CouponPayment
+	var errs []error
+	for _, c := range bonds {
+		var pay CouponPayment
+		err := b.tx(func(tx storage.Tx) error {
+			bond, err := tx.LockBond(c.ID, c.UserID)
+			if err != nil {
+				return err
+			}
+			end := b.Now()
+			if b.matured(bond) {
+				end = bond.MaturesAt
+			}
+			// Купоны, зафиксированные в Accrued при пополнении или частичном
+			// снятии, выплачиваются вместе с новыми.
+			due := periodsAt(bond, end) - bond.CouponsPaid
+			if due <= 0 && bond.Accrued == 0 {
+				return nil
+			}
+			due = max(due, 0)
+			pay = CouponPayment{Bond: bond, Count: due, Amount: bond.Amount.Percent(bond.Rate)*money.Money(due) + bond.Accrued}
+			if _, err := b.post(tx, storage.Transaction{
+				Kind:      storage.TxCoupon,
+				Initiator: "system",
+				Memo:      fmt.Sprintf("%s #%d", bond.Name, bond.ID),
+				Entries:   transferEntries(storage.AccountTreasury, bond.UserID, pay.Amount),
+			}); err != nil {
+				return err
+			}
+			bond.CouponsPaid += due
+			bond.Accrued = 0
+			return tx.UpdateBondPosition(bond)
+		})
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("вклад #%d: %w", c.ID, err))
+			continue
+		}
+		if pay.Amount > 0 {
+			res = append(res, pay)
+		}
+	}
+	return res, errors.Join(errs...)
+}
+
+// maturityFor — дата погашения вклада, купленного в момент at.
+func maturityFor(p storage.Product, at time.Time) time.Time {
+	if p.TermDays <= 0 {
+		return time.Time{}
+	}
+	return at.AddDate(0, 0, p.TermDays)
+}
@@ -0,0 +1,176 @@
+package bank
+
+import (
+	"errors"
+	"strings"
+
+	"mybot/internal/storage"
+)
+
+// MaxDeliveryAttempts — сколько раз воркер пытается доставить рассылку
+// игроку, прежде чем считать доставку неудачной. Ожидание из-за
+// ограничения частоты Telegram попыткой не считается.
+const MaxDeliveryAttempts = 3
+
+var (
+	// ErrEmptyBroadcast — текст рассылки пуст.
+	ErrEmptyBroadcast = errors.New("текст рассылки пуст")
+	// ErrBroadcastClosed — рассылка уже отправляется, завершена или отменена.
+	ErrBroadcastClosed = errors.New("рассылка уже запущена, завершена или отменена")
+	// ErrNoActiveBroadcast — нет рассылок в очереди или в работе.
+	ErrNoActiveBroadcast = errors.New("нет активных рассылок")
+)
+
+// StartBroadcast готовит рассылку msg получателям сегмента seg: текст,
+// фото или файл с подписью, форматированием и кнопками. Рассылка остаётся
+// черновиком, пока администратор не подтвердит её после предпросмотра.
+func (b *Bank) StartBroadcast(adminID string, msg storage.Broadcast, seg Segment) (storage.Broadcast, error) {
+	job := msg
+	job.Text, job.Segment = strings.TrimSpace(msg.Text), seg.String()
+	job.CreatedBy, job.Status, job.CreatedAt = adminID, storage.BroadcastDraft, b.Now()
+	if job.Text == "" && job.FileID == "" {
+		return job, ErrEmptyBroadcast
+	}
+	err := b.tx(func(tx storage.Tx) error {
+		recipients, err := b.recipients(tx, seg)
+		if err != nil {
+			return err
+		}
+		if len(recipients) == 0 {
+			return ErrNoRecipients
+		}
+		job.Total = len(recipients)
+		return tx.CreateBroadcast(&job, recipients)
+	})
+	return job, err
+}
+
+// ConfirmBroadcast ставит черновик рассылки id в очередь на отправку.
+func (b *Bank) ConfirmBroadcast(id int64) (storage.Broadcast, error) {
+	var job storage.Broadcast
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		if job, err = tx.LockBroadcast(id); err != nil {
+			return err
+		}
+		if job.Status != storage.BroadcastDraft {
+			return ErrBroadcastClosed
+		}
+		job.Status = storage.BroadcastQueued
+		return tx.UpdateBroadcast(job)
+	})
+	return job, err
+}
+
+// SetBroadcastMessage запоминает сообщение администратора, в котором
+// воркер показывает ход рассылки.
+func (b *Bank) SetBroadcastMessage(id, chatID int64, messageID int) error {
+	return b.tx(func(tx storage.Tx) error {
+		job, err := tx.LockBroadcast(id)
+		if err != nil {
+			return err
+		}
+		job.ChatID, job.MessageID = chatID, messageID
+		return tx.UpdateBroadcast(job)
+	})
+}
+
+// ActiveBroadcasts — рассылки в очереди и в работе, старые первыми.
+func (b *Bank) ActiveBroadcasts() ([]storage.Broadcast, error) {
+	var res []storage.Broadcast
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		res, err = tx.ActiveBroadcasts()
+		return err
+	})
+	return res, err
+}
+
+// BroadcastBatch берёт рассылку id в работу и возвращает до limit ещё не
+// доставленных сообщений. Когда доставлять больше некому, рассылка
+// завершается и список пуст.
+func (b *Bank) BroadcastBatch(id int64, limit int) (storage.Broadcast, []storage.Delivery, error) {
+	var job storage.Broadcast
+	var batch []storage.Delivery
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		if job, err = tx.LockBroadcast(id); err != nil || !job.Active() {
+			return err
+		}
+		if batch, err = tx.PendingDeliveries(id, limit); err != nil {
+			return err
+		}
+		job.Status = storage.BroadcastRunning
+		if len(batch) == 0 {
+			job.Status, job.FinishedAt = storage.BroadcastDone, b.Now()
+		}
+		return tx.UpdateBroadcast(job)
+	})
+	return job, batch, err
+}
+
+// RecordDelivery сохраняет итог доставки d: sent или blocked, любой другой
+// статус — неудачная попытка, после которой доставка остаётся pending до
+// MaxDeliveryAttempts неудач, а затем считается failed. Игрок со статусом
+// blocked помечается как заблокировавший бота.
+func (b *Bank) RecordDelivery(d storage.Delivery, status, errText string) (storage.Broadcast, error) {
+	var job storage.Broadcast
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		if job, err = tx.LockBroadcast(d.BroadcastID); err != nil {
+			return err
+		}
+		d.Status, d.Error, d.UpdatedAt = status, errText, b.Now()
+		if status != storage.DeliverySent && status != storage.DeliveryBlocked {
+			d.Attempts++
+			d.Status = storage.DeliveryPending
+			if d.Attempts >= MaxDeliveryAttempts {
+				d.Status = storage.DeliveryFailed
+			}
+		}
+		if err := tx.UpdateDelivery(d); err != nil {
+			return err
+		}
+		switch d.Status {
+		case storage.DeliverySent:
+			job.Sent++
+		case storage.DeliveryFailed:
+			job.Failed++
+		case storage.DeliveryBlocked:
+			job.Blocked++
+			if err := tx.SetBlockedBot(d.UserID, true); err != nil {
+				return err
+			}
+		}
+		return tx.UpdateBroadcast(job)
+	})
+	return job, err
+}
+
+// CancelBroadcast отменяет черновик или рассылку id, а при id = 0 —
+// последнюю активную. Уже отправленные сообщения остаются у игроков.
+func (b *Bank) CancelBroadcast(id int64) (storage.Broadcast, error) {
+	var job storage.Broadcast
+	err := b.tx(func(tx storage.Tx) error {
+		if id == 0 {
+			active, err := tx.ActiveBroadcasts()
+			if err != nil {
+				return err
+			}
+			if len(active) == 0 {
+				return ErrNoActiveBroadcast
+			}
+			id = active[len(active)-1].ID
+		}
+		var err error
+		if job, err = tx.LockBroadcast(id); err != nil {
+			return err
+		}
+		if !job.Active() && job.Status != storage.BroadcastDraft {
+			return ErrBroadcastClosed
+		}
+		job.Status, job.FinishedAt = storage.BroadcastCancelled, b.Now()
+		return tx.UpdateBroadcast(job)
+	})
+	return job, err
+}
@@ -0,0 +1,200 @@
+package bank
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"mybot/internal/storage"
+)
+
+// ComplaintCooldown — как часто игрок может отправлять жалобы.
+const ComplaintCooldown = 12 * time.Hour
+
+// MaxTicketText — максимальная длина жалобы или сообщения по ней в символах.
+const MaxTicketText = 2000
+
+var (
+	ErrComplaintEmpty    = errors.New("жалоба не может быть пустой")
+	ErrComplaintCooldown = errors.New("жалобу можно отправлять не чаще раза в 12 часов")
+	// ErrComplaintTooLong — текст длиннее MaxTicketText.
+	ErrComplaintTooLong = errors.New("сообщение слишком длинное")
+	// ErrTicketClosed — обращение уже решено или отклонено.
+	ErrTicketClosed = errors.New("обращение уже закрыто")
+	// ErrBadTicketStatus — закрыть обращение можно только как решённое или отклонённое.
+	ErrBadTicketStatus = errors.New("обращение закрывается как resolved или rejected")
+)
+
+// Ticket — обращение вместе с перепиской по нему.
+type Ticket struct {
+	storage.Complaint
+	Messages []storage.ComplaintMessage `json:"messages"`
+}
+
+// complaintWait — сколько игроку осталось ждать до следующей жалобы.
+func (b *Bank) complaintWait(tx storage.Tx, uid string) (time.Duration, error) {
+	last, err := tx.LastComplaintAt(uid)
+	if err != nil {
+		return 0, err
+	}
+	return last.Add(ComplaintCooldown).Sub(b.Now()), nil
+}
+
+func ticketText(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", ErrComplaintEmpty
+	}
+	if utf8.RuneCountInString(text) > MaxTicketText {
+		return "", ErrComplaintTooLong
+	}
+	return text, nil
+}
+
+// Complain открывает обращение игрока. Если с прошлой жалобы не прошло
+// ComplaintCooldown, возвращает ErrComplaintCooldown и оставшееся время.
+func (b *Bank) Complain(uid, nick, text string) (storage.Complaint, time.Duration, error) {
+	c := storage.Complaint{UserID: uid, Nick: nick, Status: storage.TicketOpen, CreatedAt: b.Now(), UpdatedAt: b.Now()}
+	var wait time.Duration
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		if wait, err = b.complaintWait(tx, uid); err != nil {
+			return err
+		}
+		if wait > 0 {
+			return ErrComplaintCooldown
+		}
+		if c.Text, err = ticketText(text); err != nil {
+			return err
+		}
+		return tx.InsertComplaint(&c)
+	})
+	return c, wait, err
+}
+
+// Tickets — обращения по фильтру, новые первыми.
+func (b *Bank) Tickets(f storage.ComplaintFilter) ([]storage.Complaint, error) {
+	var res []storage.Complaint
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		res, err = tx.Complaints(f)
+		return err
+	})
+	return res, err
+}
+
+// Ticket — обращение id с перепиской.
+func (b *Bank) Ticket(id int64) (Ticket, error) {
+	var t Ticket
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		if t.Complaint, err = tx.Complaint(id); err != nil {
+			return err
+		}
+		t.Messages, err = tx.ComplaintMessages(id)
+		return err
+	})
+	return t, err
+}
+
+// UserTickets — последние limit обращений игрока uid с перепиской для WebApp.
+func (b *Bank) UserTickets(uid string, limit int) ([]Ticket, error) {
+	var res []Ticket
+	err := b.tx(func(tx storage.Tx) error {
+		complaints, err := tx.Complaints(storage.ComplaintFilter{UserID: uid, Limit: limit})
+		if err != nil {
+			return err
+		}
+		for _, c := range complaints {
+			t := Ticket{Complaint: c}
+			if t.Messages, err = tx.ComplaintMessages(c.ID); err != nil {
+				return err
+			}
+			res = append(res, t)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// ReplyTicket добавляет сообщение в переписку по открытому обращению.
+// Игрок может писать только в свои обращения. Первый ответ администратора
+// берёт обращение в работу и назначает его ответившему, если исполнителя
+// ещё нет.
+func (b *Bank) ReplyTicket(id int64, authorID string, fromAdmin bool, text string) (storage.Complaint, error) {
+	var c storage.Complaint
+	text, err := ticketText(text)
+	if err != nil {
+		return c, err
+	}
+	err = b.tx(func(tx storage.Tx) error {
+		var err error
+		if c, err = tx.LockComplaint(id); err != nil {
+			return err
+		}
+		if !fromAdmin && c.UserID != authorID {
+			return storage.ErrNotFound
+		}
+		if c.Closed() {
+			return ErrTicketClosed
+		}
+		if fromAdmin {
+			c.Status = storage.TicketInProgress
+			if c.AssignedTo == "" {
+				c.AssignedTo = authorID
+			}
+		}
+		c.UpdatedAt = b.Now()
+		if err := tx.UpdateComplaint(c); err != nil {
+			return err
+		}
+		return tx.AddComplaintMessage(&storage.ComplaintMessage{
+			ComplaintID: id, AuthorID: authorID, FromAdmin: fromAdmin, Text: text, CreatedAt: b.Now(),
+		})
+	})
+	return c, err
+}
+
+// AssignTicket назначает открытое обращение администратору adminID и
+// берёт его в работу.
+func (b *Bank) AssignTicket(id int64, adminID string) (storage.Complaint, error) {
+	return b.updateTicket(id, func(c *storage.Complaint) error {
+		c.Status, c.AssignedTo = storage.TicketInProgress, adminID
+		return nil
+	})
+}
+
+// CloseTicket закрывает обращение как решённое или отклонённое. Если оно
+// уже закрыто, возвращает ErrTicketClosed и обращение в его состоянии.
+func (b *Bank) CloseTicket(id int64, adminID, status string) (storage.Complaint, error) {
+	if status != storage.TicketResolved && status != storage.TicketRejected {
+		return storage.Complaint{ID: id}, ErrBadTicketStatus
+	}
+	return b.updateTicket(id, func(c *storage.Complaint) error {
+		c.Status = status
+		if c.AssignedTo == "" {
+			c.AssignedTo = adminID
+		}
+		return nil
+	})
+}
+
+func (b *Bank) updateTicket(id int64, fn func(c *storage.Complaint) error) (storage.Complaint, error) {
+	var c storage.Complaint
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		if c, err = tx.LockComplaint(id); err != nil {
+			return err
+		}
+		if c.Closed() {
+			return ErrTicketClosed
+		}
+		if err := fn(&c); err != nil {
+			return err
+		}
+		c.UpdatedAt = b.Now()
+		return tx.UpdateComplaint(c)
+	})
+	return c, err
+}
@@ -0,0 +1,275 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+// Операции, за которые банк берёт комиссию.
+const (
+	FeeTransfer = "transfer"
+	FeeWithdraw = "withdraw"
+	FeeSellBond = "sell_bond"
+)
+
+// Ключи настроек комиссий. Тариф каждой операции хранится строкой
+// FeeSchedule, освобождённые роли — списком через запятую. Роли
+// администраторов и игроков освобождаются отдельными списками, чтобы игрок
+// не мог получить льготу администратора, выбрав роль с тем же названием.
+const (
+	SettingFeeTransfer        = "fee_transfer"
+	SettingFeeWithdraw        = "fee_withdraw"
+	SettingFeeSellBond        = "fee_sell_bond"
+	SettingFeeFreeRoles       = "fee_free_roles"
+	SettingFeeFreePlayerRoles = "fee_free_player_roles"
+)
+
+// FeeOps — операции с комиссией и настройки их тарифов.
+var FeeOps = map[string]string{
+	FeeTransfer: SettingFeeTransfer,
+	FeeWithdraw: SettingFeeWithdraw,
+	FeeSellBond: SettingFeeSellBond,
+}
+
+// ErrUnknownFeeOp — за такую операцию комиссия не берётся.
+var ErrUnknownFeeOp = errors.New("операция: transfer, withdraw или sell_bond")
+
+// FeeTier — ступень тарифа: для сумм от From комиссия Flat GOLD или Pct
+// процентов.
+type FeeTier struct {
+	From money.Money
+	Flat money.Money
+	Pct  float64
+}
+
+// FeeSchedule — тариф операции, ступени по возрастанию From. Пустой тариф —
+// без комиссии.
+type FeeSchedule []FeeTier
+
+// ParseFeeSchedule разбирает тариф: "0" — без комиссии, "5" — 5 GOLD,
+// "1.5%" — процент от суммы, "0:1%,1000:0.5%,10000:25" — ступени по сумме
+// операции.
+func ParseFeeSchedule(s string) (FeeSchedule, error) {
+	var res FeeSchedule
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var t FeeTier
+		from, fee, tiered := strings.Cut(part, ":")
+		if !tiered {
+			fee = from
+		} else {
+			v, err := money.Parse(from)
+			if err != nil || v < 0 || v > MaxAmount {
+				return nil, fmt.Errorf("%w: порог %q", ErrBadSetting, from)
+			}
+			t.From = v
+		}
+		if pct, ok := strings.CutSuffix(strings.TrimSpace(fee), "%"); ok {
+			v, err := strconv.ParseFloat(pct, 64)
+			if err != nil || !validPercent(v) {
+				return nil, fmt.Errorf("%w: процент %q", ErrBadSetting, fee)
+			}
+			t.Pct = v
+		} else {
+			v, err := money.Parse(fee)
+			if err != nil || v < 0 || v > MaxAmount {
+				return nil, fmt.Errorf("%w: комиссия %q", ErrBadSetting, fee)
+			}
+			t.Flat = v
+		}
+		if t.Flat > 0 || t.Pct > 0 || tiered {
+			res = append(res, t)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].From < res[j].From })
+	for i := 1; i < len(res); i++ {
+		if res[i].From == res[i-1].From {
+			return nil, fmt.Errorf("%w: порог %s повторяется", ErrBadSetting, res[i].From)
+		}
+	}
+	return res, nil
+}
+
+// Fee — комиссия за операцию на сумму amount по ступени с наибольшим
+// порогом, не превышающим amount.
+func (s FeeSchedule) Fee(amount money.Money) money.Money {
+	var fee money.Money
+	for _, t := range s {
+		if amount < t.From {
+			break
+		}
+		fee = t.Flat + amount.Percent(t.Pct)
+	}
+	return fee
+}
+
+func (s FeeSchedule) String() string {
+	if len(s) == 0 {
+		return "без комиссии"
+	}
+	parts := make([]string, len(s))
+	for i, t := range s {
+		fee := t.Flat.String() + " GOLD"
+		if t.Pct > 0 {
+			fee = strconv.FormatFloat(t.Pct, 'g', -1, 64) + "%"
+		}
+		parts[i] = fee
+		if len(s) > 1 {
+			parts[i] = fmt.Sprintf("от %s: %s", t.From, fee)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func checkFeeSchedule(v string) error {
+	_, err := ParseFeeSchedule(v)
+	return err
+}
+
+// parseRoles — роли из списка через запятую.
+func parseRoles(v string) map[string]bool {
+	res := map[string]bool{}
+	for _, r := range strings.Split(v, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			res[r] = true
+		}
+	}
+	return res
+}
+
+// Fees — тарифы всех операций и роли, освобождённые от комиссий.
+type Fees struct {
+	Schedules map[string]FeeSchedule
+	// FreeRoles — роли администраторов, FreePlayerRoles — роли игроков.
+	FreeRoles, FreePlayerRoles []string
+}
+
+// sortedRoles — роли из настройки key по алфавиту.
+func sortedRoles(tx storage.Tx, key string) ([]string, error) {
+	v, err := setting(tx, key)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for r := range parseRoles(v) {
+		res = append(res, r)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// Fees возвращает текущие тарифы комиссий.
+func (b *Bank) Fees() (Fees, error) {
+	f := Fees{Schedules: map[string]FeeSchedule{}}
+	err := b.tx(func(tx storage.Tx) error {
+		for op, key := range FeeOps {
+			s, err := feeSchedule(tx, key)
+			if err != nil {
+				return err
+			}
+			f.Schedules[op] = s
+		}
+		var err error
+		if f.FreeRoles, err = sortedRoles(tx, SettingFeeFreeRoles); err != nil {
+			return err
+		}
+		f.FreePlayerRoles, err = sortedRoles(tx, SettingFeeFreePlayerRoles)
+		return err
+	})
+	return f, err
+}
+
+// SetFee меняет тариф операции op от имени adminID.
+func (b *Bank) SetFee(adminID, op, schedule string) error {
+	key, ok := FeeOps[op]
+	if !ok {
+		return ErrUnknownFeeOp
+	}
+	return b.SetSetting(adminID, key, schedule)
+}
+
+// FeeQuote — расчёт комиссии, который игрок видит до подтверждения операции.
+type FeeQuote struct {
+	Op     string      `json:"op"`
+	Amount money.Money `json:"amount"`
+	Fee    money.Money `json:"fee"`
+	// Total — сколько спишется с баланса при переводе и выводе или сколько
+	// будет зачислено при продаже вклада.
+	Total money.Money `json:"total"`
+}
+
+// QuoteFee — комиссия, которую заплатит uid за операцию op на сумму amount.
+func (b *Bank) QuoteFee(uid, op string, amount money.Money) (FeeQuote, error) {
+	q := FeeQuote{Op: op, Amount: amount}
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		q.Fee, err = b.fee(tx, uid, op, amount)
+		return err
+	})
+	q.Total = amount + q.Fee
+	if op == FeeSellBond {
+		q.Fee = min(q.Fee, amount)
+		q.Total = amount - q.Fee
+	}
+	return q, err
+}
+
+// fee считает комиссию внутри транзакции tx. Администраторы с ролью из
+// SettingFeeFreeRoles и игроки с ролью из SettingFeeFreePlayerRoles
+// комиссию не платят.
+func (b *Bank) fee(tx storage.Tx, uid, op string, amount money.Money) (money.Money, error) {
+	key, ok := FeeOps[op]
+	if !ok {
+		return 0, ErrUnknownFeeOp
+	}
+	roles, err := setting(tx, SettingFeeFreeRoles)
+	if err != nil {
+		return 0, err
+	}
+	if free := parseRoles(roles); len(free) > 0 {
+		a, err := tx.Admin(uid)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return 0, err
+		}
+		if b.owners[uid] {
+			a.Role = RoleOwner
+		}
+		if a.Role != "" && free[a.Role] {
+			return 0, nil
+		}
+	}
+	if roles, err = setting(tx, SettingFeeFreePlayerRoles); err != nil {
+		return 0, err
+	}
+	if free := parseRoles(roles); len(free) > 0 {
+		u, err := tx.User(uid)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return 0, err
+		}
+		if u.Role != "" && free[u.Role] {
+			return 0, nil
+		}
+	}
+	s, err := feeSchedule(tx, key)
+	if err != nil {
+		return 0, err
+	}
+	return s.Fee(amount), nil
+}
+
+func feeSchedule(tx storage.Tx, key string) (FeeSchedule, error) {
+	v, err := setting(tx, key)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFeeSchedule(v)
+}
@@ -0,0 +1,40 @@
+package bank
+
+import (
+	"mybot/internal/storage"
+)
+
+// HistoryMaxLimit — сколько операций максимум отдаётся за один запрос.
+const HistoryMaxLimit = 100
+
+var accountTitles = map[string]string{
+	storage.AccountExternal: "Администрация",
+	storage.AccountBonds:    "Вклады",
+	storage.AccountTreasury: "Казна банка",
+	storage.AccountInterest: "Проценты",
+	storage.AccountFees:     "Комиссия банка",
+}
+
+// History возвращает операции по счёту игрока от новых к старым.
+// Контрагент — ник другого игрока или название системного счёта.
+func (b *Bank) History(uid string, f storage.HistoryFilter) ([]storage.HistoryItem, error) {
+	if f.Limit <= 0 || f.Limit > HistoryMaxLimit {
+		f.Limit = HistoryMaxLimit
+	}
+	var res []storage.HistoryItem
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		res, err = tx.History(uid, f)
+		return err
+	})
+	for i := range res {
+		h := &res[i]
+		switch {
+		case h.CounterpartyNick != "":
+			h.Counterparty = h.CounterpartyNick
+		case accountTitles[h.Counterparty] != "":
+			h.Counterparty = accountTitles[h.Counterparty]
+		}
+	}
+	return res, err
+}
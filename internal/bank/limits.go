@@ -0,0 +1,289 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+// Виды ограничений игрока.
+const (
+	// LimitTransfer — наибольшая сумма одного перевода.
+	LimitTransfer = "transfer"
+	// LimitDaily — сколько игрок может отправить переводами и выводами за сутки.
+	LimitDaily = "daily"
+	// LimitPending — общая сумма ожидающих заявок на вывод.
+	LimitPending = "pending"
+)
+
+// LimitNames — все виды ограничений в порядке показа.
+var LimitNames = []string{LimitTransfer, LimitDaily, LimitPending}
+
+// ScopeAll — область ограничений для всех игроков. Ограничения роли
+// ("role:<роль>") важнее общих, а ограничения игрока ("user:<ID>") — важнее
+// ограничений роли.
+const ScopeAll = "all"
+
+var (
+	// ErrUnknownLimit — такого вида ограничений нет.
+	ErrUnknownLimit = errors.New("ограничение: transfer, daily или pending")
+	// ErrBadLimitScope — область ограничения не all, role:<роль> и не ID игрока.
+	ErrBadLimitScope = errors.New("кому: all, role:<роль> или ID игрока")
+)
+
+// RoleScope — область ограничений игроков с ролью role.
+func RoleScope(role string) string { return "role:" + role }
+
+// UserScope — область ограничений игрока uid.
+func UserScope(uid string) string { return "user:" + uid }
+
+// ParseLimitScope разбирает, кому ставится ограничение: all, role:<роль>
+// или ID игрока.
+func ParseLimitScope(s string) (string, error) {
+	if s == ScopeAll {
+		return s, nil
+	}
+	if role, ok := strings.CutPrefix(s, "role:"); ok && strings.TrimSpace(role) != "" {
+		return s, nil
+	}
+	if CheckUserID(s) == nil {
+		return UserScope(s), nil
+	}
+	return "", ErrBadLimitScope
+}
+
+func isLimit(name string) bool {
+	for _, n := range LimitNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LimitBreach — какое ограничение превысит операция.
+type LimitBreach struct {
+	Name  string
+	Limit money.Money
+	// Used — сколько уже израсходовано до операции.
+	Used money.Money
+}
+
+var limitTitles = map[string]string{
+	LimitTransfer: "на один перевод",
+	LimitDaily:    "на сутки",
+	LimitPending:  "на ожидающие выводы",
+}
+
+func (l LimitBreach) String() string {
+	if l.Name == LimitTransfer {
+		return fmt.Sprintf("лимит %s: %s GOLD", limitTitles[l.Name], l.Limit)
+	}
+	return fmt.Sprintf("лимит %s: %s GOLD, уже %s GOLD", limitTitles[l.Name], l.Limit, l.Used)
+}
+
+// Allowance — ограничение игрока и сколько по нему ещё можно потратить.
+type Allowance struct {
+	Name      string      `json:"name"`
+	Limit     money.Money `json:"limit"`
+	Remaining money.Money `json:"remaining"`
+}
+
+// limits — действующие ограничения игрока uid: ограничение игрока важнее
+// ограничения его роли, а то — общего. Нет ограничения — нет и лимита.
+func limits(tx storage.Tx, uid string) (map[string]money.Money, error) {
+	scopes := []string{ScopeAll}
+	u, err := tx.User(uid)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	if u.Role != "" {
+		scopes = append(scopes, RoleScope(u.Role))
+	}
+	scopes = append(scopes, UserScope(uid))
+	all, err := tx.Limits(scopes...)
+	if err != nil {
+		return nil, err
+	}
+	rank := func(scope string) int {
+		for i, s := range scopes {
+			if s == scope {
+				return i
+			}
+		}
+		return -1
+	}
+	res := map[string]money.Money{}
+	best := map[string]int{}
+	for _, l := range all {
+		if r, ok := best[l.Name]; !ok || rank(l.Scope) > r {
+			res[l.Name], best[l.Name] = l.Value, rank(l.Scope)
+		}
+	}
+	return res, nil
+}
+
+// usage — сколько игрок уже израсходовал по каждому ограничению.
+func (b *Bank) usage(tx storage.Tx, uid string) (map[string]money.Money, error) {
+	daily, err := tx.Outflow(uid, b.Now().Add(-24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	requests, err := tx.PendingRequests(uid)
+	if err != nil {
+		return nil, err
+	}
+	var pending money.Money
+	for _, r := range requests {
+		if r.Kind == storage.RequestWithdraw {
+			pending += r.Amount
+		}
+	}
+	return map[string]money.Money{LimitDaily: daily, LimitPending: pending}, nil
+}
+
+// checkLimits проверяет перевод (FeeTransfer) или вывод (FeeWithdraw) на
+// сумму amount; nil, если ограничения не нарушены.
+func (b *Bank) checkLimits(tx storage.Tx, uid, op string, amount money.Money) (*LimitBreach, error) {
+	lim, err := limits(tx, uid)
+	if err != nil || len(lim) == 0 {
+		return nil, err
+	}
+	used, err := b.usage(tx, uid)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{LimitTransfer, LimitDaily}
+	if op == FeeWithdraw {
+		names = []string{LimitDaily, LimitPending}
+	}
+	for _, name := range names {
+		v, ok := lim[name]
+		if ok && used[name]+amount > v {
+			return &LimitBreach{Name: name, Limit: v, Used: used[name]}, nil
+		}
+	}
+	return nil, nil
+}
+
+// CheckLimits проверяет, не превысит ли перевод или вывод uid на сумму
+// amount его ограничения.
+func (b *Bank) CheckLimits(uid, op string, amount money.Money) (*LimitBreach, error) {
+	var res *LimitBreach
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		res, err = b.checkLimits(tx, uid, op, amount)
+		return err
+	})
+	return res, err
+}
+
+// Allowances — ограничения игрока uid и остаток по каждому из них.
+func (b *Bank) Allowances(uid string) ([]Allowance, error) {
+	var res []Allowance
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		res, err = b.allowances(tx, uid)
+		return err
+	})
+	return res, err
+}
+
+func (b *Bank) allowances(tx storage.Tx, uid string) ([]Allowance, error) {
+	lim, err := limits(tx, uid)
+	if err != nil || len(lim) == 0 {
+		return nil, err
+	}
+	used, err := b.usage(tx, uid)
+	if err != nil {
+		return nil, err
+	}
+	var res []Allowance
+	for _, name := range LimitNames {
+		if v, ok := lim[name]; ok {
+			res = append(res, Allowance{Name: name, Limit: v, Remaining: max(v-used[name], 0)})
+		}
+	}
+	return res, nil
+}
+
+// Limits — ограничения с областью scope (all, role:<роль> или ID игрока).
+func (b *Bank) Limits(scope string) ([]storage.Limit, error) {
+	scope, err := ParseLimitScope(scope)
+	if err != nil {
+		return nil, err
+	}
+	var res []storage.Limit
+	err = b.tx(func(tx storage.Tx) error {
+		var err error
+		res, err = tx.Limits(scope)
+		return err
+	})
+	return res, err
+}
+
+// SetLimit ставит ограничение name для scope от имени adminID.
+func (b *Bank) SetLimit(adminID, scope, name string, value money.Money) error {
+	scope, err := ParseLimitScope(scope)
+	if err != nil {
+		return err
+	}
+	if !isLimit(name) {
+		return ErrUnknownLimit
+	}
+	if err := CheckAmount(value); err != nil {
+		return err
+	}
+	return b.tx(func(tx storage.Tx) error {
+		return tx.SetLimit(storage.Limit{Scope: scope, Name: name, Value: value, UpdatedBy: adminID, UpdatedAt: b.Now()})
+	})
+}
+
+// DeleteLimit снимает ограничение name для scope.
+func (b *Bank) DeleteLimit(scope, name string) (bool, error) {
+	scope, err := ParseLimitScope(scope)
+	if err != nil {
+		return false, err
+	}
+	if !isLimit(name) {
+		return false, ErrUnknownLimit
+	}
+	var removed bool
+	err = b.tx(func(tx storage.Tx) error {
+		var err error
+		removed, err = tx.DeleteLimit(scope, name)
+		return err
+	})
+	return removed, err
+}
+
+// DecideTransfer применяет решение администратора по переводу, который
+// превысил лимит. Как и DecideRequest, при нехватке средств у отправителя
+// перевод остаётся на проверке.
+func (b *Bank) DecideTransfer(id int64, adminID string, approve bool) (Transfer, error) {
+	res := Transfer{ID: id}
+	err := b.tx(func(tx storage.Tx) error {
+		to := storage.StatusRejected
+		if approve {
+			to = storage.StatusApproved
+		}
+		p, err := tx.ClosePendingTransfer(id, "", storage.StatusReview, to, b.Now())
+		res.FromID, res.ToID, res.Amount, res.Fee, res.Comment = p.FromID, p.ToID, p.Amount, p.Fee, p.Comment
+		if err != nil || !approve {
+			return err
+		}
+		receiver, err := recipient(tx, p.FromID, p.ToID)
+		if err != nil {
+			return err
+		}
+		t, err := b.transfer(tx, p.FromID, receiver, p.Amount, p.Fee, p.Comment)
+		t.ID = id
+		res = t
+		return err
+	})
+	return res, err
+}
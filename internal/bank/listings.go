@@ -0,0 +1,207 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+var (
+	// ErrAlreadyListed — у вклада уже есть открытое объявление.
+	ErrAlreadyListed = errors.New("вклад уже выставлен на продажу")
+	// ErrOwnListing — нельзя купить собственный вклад.
+	ErrOwnListing = errors.New("нельзя купить собственный вклад")
+)
+
+// ListingView — объявление вторичного рынка с вкладом и ником продавца.
+type ListingView struct {
+	storage.Listing
+	Bond       BondView `json:"bond"`
+	SellerNick string   `json:"seller_nick"`
+	// Own — объявление игрока, который запрашивает список.
+	Own bool `json:"own"`
+}
+
+// Trade — состоявшаяся сделка вторичного рынка.
+type Trade struct {
+	Listing storage.Listing
+	Bond    storage.Bond
+	// Fee — комиссия банка, удержанная из цены; продавец получил Price − Fee.
+	Fee        money.Money
+	BuyerNick  string
+	SellerNick string
+}
+
+// ListBond выставляет вклад игрока на продажу другим игрокам. Заблокированный
+// вклад можно выставить, только если это разрешено настройкой
+// SettingMarketAllowLocked.
+func (b *Bank) ListBond(uid string, bondID int, price money.Money) (storage.Listing, error) {
+	l := storage.Listing{BondID: bondID, SellerID: uid, Price: price, Status: storage.ListingOpen}
+	if err := CheckAmount(price); err != nil {
+		return l, err
+	}
+	err := b.tx(func(tx storage.Tx) error {
+		bond, err := tx.LockBond(bondID, uid)
+		if err != nil {
+			return err
+		}
+		if !bond.CanWithdraw && !b.matured(bond) {
+			allow, err := settingBool(tx, SettingMarketAllowLocked)
+			if err != nil {
+				return err
+			}
+			if !allow {
+				return ErrBondLocked
+			}
+		}
+		if _, err := tx.OpenListing(bondID); err == nil {
+			return ErrAlreadyListed
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		l.CreatedAt = b.Now()
+		return tx.CreateListing(&l)
+	})
+	return l, err
+}
+
+// CancelListing снимает объявление игрока с продажи.
+func (b *Bank) CancelListing(uid string, id int64) (storage.Listing, error) {
+	var l storage.Listing
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		l, err = tx.LockListing(id)
+		if err != nil {
+			return err
+		}
+		if l.SellerID != uid {
+			return storage.ErrNotFound
+		}
+		return tx.CloseListing(id, storage.ListingCancelled, "", b.Now())
+	})
+	return l, err
+}
+
+// BuyListing покупает вклад по объявлению: цена списывается с покупателя,
+// продавец получает её за вычетом комиссии SettingMarketFeePct, вклад
+// переходит покупателю — всё в одной транзакции. Вклад блокируется раньше
+// объявления, в том же порядке, что и при его закрытии или снятии, а
+// объявление после этого проверяется заново.
+func (b *Bank) BuyListing(buyerID string, id int64) (Trade, error) {
+	var t Trade
+	err := b.tx(func(tx storage.Tx) error {
+		l, err := tx.Listing(id)
+		if err != nil {
+			return err
+		}
+		if l.Status != storage.ListingOpen {
+			return storage.ErrListingClosed
+		}
+		if l.SellerID == buyerID {
+			return ErrOwnListing
+		}
+		bond, err := tx.LockBond(l.BondID, l.SellerID)
+		if err != nil {
+			return err
+		}
+		if l, err = tx.LockListing(id); err != nil {
+			return err
+		}
+		if l.Status != storage.ListingOpen {
+			return storage.ErrListingClosed
+		}
+		if !bond.CanWithdraw && !b.matured(bond) {
+			// Настройку могли выключить, пока объявление висело на рынке.
+			allow, err := settingBool(tx, SettingMarketAllowLocked)
+			if err != nil {
+				return err
+			}
+			if !allow {
+				return ErrBondLocked
+			}
+		}
+		feePct, err := settingFloat(tx, SettingMarketFeePct)
+		if err != nil {
+			return err
+		}
+		fee := l.Price.Percent(feePct)
+
+		if _, err := b.post(tx, storage.Transaction{
+			Kind:      storage.TxBondTrade,
+			Initiator: buyerID,
+			Memo:      fmt.Sprintf("%s #%d", bond.Name, bond.ID),
+			Entries: []storage.Entry{
+				{Account: buyerID, Amount: -l.Price},
+				{Account: l.SellerID, Amount: l.Price - fee},
+				{Account: storage.AccountTreasury, Amount: fee},
+			},
+		}); err != nil {
+			return err
+		}
+		if err := tx.TransferBond(bond.ID, buyerID); err != nil {
+			return err
+		}
+		if err := tx.CloseListing(id, storage.ListingSold, buyerID, b.Now()); err != nil {
+			return err
+		}
+
+		l.Status, l.BuyerID = storage.ListingSold, buyerID
+		bond.UserID = buyerID
+		t = Trade{Listing: l, Bond: bond, Fee: fee}
+		if u, err := tx.User(buyerID); err == nil {
+			t.BuyerNick = u.Nick
+		}
+		if u, err := tx.User(l.SellerID); err == nil {
+			t.SellerNick = u.Nick
+		}
+		return nil
+	})
+	return t, err
+}
+
+// Listings — открытые объявления вторичного рынка; объявления uid помечены Own.
+func (b *Bank) Listings(uid string) ([]ListingView, error) {
+	var res []ListingView
+	err := b.tx(func(tx storage.Tx) error {
+		listings, err := tx.OpenListings()
+		if err != nil || len(listings) == 0 {
+			return err
+		}
+		all, err := tx.AllBonds()
+		if err != nil {
+			return err
+		}
+		bonds := make(map[int]storage.Bond, len(all))
+		for _, bond := range all {
+			bonds[bond.ID] = bond
+		}
+		for _, l := range listings {
+			bond, ok := bonds[l.BondID]
+			if !ok || bond.UserID != l.SellerID {
+				// Вклад закрыт или уже сменил владельца — объявление не актуально.
+				continue
+			}
+			v := ListingView{Listing: l, Bond: b.view(bond), Own: l.SellerID == uid}
+			if u, err := tx.User(l.SellerID); err == nil {
+				v.SellerNick = u.Nick
+			}
+			res = append(res, v)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// closeListings снимает открытое объявление по вкладу, который закрывается.
+func (b *Bank) closeListings(tx storage.Tx, bondID int) error {
+	l, err := tx.OpenListing(bondID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return tx.CloseListing(l.ID, storage.ListingCancelled, "", b.Now())
+}
@@ -0,0 +1,287 @@
+package bank
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+var (
+	// ErrSubscriptionClosed — покупка вне окна подписки облигации.
+	ErrSubscriptionClosed = errors.New("подписка на облигацию закрыта")
+	// ErrSupplyExhausted — покупка превысила бы общий объём выпуска.
+	ErrSupplyExhausted = errors.New("превышен объём выпуска облигации")
+	// ErrHoldingLimit — покупка превысила бы лимит облигации на одного игрока.
+	ErrHoldingLimit = errors.New("превышен лимит облигации на игрока")
+	// ErrNotOnSale — облигация приостановлена или снята с рынка.
+	ErrNotOnSale = errors.New("облигация сейчас не продаётся")
+	// ErrProductRetired — снятую с рынка облигацию нельзя менять.
+	ErrProductRetired = errors.New("облигация снята с рынка")
+)
+
+// MarketItem — облигация на рынке с остатком выпуска для игрока.
+type MarketItem struct {
+	storage.Product
+	// Remaining — сколько ещё осталось в выпуске, UserRemaining — сколько
+	// ещё может купить этот игрок; null — без ограничения.
+	Remaining     *money.Money `json:"remaining"`
+	UserRemaining *money.Money `json:"user_remaining"`
+	Open          bool         `json:"open"`
+	OpensAt       string       `json:"opens_at,omitempty"`
+	ClosesAt      string       `json:"closes_at,omitempty"`
+}
+
+// subscriptionOpen — можно ли сейчас покупать облигацию.
+func (b *Bank) subscriptionOpen(p storage.Product) bool {
+	now := b.Now()
+	return p.Status == storage.ProductActive &&
+		(p.OpensAt.IsZero() || !now.Before(p.OpensAt)) && (p.ClosesAt.IsZero() || now.Before(p.ClosesAt))
+}
+
+// capacity — сколько ещё можно купить с учётом лимита limit, из которого
+// уже занято used; nil — лимита нет.
+func capacity(limit, used money.Money) *money.Money {
+	if limit <= 0 {
+		return nil
+	}
+	left := max(limit-used, 0)
+	return &left
+}
+
+// marketItems дополняет облигации остатками выпуска; лимит на игрока
+// считается, только если uid не пустой.
+func (b *Bank) marketItems(tx storage.Tx, products []storage.Product, uid string) ([]MarketItem, error) {
+	var res []MarketItem
+	for _, p := range products {
+		it := MarketItem{Product: p, Remaining: capacity(p.MaxSupply, p.Issued), Open: b.subscriptionOpen(p)}
+		if p.PerUserMax > 0 && uid != "" {
+			held, err := tx.Holdings(uid, p.ID)
+			if err != nil {
+				return nil, err
+			}
+			it.UserRemaining = capacity(p.PerUserMax, held)
+		}
+		if !p.OpensAt.IsZero() {
+			it.OpensAt = p.OpensAt.Format("02.01.2006 15:04")
+		}
+		if !p.ClosesAt.IsZero() {
+			it.ClosesAt = p.ClosesAt.Format("02.01.2006 15:04")
+		}
+		res = append(res, it)
+	}
+	return res, nil
+}
+
+// Market — облигации на рынке с остатками выпуска для игрока uid. Снятые с
+// рынка не показываются, приостановленные — с Open = false.
+func (b *Bank) Market(uid string) ([]MarketItem, error) {
+	var res []MarketItem
+	err := b.tx(func(tx storage.Tx) error {
+		products, err := tx.Products(false)
+		if err != nil {
+			return err
+		}
+		res, err = b.marketItems(tx, products, uid)
+		return err
+	})
+	return res, err
+}
+
+// AllProducts — все облигации, включая снятые с рынка, для администрации.
+func (b *Bank) AllProducts() ([]MarketItem, error) {
+	var res []MarketItem
+	err := b.tx(func(tx storage.Tx) error {
+		products, err := tx.Products(true)
+		if err != nil {
+			return err
+		}
+		res, err = b.marketItems(tx, products, "")
+		return err
+	})
+	return res, err
+}
+
+var (
+	// ErrBadTerm — срок облигации не может быть отрицательным.
+	ErrBadTerm = errors.New("срок должен быть неотрицательным числом дней")
+	// ErrBadModel — неизвестная модель начисления процентов.
+	ErrBadModel = errors.New("модель процентов: daily, simple, monthly или coupon")
+	// ErrBadEarlyPolicy — неизвестные условия досрочного закрытия или штраф
+	// вне диапазона 0–100%.
+	ErrBadEarlyPolicy = errors.New("досрочное закрытие: forbidden, forfeit или penalty:0–100")
+	// ErrBadLimits — отрицательный лимит выпуска или пустое окно подписки.
+	ErrBadLimits = errors.New("лимиты выпуска должны быть неотрицательными, а окно подписки — непустым")
+	// ErrBadProduct — пустое название, цена вне 0–MaxAmount, процент вне
+	// 0–100, неизвестное состояние.
+	ErrBadProduct = errors.New("название не может быть пустым, цена — отрицательной, процент — вне 0–100")
+)
+
+// validateProduct проверяет условия облигации и подставляет значения по
+// умолчанию для пустых полей.
+func validateProduct(p *storage.Product) error {
+	if p.Name == "" || p.Price < 0 || p.Price > MaxAmount || !validPercent(p.Rate) {
+		return ErrBadProduct
+	}
+	if p.TermDays < 0 {
+		return ErrBadTerm
+	}
+	switch p.InterestModel {
+	case "":
+		p.InterestModel = storage.InterestDaily
+	case storage.InterestDaily, storage.InterestSimple, storage.InterestMonthly, storage.InterestCoupon:
+	default:
+		return ErrBadModel
+	}
+	switch p.EarlyPolicy {
+	case "":
+		p.EarlyPolicy = storage.EarlyForbidden
+	case storage.EarlyForbidden, storage.EarlyForfeit:
+	case storage.EarlyPenalty:
+		if !validPercent(p.PenaltyPct) {
+			return ErrBadEarlyPolicy
+		}
+	default:
+		return ErrBadEarlyPolicy
+	}
+	if p.EarlyPolicy != storage.EarlyPenalty {
+		p.PenaltyPct = 0
+	}
+	if p.MaxSupply < 0 || p.PerUserMax < 0 || p.MaxSupply > MaxAmount || p.PerUserMax > MaxAmount || !p.OpensAt.IsZero() && !p.ClosesAt.IsZero() && !p.OpensAt.Before(p.ClosesAt) {
+		return ErrBadLimits
+	}
+	switch p.Status {
+	case storage.ProductActive, storage.ProductPaused, storage.ProductRetired:
+	default:
+		return ErrBadProduct
+	}
+	return nil
+}
+
+// CreateProduct выставляет облигацию на рынок и записывает это в журнал
+// изменений от имени adminID.
+func (b *Bank) CreateProduct(adminID string, p storage.Product) (storage.Product, error) {
+	p.Status = storage.ProductActive
+	if err := validateProduct(&p); err != nil {
+		return p, err
+	}
+	err := b.tx(func(tx storage.Tx) error {
+		if err := tx.CreateProduct(&p); err != nil {
+			return err
+		}
+		return tx.InsertProductChange(&storage.ProductChange{
+			ProductID: p.ID, AdminID: adminID, Field: "created", NewValue: p.Name, CreatedAt: b.Now(),
+		})
+	})
+	return p, err
+}
+
+// ProductEdit — изменение одного поля облигации, записанное в журнал.
+type ProductEdit struct {
+	Field, Old, New string
+}
+
+// EditProduct меняет условия облигации id функцией edit под блокировкой
+// строки и записывает каждое изменённое поле в журнал. Открытые вклады
+// сохраняют условия, на которых были куплены.
+func (b *Bank) EditProduct(adminID string, id int, edit func(p *storage.Product) error) (storage.Product, []ProductEdit, error) {
+	var p storage.Product
+	var edits []ProductEdit
+	err := b.tx(func(tx storage.Tx) error {
+		before, err := tx.LockProduct(id)
+		if err != nil {
+			return err
+		}
+		if before.Status == storage.ProductRetired {
+			return ErrProductRetired
+		}
+		p = before
+		if err := edit(&p); err != nil {
+			return err
+		}
+		if err := validateProduct(&p); err != nil {
+			return err
+		}
+		edits = diffProducts(before, p)
+		if len(edits) == 0 {
+			return nil
+		}
+		if err := tx.UpdateProduct(p); err != nil {
+			return err
+		}
+		return b.logEdits(tx, adminID, id, edits)
+	})
+	return p, edits, err
+}
+
+// SetProductStatus приостанавливает, возобновляет или снимает облигацию с
+// рынка. Снятую с рынка вернуть нельзя.
+func (b *Bank) SetProductStatus(adminID string, id int, status string) (storage.Product, error) {
+	p, _, err := b.EditProduct(adminID, id, func(p *storage.Product) error {
+		p.Status = status
+		return nil
+	})
+	return p, err
+}
+
+// ProductChanges — последние limit изменений облигации.
+func (b *Bank) ProductChanges(id, limit int) ([]storage.ProductChange, error) {
+	var res []storage.ProductChange
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		res, err = tx.ProductChanges(id, limit)
+		return err
+	})
+	return res, err
+}
+
+func (b *Bank) logEdits(tx storage.Tx, adminID string, id int, edits []ProductEdit) error {
+	for _, e := range edits {
+		if err := tx.InsertProductChange(&storage.ProductChange{
+			ProductID: id, AdminID: adminID, Field: e.Field, OldValue: e.Old, NewValue: e.New, CreatedAt: b.Now(),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffProducts — поля, которые различаются у двух версий облигации.
+func diffProducts(before, after storage.Product) []ProductEdit {
+	old, cur := productValues(before), productValues(after)
+	var res []ProductEdit
+	for i := range old {
+		if old[i][1] != cur[i][1] {
+			res = append(res, ProductEdit{Field: old[i][0], Old: old[i][1], New: cur[i][1]})
+		}
+	}
+	return res
+}
+
+// productValues — изменяемые поля облигации в виде пар (поле, значение) для
+// журнала изменений.
+func productValues(p storage.Product) [][2]string {
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("02.01.2006 15:04")
+	}
+	return [][2]string{
+		{"name", p.Name},
+		{"price", p.Price.String()},
+		{"rate", strconv.FormatFloat(p.Rate, 'g', -1, 64)},
+		{"term_days", strconv.Itoa(p.TermDays)},
+		{"auto_redeem", strconv.FormatBool(p.AutoRedeem)},
+		{"interest_model", p.InterestModel},
+		{"early_policy", p.EarlyPolicy},
+		{"penalty_pct", strconv.FormatFloat(p.PenaltyPct, 'g', -1, 64)},
+		{"max_supply", p.MaxSupply.String()},
+		{"per_user_max", p.PerUserMax.String()},
+		{"opens_at", date(p.OpensAt)},
+		{"closes_at", date(p.ClosesAt)},
+		{"status", p.Status},
+	}
+}
@@ -0,0 +1,102 @@
+package bank
+
+import (
+	"fmt"
+	"time"
+
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+// RequestTTL — через сколько необработанная заявка истекает.
+const RequestTTL = 72 * time.Hour
+
+// RequestMoney создаёт заявку игрока на вывод или пополнение. Комиссия за
+// вывод считается сразу и фиксируется в заявке.
+func (b *Bank) RequestMoney(uid, kind string, amount money.Money) (storage.MoneyRequest, error) {
+	r := storage.MoneyRequest{UserID: uid, Kind: kind, Amount: amount, Status: storage.StatusPending, CreatedAt: b.Now()}
+	if err := CheckAmount(amount); err != nil {
+		return r, err
+	}
+	err := b.tx(func(tx storage.Tx) error {
+		if kind == storage.RequestWithdraw {
+			var err error
+			if r.Fee, err = b.fee(tx, uid, FeeWithdraw, amount); err != nil {
+				return err
+			}
+		}
+		return tx.CreateRequest(&r)
+	})
+	return r, err
+}
+
+// DecideRequest применяет решение администратора по заявке. Одобрение
+// проводит деньги в той же транзакции, что и смена состояния, поэтому из
+// двух одновременных решений применится только одно; второе получит
+// storage.ErrRequestClosed и заявку в её фактическом состоянии. Если у
+// игрока не хватает средств на вывод, заявка остаётся в ожидании. Выведенные
+// деньги уходят в казну, пополнения зачисляются из неё.
+func (b *Bank) DecideRequest(id int64, adminID string, approve bool) (storage.MoneyRequest, error) {
+	var r storage.MoneyRequest
+	err := b.tx(func(tx storage.Tx) error {
+		to := storage.StatusRejected
+		if approve {
+			to = storage.StatusApproved
+		}
+		var err error
+		r, err = tx.CloseRequest(id, "", to, adminID, b.Now())
+		if err != nil || !approve {
+			return err
+		}
+
+		t := storage.Transaction{
+			Kind:      storage.TxWithdraw,
+			Initiator: adminID,
+			Memo:      fmt.Sprintf("Заявка #%d", id),
+			Entries:   withFee(transferEntries(r.UserID, storage.AccountTreasury, r.Amount), r.UserID, r.Fee),
+		}
+		if r.Kind == storage.RequestDeposit {
+			t.Kind = storage.TxDeposit
+			t.Entries = transferEntries(storage.AccountTreasury, r.UserID, r.Amount)
+		}
+		txID, err := b.post(tx, t)
+		if err != nil {
+			return err
+		}
+		return tx.SetRequestTx(id, txID)
+	})
+	return r, err
+}
+
+// CancelRequest отменяет заявку по просьбе её автора.
+func (b *Bank) CancelRequest(uid string, id int64) (storage.MoneyRequest, error) {
+	var r storage.MoneyRequest
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		r, err = tx.CloseRequest(id, uid, storage.StatusCancelled, uid, b.Now())
+		return err
+	})
+	return r, err
+}
+
+func (b *Bank) PendingRequests(uid string) ([]storage.MoneyRequest, error) {
+	var res []storage.MoneyRequest
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		res, err = tx.PendingRequests(uid)
+		return err
+	})
+	return res, err
+}
+
+// ExpireRequests закрывает заявки, провисевшие в pending дольше RequestTTL.
+func (b *Bank) ExpireRequests() ([]storage.MoneyRequest, error) {
+	var res []storage.MoneyRequest
+	err := b.tx(func(tx storage.Tx) error {
+		now := b.Now()
+		var err error
+		res, err = tx.ExpireRequests(now.Add(-RequestTTL), now)
+		return err
+	})
+	return res, err
+}
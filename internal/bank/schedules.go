@@ -0,0 +1,223 @@
+package bank
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"mybot/internal/storage"
+)
+
+// MinScheduleEvery — повторяющуюся рассылку можно отправлять не чаще.
+const MinScheduleEvery = time.Hour
+
+// InfoRotation — как часто WebApp показывает следующую из информационных
+// строк с одинаковым наибольшим приоритетом.
+const InfoRotation = time.Minute
+
+var (
+	// ErrScheduleTime — у рассылки не указано время отправки.
+	ErrScheduleTime = errors.New("укажите время рассылки: at=дд.мм.гггг-чч:мм или in=2h")
+	// ErrScheduleEvery — рассылка повторяется чаще MinScheduleEvery.
+	ErrScheduleEvery = errors.New("повторять рассылку можно не чаще раза в час")
+	// ErrScheduleEnds — окончание раньше начала.
+	ErrScheduleEnds = errors.New("окончание должно быть позже начала")
+	// ErrBadScheduleKind — повтор и фильтры есть только у рассылок, приоритет —
+	// только у информационных строк.
+	ErrBadScheduleKind = errors.New("every= и фильтры — для рассылок, priority= — для информационных строк")
+)
+
+// AddSchedule добавляет в расписание рассылку или информационную строку.
+// Строка без начала показывается сразу.
+func (b *Bank) AddSchedule(adminID string, s storage.Schedule) (storage.Schedule, error) {
+	s.Text, s.CreatedBy, s.CreatedAt = strings.TrimSpace(s.Text), adminID, b.Now()
+	switch s.Kind {
+	case storage.ScheduleBroadcast:
+		if s.Text == "" && s.FileID == "" {
+			return s, ErrEmptyBroadcast
+		}
+		if s.StartsAt.IsZero() {
+			return s, ErrScheduleTime
+		}
+		if s.Every != 0 && s.Every < MinScheduleEvery {
+			return s, ErrScheduleEvery
+		}
+		if s.Priority != 0 {
+			return s, ErrBadScheduleKind
+		}
+		if _, rest, err := ParseSegment(strings.Fields(s.Filters)); err != nil {
+			return s, err
+		} else if len(rest) > 0 {
+			return s, ErrBadSegment
+		}
+	case storage.ScheduleInfo:
+		if s.Text == "" {
+			return s, ErrEmptyBroadcast
+		}
+		if s.Every != 0 || s.Filters != "" || s.FileID != "" {
+			return s, ErrBadScheduleKind
+		}
+		if s.StartsAt.IsZero() {
+			s.StartsAt = b.Now()
+		}
+	default:
+		return s, ErrBadScheduleKind
+	}
+	if !s.EndsAt.IsZero() && !s.EndsAt.After(s.StartsAt) {
+		return s, ErrScheduleEnds
+	}
+	err := b.tx(func(tx storage.Tx) error {
+		if s.Kind == storage.ScheduleBroadcast {
+			seg, _, _ := ParseSegment(strings.Fields(s.Filters))
+			if seg.ProductID > 0 {
+				if _, err := tx.Product(seg.ProductID); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.CreateSchedule(&s)
+	})
+	return s, err
+}
+
+// Schedules — всё расписание по времени начала.
+func (b *Bank) Schedules() ([]storage.Schedule, error) {
+	var res []storage.Schedule
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		res, err = tx.Schedules()
+		return err
+	})
+	return res, err
+}
+
+// Unschedule удаляет запись расписания id.
+func (b *Bank) Unschedule(id int64) error {
+	return b.tx(func(tx storage.Tx) error {
+		ok, err := tx.DeleteSchedule(id)
+		if err == nil && !ok {
+			err = storage.ErrNotFound
+		}
+		return err
+	})
+}
+
+// ScheduleSkip — рассылка по расписанию, пропущенная в этот раз.
+type ScheduleSkip struct {
+	ID  int64
+	Err error
+}
+
+// RunSchedules ставит в очередь рассылки, время которых наступило, и
+// возвращает их вместе с пропущенными. Повторяющаяся рассылка переносится
+// на следующий период (пропущенные за время простоя не догоняются), разовая
+// и закончившаяся удаляются из расписания вместе с истёкшими
+// информационными строками. Каждая рассылка обрабатывается в своей
+// транзакции: если под фильтры никто не подходит или поставить её в
+// очередь не удалось, она пропускается, не мешая остальным.
+func (b *Bank) RunSchedules() ([]storage.Broadcast, []ScheduleSkip, error) {
+	now := b.Now()
+	var due []storage.Schedule
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		due, err = tx.DueSchedules(now)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var res []storage.Broadcast
+	var skipped []ScheduleSkip
+	for _, s := range due {
+		var job storage.Broadcast
+		err := b.tx(func(tx storage.Tx) error {
+			var err error
+			if job, err = b.queueSchedule(tx, s, now); err != nil {
+				return err
+			}
+			return rescheduleBroadcast(tx, s, now)
+		})
+		if err != nil {
+			// Пропускаем этот раз, чтобы ошибка не повторялась каждую минуту.
+			if rerr := b.tx(func(tx storage.Tx) error { return rescheduleBroadcast(tx, s, now) }); rerr != nil {
+				err = errors.Join(err, rerr)
+			}
+			skipped = append(skipped, ScheduleSkip{ID: s.ID, Err: err})
+			continue
+		}
+		if job.ID == 0 {
+			skipped = append(skipped, ScheduleSkip{ID: s.ID, Err: ErrNoRecipients})
+			continue
+		}
+		res = append(res, job)
+	}
+
+	err = b.tx(func(tx storage.Tx) error {
+		all, err := tx.Schedules()
+		if err != nil {
+			return err
+		}
+		for _, s := range all {
+			if s.Kind == storage.ScheduleInfo && !s.EndsAt.IsZero() && !s.EndsAt.After(now) {
+				if _, err := tx.DeleteSchedule(s.ID); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return res, skipped, err
+}
+
+// queueSchedule ставит рассылку s в очередь её получателям; если под
+// фильтры никто не подходит, возвращает рассылку без ID.
+func (b *Bank) queueSchedule(tx storage.Tx, s storage.Schedule, now time.Time) (storage.Broadcast, error) {
+	job := s.Broadcast()
+	seg, _, err := ParseSegment(strings.Fields(s.Filters))
+	if err != nil {
+		return job, err
+	}
+	recipients, err := b.recipients(tx, seg)
+	if err != nil || len(recipients) == 0 {
+		return job, err
+	}
+	job.Segment, job.Status, job.Total, job.CreatedAt = seg.String(), storage.BroadcastQueued, len(recipients), now
+	err = tx.CreateBroadcast(&job, recipients)
+	return job, err
+}
+
+// rescheduleBroadcast переносит повторяющуюся рассылку s на следующий
+// после now период или удаляет её из расписания, если повторов больше нет.
+func rescheduleBroadcast(tx storage.Tx, s storage.Schedule, now time.Time) error {
+	if s.Every > 0 {
+		for !s.StartsAt.After(now) {
+			s.StartsAt = s.StartsAt.Add(s.Every)
+		}
+		if s.EndsAt.IsZero() || s.StartsAt.Before(s.EndsAt) {
+			return tx.UpdateSchedule(s)
+		}
+	}
+	_, err := tx.DeleteSchedule(s.ID)
+	return err
+}
+
+// infoLine — информационная строка для WebApp сейчас: строки расписания с
+// наибольшим приоритетом по очереди, каждая на InfoRotation, а если их
+// нет — строка из /set_info.
+func (b *Bank) infoLine(tx storage.Tx) (string, error) {
+	now := b.Now()
+	lines, err := tx.InfoLines(now)
+	if err != nil {
+		return "", err
+	}
+	if len(lines) == 0 {
+		return tx.InfoLine()
+	}
+	top := 1
+	for top < len(lines) && lines[top].Priority == lines[0].Priority {
+		top++
+	}
+	slot := now.Unix() / int64(InfoRotation/time.Second)
+	return lines[slot%int64(top)].Text, nil
+}
@@ -0,0 +1,221 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+// TouchInterval — точность времени последней активности игрока: фильтр
+// active= считает дни, так что чаще обновлять его незачем.
+const TouchInterval = time.Hour
+
+var (
+	// ErrBadSegment — фильтр получателей рассылки указан неверно.
+	ErrBadSegment = errors.New("фильтры: role=<роль>, balance=<от>..<до>, bond=<облигация>, active=<дней>, ids=<ID,ID>")
+	// ErrNoRecipients — под фильтры рассылки не подходит ни один игрок.
+	ErrNoRecipients = errors.New("под фильтры не подходит ни один игрок")
+)
+
+// Segment — получатели рассылки. Нулевые поля не ограничивают выборку,
+// заполненные сужают её все вместе.
+type Segment struct {
+	// Roles — роли игроков, достаточно любой из них.
+	Roles []string
+	// MinBalance и MaxBalance — границы баланса включительно.
+	MinBalance, MaxBalance *money.Money
+	// ProductID — только держатели открытых вкладов по этой облигации.
+	ProductID int
+	// ActiveDays — только писавшие боту за последние ActiveDays дней.
+	ActiveDays int
+	// IDs — только перечисленные игроки.
+	IDs []string
+}
+
+// ParseSegment разбирает фильтры получателей в начале аргументов команды
+// (role=, balance=, bond=, active=, ids=) и возвращает остальные аргументы.
+func ParseSegment(args []string) (Segment, []string, error) {
+	var s Segment
+	for len(args) > 0 {
+		key, val, ok := strings.Cut(args[0], "=")
+		if !ok {
+			break
+		}
+		var err error
+		switch key {
+		case "role":
+			s.Roles = splitList(val)
+			if len(s.Roles) == 0 {
+				err = ErrBadSegment
+			}
+		case "balance":
+			s.MinBalance, s.MaxBalance, err = parseRange(val)
+		case "bond":
+			s.ProductID, err = strconv.Atoi(val)
+			if err == nil {
+				err = CheckBondID(s.ProductID)
+			}
+		case "active":
+			if s.ActiveDays, err = strconv.Atoi(val); err == nil && s.ActiveDays <= 0 {
+				err = ErrBadSegment
+			}
+		case "ids":
+			s.IDs = splitList(val)
+			if len(s.IDs) == 0 {
+				err = ErrBadSegment
+			}
+			for _, id := range s.IDs {
+				if CheckUserID(id) != nil {
+					err = ErrBadSegment
+				}
+			}
+		default:
+			return s, args, nil
+		}
+		if err != nil {
+			return s, args, fmt.Errorf("%w: %s", ErrBadSegment, args[0])
+		}
+		args = args[1:]
+	}
+	return s, args, nil
+}
+
+func splitList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// parseRange разбирает диапазон баланса: "100..500", "100.." или "..500".
+func parseRange(s string) (min, max *money.Money, err error) {
+	from, to, ok := strings.Cut(s, "..")
+	if !ok || from == "" && to == "" {
+		return nil, nil, ErrBadSegment
+	}
+	if from != "" {
+		v, err := money.Parse(from)
+		if err != nil {
+			return nil, nil, err
+		}
+		min = &v
+	}
+	if to != "" {
+		v, err := money.Parse(to)
+		if err != nil {
+			return nil, nil, err
+		}
+		max = &v
+	}
+	if min != nil && max != nil && *min > *max {
+		return nil, nil, ErrBadSegment
+	}
+	return min, max, nil
+}
+
+// String — описание получателей для администратора.
+func (s Segment) String() string {
+	var parts []string
+	if len(s.Roles) > 0 {
+		parts = append(parts, "роль "+strings.Join(s.Roles, ", "))
+	}
+	switch {
+	case s.MinBalance != nil && s.MaxBalance != nil:
+		parts = append(parts, fmt.Sprintf("баланс от %s до %s GOLD", *s.MinBalance, *s.MaxBalance))
+	case s.MinBalance != nil:
+		parts = append(parts, fmt.Sprintf("баланс от %s GOLD", *s.MinBalance))
+	case s.MaxBalance != nil:
+		parts = append(parts, fmt.Sprintf("баланс до %s GOLD", *s.MaxBalance))
+	}
+	if s.ProductID > 0 {
+		parts = append(parts, fmt.Sprintf("вкладчики облигации #%d", s.ProductID))
+	}
+	if s.ActiveDays > 0 {
+		parts = append(parts, fmt.Sprintf("активные за %d дн.", s.ActiveDays))
+	}
+	if len(s.IDs) > 0 {
+		parts = append(parts, "ID "+strings.Join(s.IDs, ", "))
+	}
+	if len(parts) == 0 {
+		return "все игроки"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// recipients — незаблокированные игроки сегмента s, кроме заблокировавших
+// бота.
+func (b *Bank) recipients(tx storage.Tx, s Segment) ([]string, error) {
+	users, err := tx.Users(false)
+	if err != nil {
+		return nil, err
+	}
+
+	var balances map[string]money.Money
+	if s.MinBalance != nil || s.MaxBalance != nil {
+		list, err := tx.Balances()
+		if err != nil {
+			return nil, err
+		}
+		balances = make(map[string]money.Money, len(list))
+		for _, a := range list {
+			balances[a.UserID] = a.Amount
+		}
+	}
+
+	var holders map[string]bool
+	if s.ProductID > 0 {
+		if _, err := tx.Product(s.ProductID); err != nil {
+			return nil, err
+		}
+		bonds, err := tx.AllBonds()
+		if err != nil {
+			return nil, err
+		}
+		holders = make(map[string]bool)
+		for _, bond := range bonds {
+			if bond.ProductID == s.ProductID {
+				holders[bond.UserID] = true
+			}
+		}
+	}
+
+	var since time.Time
+	if s.ActiveDays > 0 {
+		since = b.Now().AddDate(0, 0, -s.ActiveDays)
+	}
+
+	var res []string
+	for _, u := range users {
+		switch {
+		case u.BlockedBot:
+		case len(s.Roles) > 0 && !slices.Contains(s.Roles, u.Role):
+		case s.MinBalance != nil && balances[u.ID] < *s.MinBalance:
+		case s.MaxBalance != nil && balances[u.ID] > *s.MaxBalance:
+		case holders != nil && !holders[u.ID]:
+		case s.ActiveDays > 0 && u.LastSeenAt.Before(since):
+		case len(s.IDs) > 0 && !slices.Contains(s.IDs, u.ID):
+		default:
+			res = append(res, u.ID)
+		}
+	}
+	return res, nil
+}
+
+// Touch запоминает, что игрок uid только что писал боту, и возвращает его в
+// рассылки, если он блокировал бота. Время обновляется не чаще раза в
+// TouchInterval, чтобы не писать в базу на каждое сообщение.
+func (b *Bank) Touch(uid string) error {
+	now := b.Now()
+	return b.tx(func(tx storage.Tx) error {
+		return tx.TouchUser(uid, now, now.Add(-TouchInterval))
+	})
+}
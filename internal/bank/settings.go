@@ -0,0 +1,185 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"mybot/internal/storage"
+)
+
+// Ключи настроек банка.
+const (
+	SettingMarketFeePct      = "market_fee_pct"      // комиссия вторичного рынка, % от цены
+	SettingMarketAllowLocked = "market_allow_locked" // можно ли продавать заблокированные вклады
+	SettingEvidenceHours     = "evidence_hours"      // сколько часов заявка на пополнение ждёт скриншот
+	SettingPlayerRoles       = "player_roles"        // роли, которые игрок может выбрать при регистрации
+)
+
+// settingDef — описание настройки: значение по умолчанию и проверка нового.
+type settingDef struct {
+	def   string
+	title string
+	check func(v string) error
+}
+
+var settingDefs = map[string]settingDef{
+	SettingMarketFeePct:       {"0", "комиссия вторичного рынка, %", checkPercent},
+	SettingMarketAllowLocked:  {"false", "продажа заблокированных вкладов на вторичном рынке", checkBool},
+	SettingFeeTransfer:        {"0", "комиссия за перевод", checkFeeSchedule},
+	SettingFeeWithdraw:        {"0", "комиссия за вывод", checkFeeSchedule},
+	SettingFeeSellBond:        {"0", "комиссия за продажу вклада банку", checkFeeSchedule},
+	SettingFeeFreeRoles:       {"", "роли администраторов без комиссий, через запятую", checkAdminRoles},
+	SettingFeeFreePlayerRoles: {"", "роли игроков без комиссий, через запятую", checkFreePlayerRoles},
+	SettingEvidenceHours:      {"24", "часов на скриншот к заявке на пополнение, 0 — без срока", checkHours},
+	SettingPlayerRoles:        {"player", "роли игроков при регистрации, через запятую", checkPlayerRoles},
+}
+
+var (
+	// ErrUnknownSetting — такой настройки нет.
+	ErrUnknownSetting = errors.New("неизвестная настройка")
+	// ErrBadSetting — значение не подходит настройке.
+	ErrBadSetting = errors.New("некорректное значение настройки")
+)
+
+func checkPercent(v string) error {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || !validPercent(f) {
+		return fmt.Errorf("%w: нужно число от 0 до 100", ErrBadSetting)
+	}
+	return nil
+}
+
+func checkHours(v string) error {
+	if n, err := strconv.Atoi(v); err != nil || n < 0 || n > 24*365 {
+		return fmt.Errorf("%w: нужно целое число часов от 0", ErrBadSetting)
+	}
+	return nil
+}
+
+func checkBool(v string) error {
+	if _, err := strconv.ParseBool(v); err != nil {
+		return fmt.Errorf("%w: нужно true или false", ErrBadSetting)
+	}
+	return nil
+}
+
+func checkAdminRoles(v string) error {
+	for r := range parseRoles(v) {
+		if !IsRole(r) {
+			return fmt.Errorf("%w: %s — не роль администратора (owner, finance, moderator, support)", ErrBadSetting, r)
+		}
+	}
+	return nil
+}
+
+func checkFreePlayerRoles(v string) error {
+	for r := range parseRoles(v) {
+		if IsRole(r) {
+			return fmt.Errorf("%w: %s — роль администратора", ErrBadSetting, r)
+		}
+	}
+	return nil
+}
+
+// checkPlayerRoles не даёт назвать роль игрока так же, как роль
+// администратора: по ролям подбираются лимиты и освобождение от комиссий.
+func checkPlayerRoles(v string) error {
+	roles := parseRoles(v)
+	if len(roles) == 0 {
+		return fmt.Errorf("%w: нужна хотя бы одна роль", ErrBadSetting)
+	}
+	for r := range roles {
+		if IsRole(r) || len(r) > 32 {
+			return fmt.Errorf("%w: %s не может быть ролью игрока", ErrBadSetting, r)
+		}
+	}
+	return nil
+}
+
+// SettingView — настройка с текущим значением для /settings.
+type SettingView struct {
+	Key, Title, Value, Default string
+	UpdatedBy                  string
+}
+
+// Settings — все известные настройки с текущими значениями.
+func (b *Bank) Settings() ([]SettingView, error) {
+	var set []storage.Setting
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		set, err = tx.Settings()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]storage.Setting, len(set))
+	for _, s := range set {
+		stored[s.Key] = s
+	}
+
+	res := make([]SettingView, 0, len(settingDefs))
+	for key, d := range settingDefs {
+		v := SettingView{Key: key, Title: d.title, Value: d.def, Default: d.def}
+		if s, ok := stored[key]; ok {
+			v.Value, v.UpdatedBy = s.Value, s.UpdatedBy
+		}
+		res = append(res, v)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res, nil
+}
+
+// SetSetting меняет настройку key от имени adminID.
+func (b *Bank) SetSetting(adminID, key, value string) error {
+	d, ok := settingDefs[key]
+	if !ok {
+		return ErrUnknownSetting
+	}
+	if err := d.check(value); err != nil {
+		return err
+	}
+	return b.tx(func(tx storage.Tx) error {
+		return tx.SetSetting(storage.Setting{Key: key, Value: value, UpdatedBy: adminID, UpdatedAt: b.Now()})
+	})
+}
+
+// setting — значение настройки внутри транзакции tx.
+func setting(tx storage.Tx, key string) (string, error) {
+	set, err := tx.Settings()
+	if err != nil {
+		return "", err
+	}
+	for _, s := range set {
+		if s.Key == key {
+			return s.Value, nil
+		}
+	}
+	return settingDefs[key].def, nil
+}
+
+func settingFloat(tx storage.Tx, key string) (float64, error) {
+	v, err := setting(tx, key)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+func settingInt(tx storage.Tx, key string) (int, error) {
+	v, err := setting(tx, key)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(v)
+}
+
+func settingBool(tx storage.Tx, key string) (bool, error) {
+	v, err := setting(tx, key)
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(v)
+}
@@ -0,0 +1,181 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+var (
+	// ErrUnknownRecipient — игрока с таким ID или ником нет.
+	ErrUnknownRecipient = errors.New("получатель не найден")
+	// ErrAmbiguousRecipient — ник носят несколько игроков (заведённые до
+	// проверки ников при регистрации); в ошибке перечисляются их ID.
+	ErrAmbiguousRecipient = errors.New("этот ник у нескольких игроков, укажите ID")
+	// ErrSelfTransfer — перевод самому себе.
+	ErrSelfTransfer = errors.New("нельзя перевести самому себе")
+	// ErrRecipientBanned — получатель заблокирован.
+	ErrRecipientBanned = errors.New("получатель заблокирован")
+	// ErrCommentTooLong — комментарий к переводу длиннее MaxCommentLen.
+	ErrCommentTooLong = errors.New("комментарий слишком длинный")
+	// ErrTransferExpired — перевод не подтвердили за TransferTTL.
+	ErrTransferExpired = errors.New("время на подтверждение перевода истекло")
+)
+
+const (
+	// TransferTTL — сколько перевод ждёт подтверждения отправителем.
+	TransferTTL = 10 * time.Minute
+	// MaxCommentLen — максимальная длина комментария к переводу в символах.
+	MaxCommentLen = 200
+)
+
+// TransferDraft — перевод, ожидающий подтверждения, с ником получателя.
+type TransferDraft struct {
+	storage.PendingTransfer
+	ToNick string
+}
+
+// recipient находит получателя перевода по ID или нику (можно с @) и
+// проверяет, что ему можно переводить.
+func recipient(tx storage.Tx, from, target string) (storage.User, error) {
+	target = strings.TrimPrefix(strings.TrimSpace(target), "@")
+	if target == "" {
+		return storage.User{}, ErrNoRecipient
+	}
+	u, err := tx.User(target)
+	if errors.Is(err, storage.ErrNotFound) {
+		users, err := tx.UsersByNick(target)
+		if err != nil {
+			return u, err
+		}
+		switch len(users) {
+		case 0:
+			return u, ErrUnknownRecipient
+		case 1:
+			u = users[0]
+		default:
+			ids := make([]string, len(users))
+			for i, u := range users {
+				ids[i] = u.ID
+			}
+			return u, fmt.Errorf("%w: %s", ErrAmbiguousRecipient, strings.Join(ids, ", "))
+		}
+	} else if err != nil {
+		return u, err
+	}
+	if u.ID == from {
+		return u, ErrSelfTransfer
+	}
+	if u.Banned {
+		return u, ErrRecipientBanned
+	}
+	return u, nil
+}
+
+// PrepareTransfer проверяет перевод игроку target (ID или ник) и сохраняет
+// его до подтверждения отправителем. Комиссия считается сейчас и
+// фиксируется в переводе.
+func (b *Bank) PrepareTransfer(from, target string, amount money.Money, comment string) (TransferDraft, error) {
+	comment = strings.TrimSpace(comment)
+	d := TransferDraft{PendingTransfer: storage.PendingTransfer{
+		FromID: from, Amount: amount, Comment: comment, Status: storage.StatusPending, CreatedAt: b.Now(),
+	}}
+	if err := CheckAmount(amount); err != nil {
+		return d, err
+	}
+	if utf8.RuneCountInString(comment) > MaxCommentLen {
+		return d, ErrCommentTooLong
+	}
+	err := b.tx(func(tx storage.Tx) error {
+		to, err := recipient(tx, from, target)
+		if err != nil {
+			return err
+		}
+		d.ToID, d.ToNick = to.ID, to.Nick
+		if d.Fee, err = b.fee(tx, from, FeeTransfer, amount); err != nil {
+			return err
+		}
+		balance, err := tx.Balance(from)
+		if err != nil {
+			return err
+		}
+		if balance < amount+d.Fee {
+			return ErrInsufficientFunds
+		}
+		return tx.CreatePendingTransfer(&d.PendingTransfer)
+	})
+	return d, err
+}
+
+// ConfirmTransfer проводит перевод id, подготовленный uid. Перевод
+// закрывается в той же транзакции, поэтому повторное подтверждение получит
+// storage.ErrTransferClosed. Если средств не хватает, перевод остаётся
+// ожидающим; просроченный — закрывается как истёкший. Перевод сверх лимита
+// не проводится, а уходит на проверку администрации: Transfer.Review.
+func (b *Bank) ConfirmTransfer(uid string, id int64) (Transfer, error) {
+	res := Transfer{ID: id}
+	err := b.tx(func(tx storage.Tx) error {
+		p, err := tx.ClosePendingTransfer(id, uid, storage.StatusPending, storage.StatusApproved, b.Now())
+		if err != nil {
+			return err
+		}
+		if b.Now().Sub(p.CreatedAt) > TransferTTL {
+			return ErrTransferExpired
+		}
+		to, err := recipient(tx, uid, p.ToID)
+		if err != nil {
+			return err
+		}
+		breach, err := b.checkLimits(tx, uid, FeeTransfer, p.Amount)
+		if err != nil {
+			return err
+		}
+		if breach == nil {
+			res, err = b.transfer(tx, uid, to, p.Amount, p.Fee, p.Comment)
+			res.ID = id
+			return err
+		}
+
+		// Средства проверяются сразу, чтобы администрации не приходили
+		// переводы, которые всё равно не пройдут.
+		balance, err := tx.Balance(uid)
+		if err != nil {
+			return err
+		}
+		if balance < p.Amount+p.Fee {
+			return ErrInsufficientFunds
+		}
+		if _, err := tx.ClosePendingTransfer(id, uid, storage.StatusApproved, storage.StatusReview, b.Now()); err != nil {
+			return err
+		}
+		sender, _ := tx.User(uid)
+		res = Transfer{ID: id, FromID: uid, FromNick: sender.Nick, ToID: to.ID, ToNick: to.Nick,
+			Amount: p.Amount, Fee: p.Fee, Comment: p.Comment, Review: breach}
+		return nil
+	})
+	if errors.Is(err, ErrTransferExpired) {
+		if err := b.tx(func(tx storage.Tx) error {
+			_, err := tx.ClosePendingTransfer(id, uid, storage.StatusPending, storage.StatusExpired, b.Now())
+			return err
+		}); err != nil {
+			return res, err
+		}
+	}
+	return res, err
+}
+
+// CancelTransfer отменяет неподтверждённый перевод id отправителя uid.
+func (b *Bank) CancelTransfer(uid string, id int64) (storage.PendingTransfer, error) {
+	var p storage.PendingTransfer
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		p, err = tx.ClosePendingTransfer(id, uid, storage.StatusPending, storage.StatusCancelled, b.Now())
+		return err
+	})
+	return p, err
+}
@@ -0,0 +1,83 @@
+package bank
+
+import (
+	"errors"
+
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+// ErrBadAmount — сумма операции не может быть нулевой.
+var ErrBadAmount = errors.New("сумма не может быть нулевой")
+
+// Treasury — состояние казны против обязательств по открытым вкладам. Тело
+// вкладов лежит на system:bonds, поэтому из резервов казны должны
+// выплачиваться только начисленные проценты.
+type Treasury struct {
+	// Reserves — баланс system:treasury.
+	Reserves money.Money
+	// Principal — тело открытых вкладов, Interest — начисленные, но ещё не
+	// выплаченные проценты по ним.
+	Principal money.Money
+	Interest  money.Money
+	Bonds     int
+}
+
+// Liabilities — сколько банк должен владельцам вкладов, если все закроют их сейчас.
+func (t Treasury) Liabilities() money.Money {
+	return t.Principal + t.Interest
+}
+
+// Shortfall — насколько начисленные проценты превышают резервы казны; 0,
+// если резервов хватает.
+func (t Treasury) Shortfall() money.Money {
+	return max(t.Interest-t.Reserves, 0)
+}
+
+// Treasury считает резервы казны и обязательства по всем открытым вкладам.
+func (b *Bank) Treasury() (Treasury, error) {
+	var t Treasury
+	err := b.tx(func(tx storage.Tx) error {
+		var err error
+		if t.Reserves, err = tx.AccountTotal(storage.AccountTreasury); err != nil {
+			return err
+		}
+		bonds, err := tx.AllBonds()
+		if err != nil {
+			return err
+		}
+		for _, bond := range bonds {
+			t.Principal += bond.Amount
+			t.Interest += b.BondValue(bond) - bond.Amount
+		}
+		t.Bonds = len(bonds)
+		return nil
+	})
+	return t, err
+}
+
+// FundTreasury пополняет казну извне на amount от имени adminID;
+// отрицательная сумма изымает средства из казны.
+func (b *Bank) FundTreasury(adminID string, amount money.Money, memo string) error {
+	if amount == 0 {
+		return ErrBadAmount
+	}
+	if amount > MaxAmount || -amount > MaxAmount {
+		return ErrAmountTooLarge
+	}
+	if memo == "" {
+		memo = "Пополнение казны"
+		if amount < 0 {
+			memo = "Изъятие из казны"
+		}
+	}
+	return b.tx(func(tx storage.Tx) error {
+		_, err := b.post(tx, storage.Transaction{
+			Kind:      storage.TxTreasury,
+			Initiator: adminID,
+			Memo:      memo,
+			Entries:   transferEntries(storage.AccountExternal, storage.AccountTreasury, amount),
+		})
+		return err
+	})
+}
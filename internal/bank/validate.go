@@ -0,0 +1,69 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"mybot/internal/money"
+)
+
+// MaxAmount — наибольшая сумма одной операции.
+const MaxAmount = money.Money(1_000_000_000_00)
+
+var (
+	// ErrAmountNotPositive — сумма операции должна быть больше нуля.
+	ErrAmountNotPositive = errors.New("сумма должна быть больше нуля")
+	// ErrAmountTooLarge — сумма операции больше MaxAmount.
+	ErrAmountTooLarge = fmt.Errorf("сумма не может превышать %s GOLD", MaxAmount)
+	// ErrBadBondID — номер вклада или облигации не указан или некорректен.
+	ErrBadBondID = errors.New("некорректный номер вклада")
+	// ErrBadListingID — номер объявления не указан или некорректен.
+	ErrBadListingID = errors.New("некорректный номер объявления")
+	// ErrBadRequestID — номер заявки не указан или некорректен.
+	ErrBadRequestID = errors.New("некорректный номер заявки")
+	// ErrBadTicketID — номер обращения не указан или некорректен.
+	ErrBadTicketID = errors.New("некорректный номер обращения")
+	// ErrBadUserID — ID игрока должен быть числовым Telegram ID.
+	ErrBadUserID = errors.New("некорректный ID игрока")
+	// ErrNickTaken — ник, без учёта регистра, уже у другого игрока.
+	ErrNickTaken = errors.New("этот ник уже занят")
+	// ErrBadRole — роли нет в SettingPlayerRoles.
+	ErrBadRole = errors.New("такой роли игрока нет")
+	// ErrNoRecipient — получатель перевода не указан.
+	ErrNoRecipient = errors.New("укажите получателя: ник или ID")
+)
+
+// CheckAmount проверяет сумму, пришедшую от игрока или администратора:
+// она положительна и не больше MaxAmount. Формат и точность проверяет
+// money.Parse.
+func CheckAmount(m money.Money) error {
+	if m <= 0 {
+		return ErrAmountNotPositive
+	}
+	if m > MaxAmount {
+		return ErrAmountTooLarge
+	}
+	return nil
+}
+
+// CheckBondID проверяет номер вклада или облигации.
+func CheckBondID(id int) error {
+	if id <= 0 {
+		return ErrBadBondID
+	}
+	return nil
+}
+
+// CheckUserID проверяет, что id похож на Telegram ID: только цифры.
+func CheckUserID(id string) error {
+	if id == "" || len(id) > 20 || strings.Trim(id, "0123456789") != "" {
+		return ErrBadUserID
+	}
+	return nil
+}
+
+// validPercent — процент от 0 до 100; NaN и бесконечность не проходят.
+func validPercent(v float64) bool {
+	return v >= 0 && v <= 100
+}
@@ -0,0 +1,517 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+
+	"mybot/internal/bank"
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+func (h *Handlers) addAdmin(c telebot.Context) error {
+	if !h.can(c, bank.PermAdmins) {
+		return nil
+	}
+	args := c.Args()
+	if len(args) < 2 {
+		return c.Send("⚠️ Формат: /add_admin [ID] [owner|finance|moderator|support]")
+	}
+	if err := bank.CheckUserID(args[0]); err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+	id, role := args[0], args[1]
+	if !bank.IsRole(role) {
+		return c.Send("❌ Неизвестная роль. Доступны: owner, finance, moderator, support")
+	}
+
+	err := h.bank.SetAdmin(id, role, senderID(c))
+	if errors.Is(err, bank.ErrBootstrapOwner) {
+		return c.Send("❌ Этот администратор задан в конфигурации, его роль не меняется.")
+	}
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+
+	h.notify(id, fmt.Sprintf("🛡 Вам выдана роль администратора: %s", roleTitles[role]))
+	return c.Send(fmt.Sprintf("✅ %s назначен: %s", id, roleTitles[role]))
+}
+
+func (h *Handlers) removeAdmin(c telebot.Context) error {
+	if !h.can(c, bank.PermAdmins) {
+		return nil
+	}
+	args := c.Args()
+	if len(args) < 1 {
+		return c.Send("⚠️ Формат: /remove_admin [ID]")
+	}
+	if err := bank.CheckUserID(args[0]); err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+
+	removed, err := h.bank.RemoveAdmin(args[0])
+	if errors.Is(err, bank.ErrBootstrapOwner) {
+		return c.Send("❌ Этот администратор задан в конфигурации и не может быть снят.")
+	}
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	if !removed {
+		return c.Send("❌ Администратор не найден.")
+	}
+	return c.Send(fmt.Sprintf("✅ %s больше не администратор", args[0]))
+}
+
+func (h *Handlers) admins(c telebot.Context) error {
+	if h.bank.Role(senderID(c)) == "" {
+		return nil
+	}
+	admins, err := h.bank.Admins()
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+
+	res := "🛡 Администраторы:\n\n"
+	for _, a := range admins {
+		u, _ := h.bank.User(a.ID)
+		res += fmt.Sprintf("%s — %s %s\n", roleTitles[a.Role], a.ID, u.Nick)
+	}
+	return c.Send(res)
+}
+
+func (h *Handlers) setInfo(c telebot.Context) error {
+	if !h.can(c, bank.PermBroadcast) {
+		return nil
+	}
+	text := strings.Join(c.Args(), " ")
+	if text == "" {
+		return c.Send("⚠️ Формат: /set_info [текст информации]")
+	}
+	if err := h.bank.SetInfoLine(text); err != nil {
+		return c.Send("❌ Ошибка БД: " + err.Error())
+	}
+	return c.Send("✅ Информационная строка обновлена!")
+}
+
+func (h *Handlers) ban(c telebot.Context) error {
+	if !h.can(c, bank.PermModerate) {
+		return nil
+	}
+	args := c.Args()
+	if len(args) < 1 {
+		return c.Send("⚠️ Формат: /ban [ID пользователя]")
+	}
+	if err := bank.CheckUserID(args[0]); err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+
+	if _, err := h.bank.SetBanned(args[0], true); err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+
+	h.notify(args[0], "🚫 Вы были заблокированы администрацией. Доступ к системе ограничен.")
+	return c.Send(fmt.Sprintf("✅ Пользователь %s заблокирован", args[0]))
+}
+
+func (h *Handlers) unban(c telebot.Context) error {
+	if !h.can(c, bank.PermModerate) {
+		return nil
+	}
+	args := c.Args()
+	if len(args) < 1 {
+		return c.Send("⚠️ Формат: /unban [ID пользователя]")
+	}
+	if err := bank.CheckUserID(args[0]); err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+
+	if _, err := h.bank.SetBanned(args[0], false); err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+
+	h.notify(args[0], "✅ Ваша блокировка снята! Доступ к системе восстановлен.")
+	return c.Send(fmt.Sprintf("✅ Пользователь %s разблокирован", args[0]))
+}
+
+func (h *Handlers) createBond(c telebot.Context) error {
+	if !h.can(c, bank.PermBonds) {
+		return nil
+	}
+	args := c.Args()
+	if len(args) < 3 {
+		return c.Send("⚠️ Формат: /create_bond [Название] [Мин_Цена] [Процент] [Срок_дней, 0 — бессрочно] [опции]\n" +
+			bondOptionsHelp)
+	}
+	p := storage.Product{Name: args[0]}
+	var err error
+	if p.Price, err = money.Parse(args[1]); err != nil {
+		return c.Send("❌ Мин_Цена: " + err.Error())
+	}
+	if p.Rate, err = strconv.ParseFloat(args[2], 64); err != nil {
+		return c.Send("❌ Процент должен быть числом")
+	}
+	if len(args) > 3 {
+		if p.TermDays, err = strconv.Atoi(args[3]); err != nil || p.TermDays < 0 {
+			return c.Send("❌ Срок должен быть целым числом дней")
+		}
+	}
+	if err := applyBondOptions(&p, args[min(len(args), 4):]); err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+
+	p, err = h.bank.CreateProduct(senderID(c), p)
+	if isProductError(err) {
+		return c.Send("❌ " + err.Error())
+	}
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	return c.Send(fmt.Sprintf("✅ Облигация #%d %s создана!\n%s", p.ID, p.Name, productTerms(p)))
+}
+
+func (h *Handlers) allBonds(c telebot.Context) error {
+	if !h.can(c, bank.PermReports) {
+		return nil
+	}
+	bonds, err := h.bank.AllBonds()
+	if err != nil {
+		return c.Send("❌ Ошибка БД или данных нет.")
+	}
+	if len(bonds) == 0 {
+		return c.Send("📈 Активных вкладов не обнаружено.")
+	}
+
+	res := "📈 Все активные вклады:\n\n"
+	for _, b := range bonds {
+		icon := "🔒"
+		if b.CanWithdraw {
+			icon = "🔓"
+		}
+		res += fmt.Sprintf("[%d] %s %s: %s\n💰 %s → %s GOLD\n📅 %s", b.ID, icon, b.Nick, b.Name, b.Amount, b.CurrentValue, b.CreatedAt.Format("02.01 15:04"))
+		if b.Maturity != "" {
+			res += " → " + b.Maturity
+		}
+		res += "\n\n"
+	}
+	return c.Send(res)
+}
+
+func (h *Handlers) setLock(c telebot.Context) error {
+	if !h.can(c, bank.PermBonds) {
+		return nil
+	}
+	args := c.Args()
+	if len(args) < 2 || args[1] != "0" && args[1] != "1" {
+		return c.Send("⚠️ /set_lock [ID] [1-разлок / 0-блок]")
+	}
+	id, err := parseBondID(args[0])
+	if err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+	val := args[1] == "1"
+	found, err := h.bank.SetBondLock(id, val)
+	if err != nil {
+		return c.Send("❌ Ошибка базы: " + err.Error())
+	}
+	if !found {
+		return c.Send("❌ Ошибка: Инвестиция с таким ID не найдена.")
+	}
+	status := "заблокирована"
+	if val {
+		status = "разблокирована"
+	}
+	return c.Send(fmt.Sprintf("✅ Инвестиция #%d %s.", id, status))
+}
+
+func (h *Handlers) cashAllFile(c telebot.Context) error {
+	if !h.can(c, bank.PermReports) {
+		return nil
+	}
+	balances, err := h.bank.Balances()
+	if err != nil {
+		return c.Send("❌ Нечего выгружать.")
+	}
+
+	content := "--- РЕЕСТР БАЛАНСОВ ---\n"
+	for _, b := range balances {
+		content += fmt.Sprintf("%s: %s GOLD\n", b.Nick, b.Amount)
+	}
+
+	fileName := "balances.txt"
+	_ = os.WriteFile(fileName, []byte(content), 0644)
+	return c.Send(&telebot.Document{File: telebot.FromDisk(fileName), FileName: fileName})
+}
+
+func (h *Handlers) ledger(c telebot.Context) error {
+	if !h.can(c, bank.PermReports) {
+		return nil
+	}
+	args := c.Args()
+	if len(args) < 1 {
+		return c.Send("⚠️ Формат: /ledger [ID пользователя или system:счёт]")
+	}
+	lines, balance, err := h.bank.Statement(args[0], 30)
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	if len(lines) == 0 {
+		return c.Send("📒 По счёту нет проводок.")
+	}
+
+	res := fmt.Sprintf("📒 Проводки по счёту %s (баланс: %s GOLD):\n\n", args[0], balance)
+	for _, l := range lines {
+		res += fmt.Sprintf("#%d %s %s\n%s GOLD %s\n\n", l.TxID, l.CreatedAt.Format("02.01 15:04"), l.Kind, l.Amount.Signed(), l.Memo)
+	}
+	return c.Send(res)
+}
+
+func (h *Handlers) reconcile(c telebot.Context) error {
+	if !h.can(c, bank.PermReports) {
+		return nil
+	}
+	mismatches, err := h.bank.Reconcile()
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	if len(mismatches) == 0 {
+		return c.Send("✅ Балансы сходятся с ledger.")
+	}
+
+	res := "⚠️ РАСХОЖДЕНИЯ С LEDGER:\n\n"
+	for _, m := range mismatches {
+		res += fmt.Sprintf("👤 %s: баланс %s, ledger %s\n", m.UserID, m.Balance, m.Ledger)
+	}
+	return c.Send(res)
+}
+
+func (h *Handlers) treasury(c telebot.Context) error {
+	if !h.can(c, bank.PermReports) {
+		return nil
+	}
+	t, err := h.bank.Treasury()
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	res := fmt.Sprintf("🏦 КАЗНА БАНКА\n\n💰 Резервы: %s GOLD\n\n📊 Обязательства по вкладам (%d шт.): %s GOLD\n💵 Тело вкладов: %s GOLD\n📈 Начисленные проценты: %s GOLD",
+		t.Reserves, t.Bonds, t.Liabilities(), t.Principal, t.Interest)
+	if s := t.Shortfall(); s > 0 {
+		res += fmt.Sprintf("\n\n⚠️ Резервов не хватает на выплату процентов: недостаёт %s GOLD", s)
+	}
+	return c.Send(res)
+}
+
+func (h *Handlers) treasuryFund(c telebot.Context) error {
+	if !h.can(c, bank.PermFinance) {
+		return nil
+	}
+	args := c.Args()
+	if len(args) < 1 {
+		return c.Send("⚠️ Формат: /treasury_fund [Сумма] [Комментарий]\nОтрицательная сумма изымает средства из казны.")
+	}
+	v, err := money.Parse(args[0])
+	if err != nil {
+		return c.Send("❌ Сумма: " + err.Error())
+	}
+	if err := h.bank.FundTreasury(senderID(c), v, strings.Join(args[1:], " ")); err != nil {
+		if errors.Is(err, bank.ErrBadAmount) || errors.Is(err, bank.ErrAmountTooLarge) {
+			return c.Send("❌ " + err.Error())
+		}
+		return c.Send("❌ Ошибка БД")
+	}
+	return c.Send(fmt.Sprintf("✅ Казна: %s GOLD", v.Signed()))
+}
+
+func (h *Handlers) deposit(c telebot.Context) error {
+	if !h.can(c, bank.PermFinance) {
+		return nil
+	}
+	args := c.Args()
+	if len(args) < 2 {
+		return c.Send("⚠️ Формат: /deposit [ID] [Сумма]")
+	}
+	if err := bank.CheckUserID(args[0]); err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+	v, err := parseAmount(args[1])
+	if err != nil {
+		return c.Send("❌ Сумма: " + err.Error())
+	}
+	err = h.bank.AdminDeposit(senderID(c), args[0], v)
+	if errors.Is(err, bank.ErrUnknownRecipient) {
+		return c.Send("❌ Игрок с таким ID не зарегистрирован")
+	}
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	return c.Send(fmt.Sprintf("✅ Баланс %s пополнен на %s", args[0], v))
+}
+
+func (h *Handlers) settings(c telebot.Context) error {
+	if h.bank.Role(senderID(c)) == "" {
+		return nil
+	}
+	settings, err := h.bank.Settings()
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+
+	res := "⚙️ Настройки:\n\n"
+	for _, s := range settings {
+		res += fmt.Sprintf("%s = %s\n%s (по умолчанию %s)\n\n", s.Key, s.Value, s.Title, s.Default)
+	}
+	return c.Send(res + "Изменить: /setting [ключ] [значение]")
+}
+
+func (h *Handlers) setting(c telebot.Context) error {
+	if !h.can(c, bank.PermSettings) {
+		return nil
+	}
+	args := c.Args()
+	if len(args) < 2 {
+		return c.Send("⚠️ Формат: /setting [ключ] [значение]")
+	}
+	err := h.bank.SetSetting(senderID(c), args[0], args[1])
+	if errors.Is(err, bank.ErrUnknownSetting) || errors.Is(err, bank.ErrBadSetting) {
+		return c.Send("❌ " + err.Error())
+	}
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	return c.Send(fmt.Sprintf("✅ %s = %s", args[0], args[1]))
+}
+
+var feeOpTitles = map[string]string{
+	bank.FeeTransfer: "💸 Переводы",
+	bank.FeeWithdraw: "🏧 Вывод",
+	bank.FeeSellBond: "📉 Продажа вкладов",
+}
+
+// fees показывает тарифы комиссий, а с аргументами меняет их:
+// /fees [операция] [тариф], /fees free [роли администраторов] или
+// /fees free_players [роли игроков].
+func (h *Handlers) fees(c telebot.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		if h.bank.Role(senderID(c)) == "" {
+			return nil
+		}
+		f, err := h.bank.Fees()
+		if err != nil {
+			return c.Send("❌ Ошибка БД")
+		}
+		res := "💳 Комиссии банка:\n\n"
+		for _, op := range []string{bank.FeeTransfer, bank.FeeWithdraw, bank.FeeSellBond} {
+			res += fmt.Sprintf("%s (%s): %s\n", feeOpTitles[op], op, f.Schedules[op])
+		}
+		res += fmt.Sprintf("\n🎟 Без комиссий: администраторы — %s, игроки — %s\n\n⚙️ Изменить: /fees [операция] [тариф]\nТариф: 0, 5, 1.5%% или ступени 0:1%%,1000:0.5%%\nРоли: /fees free [роль,роль] — администраторы, /fees free_players [роль,роль] — игроки",
+			rolesTitle(f.FreeRoles), rolesTitle(f.FreePlayerRoles))
+		return c.Send(res)
+	}
+	if !h.can(c, bank.PermSettings) {
+		return nil
+	}
+	value := strings.Join(args[1:], "")
+	var err error
+	freeKeys := map[string]string{"free": bank.SettingFeeFreeRoles, "free_players": bank.SettingFeeFreePlayerRoles}
+	if key, ok := freeKeys[args[0]]; ok {
+		err = h.bank.SetSetting(senderID(c), key, value)
+	} else {
+		if len(args) < 2 {
+			return c.Send("⚠️ Формат: /fees [операция] [тариф]")
+		}
+		err = h.bank.SetFee(senderID(c), args[0], value)
+	}
+	if errors.Is(err, bank.ErrUnknownFeeOp) || errors.Is(err, bank.ErrBadSetting) {
+		return c.Send("❌ " + err.Error())
+	}
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	if args[0] == "free" {
+		return c.Send("✅ Роли администраторов без комиссий: " + value)
+	}
+	if args[0] == "free_players" {
+		return c.Send("✅ Роли игроков без комиссий: " + value)
+	}
+	s, _ := bank.ParseFeeSchedule(value)
+	return c.Send(fmt.Sprintf("✅ %s: %s", feeOpTitles[args[0]], s))
+}
+
+// rolesTitle — список ролей для сообщений.
+func rolesTitle(roles []string) string {
+	if len(roles) == 0 {
+		return "нет"
+	}
+	return strings.Join(roles, ", ")
+}
+
+var limitTitles = map[string]string{
+	bank.LimitTransfer: "💸 Один перевод",
+	bank.LimitDaily:    "📅 За сутки",
+	bank.LimitPending:  "🏧 Ожидающие выводы",
+}
+
+// setLimit показывает и меняет ограничения игроков:
+// /set_limit [all|role:роль|ID] — ограничения области,
+// /set_limit [all|role:роль|ID] [transfer|daily|pending] [сумма|off].
+func (h *Handlers) setLimit(c telebot.Context) error {
+	if !h.can(c, bank.PermSettings) {
+		return nil
+	}
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send("⚠️ Формат: /set_limit [all|role:роль|ID] [transfer|daily|pending] [сумма|off]\n" +
+			"Лимит игрока важнее лимита роли, лимит роли — общего. Операции сверх лимита уходят на одобрение.")
+	}
+	if len(args) == 1 {
+		limits, err := h.bank.Limits(args[0])
+		if errors.Is(err, bank.ErrBadLimitScope) {
+			return c.Send("❌ " + err.Error())
+		}
+		if err != nil {
+			return c.Send("❌ Ошибка БД")
+		}
+		if len(limits) == 0 {
+			return c.Send("📭 Лимитов для " + args[0] + " нет")
+		}
+		res := "🚧 Лимиты для " + args[0] + ":\n"
+		for _, l := range limits {
+			res += fmt.Sprintf("%s (%s): %s GOLD\n", limitTitles[l.Name], l.Name, l.Value)
+		}
+		return c.Send(res)
+	}
+	if len(args) < 3 {
+		return c.Send("⚠️ Формат: /set_limit [all|role:роль|ID] [transfer|daily|pending] [сумма|off]")
+	}
+
+	if args[2] == "off" {
+		removed, err := h.bank.DeleteLimit(args[0], args[1])
+		if errors.Is(err, bank.ErrBadLimitScope) || errors.Is(err, bank.ErrUnknownLimit) {
+			return c.Send("❌ " + err.Error())
+		}
+		if err != nil {
+			return c.Send("❌ Ошибка БД")
+		}
+		if !removed {
+			return c.Send("ℹ️ Такого лимита не было")
+		}
+		return c.Send(fmt.Sprintf("✅ %s для %s: без лимита", limitTitles[args[1]], args[0]))
+	}
+	value, err := parseAmount(args[2])
+	if err != nil {
+		return c.Send("❌ Сумма: " + err.Error())
+	}
+	err = h.bank.SetLimit(senderID(c), args[0], args[1], value)
+	if errors.Is(err, bank.ErrBadLimitScope) || errors.Is(err, bank.ErrUnknownLimit) {
+		return c.Send("❌ " + err.Error())
+	}
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	return c.Send(fmt.Sprintf("✅ %s для %s: %s GOLD", limitTitles[args[1]], args[0], value))
+}
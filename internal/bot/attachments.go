@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"gopkg.in/telebot.v3"
+
+	"mybot/internal/bank"
+	"mybot/internal/storage"
+)
+
+// depositMarkup — кнопки решения по заявке на пополнение.
+func depositMarkup(id int64) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	btnApprove := markup.Data("✅ Подтвердить", "approve_deposit", fmt.Sprintf("approve_deposit:%d", id))
+	btnReject := markup.Data("❌ Отклонить", "reject_deposit", fmt.Sprintf("reject_deposit:%d", id))
+	markup.Inline(markup.Row(btnApprove, btnReject))
+	return markup
+}
+
+// attachmentMessage — приложенный файл для повторной отправки по file_id.
+func attachmentMessage(a storage.Attachment, caption string) interface{} {
+	if a.FileType == storage.FileDocument {
+		return &telebot.Document{File: telebot.File{FileID: a.FileID}, Caption: caption}
+	}
+	return &telebot.Photo{File: telebot.File{FileID: a.FileID}, Caption: caption}
+}
+
+// onMedia принимает скриншот или файл игрока и прикладывает его к последней
+// заявке на пополнение или открытой жалобе, а затем пересылает администрации
+// вместе с кнопками решения.
+func (h *Handlers) onMedia(c telebot.Context) error {
+	m := c.Message()
+	var fileID, fileType string
+	switch {
+	case m.Photo != nil:
+		fileID, fileType = m.Photo.FileID, storage.FilePhoto
+	case m.Document != nil:
+		fileID, fileType = m.Document.FileID, storage.FileDocument
+	default:
+		return nil
+	}
+	uid := senderID(c)
+	if h.bank.IsBanned(uid) {
+		return c.Send("🚫 Ваш аккаунт заблокирован.")
+	}
+
+	e, err := h.bank.AttachEvidence(uid, fileID, fileType)
+	if errors.Is(err, bank.ErrNoEvidenceTarget) {
+		return c.Send("ℹ️ Файл не к чему приложить: сначала создайте заявку на пополнение или жалобу в приложении.")
+	}
+	if err != nil {
+		log.Println("❌ Ошибка сохранения файла:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+
+	nick := uid
+	if u, err := h.bank.User(uid); err == nil {
+		nick = u.Nick
+	}
+	if e.OwnerKind == storage.AttachRequest {
+		caption := fmt.Sprintf("📸 СКРИНШОТ К ЗАЯВКЕ НА ПОПОЛНЕНИЕ #%d\n👤 От: %s (ID: %s)\n💰 Сумма: %s GOLD", e.Request.ID, nick, uid, e.Request.Amount)
+		h.notifyAdmins(bank.PermFinance, attachmentMessage(e.Attachment, caption), depositMarkup(e.Request.ID))
+		return c.Send(fmt.Sprintf("✅ Скриншот приложен к заявке #%d. Администратор проверит его и зачислит средства.", e.Request.ID))
+	}
+
+	caption := fmt.Sprintf("📎 ФАЙЛ К ОБРАЩЕНИЮ #%d\n👤 От: %s (ID: %s)", e.Ticket.ID, nick, uid)
+	if e.Ticket.AssignedTo != "" {
+		h.notify(e.Ticket.AssignedTo, attachmentMessage(e.Attachment, caption), ticketMarkup(e.Ticket.ID))
+	} else {
+		h.notifyAdmins(bank.PermComplaints, attachmentMessage(e.Attachment, caption), ticketMarkup(e.Ticket.ID))
+	}
+	return c.Send(fmt.Sprintf("✅ Файл приложен к обращению #%d.", e.Ticket.ID))
+}
+
+// sendAttachments отправляет администратору файлы заявки или обращения.
+func (h *Handlers) sendAttachments(c telebot.Context, ownerKind string, ownerID int64) {
+	files, err := h.bank.Attachments(ownerKind, ownerID)
+	if err != nil {
+		log.Println("❌ Ошибка получения файлов:", err)
+		return
+	}
+	for i, a := range files {
+		c.Send(attachmentMessage(a, fmt.Sprintf("📎 Файл %d из %d", i+1, len(files))))
+	}
+}
@@ -0,0 +1,282 @@
+// Package bot — обработчики Telegram-команд, кнопок и данных WebApp поверх
+// bank.Bank. Исходящие сообщения идут через Sender, чтобы обработчики можно
+// было проверять в тестах без Telegram.
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"mybot/internal/bank"
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+// Sender — часть *telebot.Bot, через которую бот пишет пользователям.
+type Sender interface {
+	Send(to telebot.Recipient, what interface{}, opts ...interface{}) (*telebot.Message, error)
+	Edit(msg telebot.Editable, what interface{}, opts ...interface{}) (*telebot.Message, error)
+}
+
+type Handlers struct {
+	bank      *bank.Bank
+	tg        Sender
+	webAppURL string
+
+	// treasuryShort — казна уже в дефиците и администрация предупреждена;
+	// воркер напоминает снова только после того, как дефицит закрыли.
+	treasuryShort bool
+
+	// wake будит воркер рассылок сразу после /broadcast, не дожидаясь тика.
+	wake chan struct{}
+	// pause — пауза между сообщениями рассылки и ожидание после flood-wait,
+	// прерываемые остановкой бота; в тестах подменяется, чтобы не ждать
+	// по-настоящему.
+	pause func(ctx context.Context, d time.Duration)
+}
+
+func New(b *bank.Bank, tg Sender, webAppURL string) *Handlers {
+	return &Handlers{bank: b, tg: tg, webAppURL: webAppURL, wake: make(chan struct{}, 1), pause: sleep}
+}
+
+// sleep ждёт d или остановки бота — что наступит раньше.
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// Register подключает обработчики к боту.
+func (h *Handlers) Register(tb *telebot.Bot) {
+	tb.Use(h.touch)
+	tb.Handle(telebot.OnCallback, h.onCallback)
+
+	tb.Handle("/add_admin", h.addAdmin)
+	tb.Handle("/remove_admin", h.removeAdmin)
+	tb.Handle("/admins", h.admins)
+	tb.Handle("/set_info", h.setInfo)
+	tb.Handle("/broadcast", h.broadcast)
+	tb.Handle("/broadcast_cancel", h.broadcastCancel)
+	tb.Handle("/schedule", h.schedule)
+	tb.Handle("/schedules", h.schedules)
+	tb.Handle("/unschedule", h.unschedule)
+	tb.Handle("/ban", h.ban)
+	tb.Handle("/unban", h.unban)
+	tb.Handle("/create_bond", h.createBond)
+	tb.Handle("/edit_bond", h.editBond)
+	tb.Handle("/pause_bond", h.pauseBond)
+	tb.Handle("/resume_bond", h.resumeBond)
+	tb.Handle("/retire_bond", h.retireBond)
+	tb.Handle("/market", h.market)
+	tb.Handle("/all_bonds", h.allBonds)
+	tb.Handle("/set_lock", h.setLock)
+	tb.Handle("/cash_all_file", h.cashAllFile)
+	tb.Handle("/ledger", h.ledger)
+	tb.Handle("/reconcile", h.reconcile)
+	tb.Handle("/deposit", h.deposit)
+	tb.Handle("/treasury", h.treasury)
+	tb.Handle("/treasury_fund", h.treasuryFund)
+	tb.Handle("/settings", h.settings)
+	tb.Handle("/setting", h.setting)
+	tb.Handle("/fees", h.fees)
+	tb.Handle("/set_limit", h.setLimit)
+	tb.Handle("/tickets", h.tickets)
+	tb.Handle("/ticket", h.ticket)
+
+	tb.Handle("/history", h.history)
+	tb.Handle("/start", h.start)
+	tb.Handle(telebot.OnWebApp, h.onWebApp)
+	tb.Handle(telebot.OnText, h.onText)
+	tb.Handle(telebot.OnPhoto, h.onMedia)
+	tb.Handle(telebot.OnDocument, h.onMedia)
+}
+
+// touch запоминает время последней активности игрока для рассылок по
+// активным игрокам и возвращает в рассылки игрока, который блокировал бота:
+// раз он пишет, блокировки больше нет.
+func (h *Handlers) touch(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(c telebot.Context) error {
+		if c.Sender() != nil {
+			if err := h.bank.Touch(senderID(c)); err != nil {
+				log.Println("❌ Ошибка сохранения активности:", err)
+			}
+		}
+		return next(c)
+	}
+}
+
+var roleTitles = map[string]string{
+	bank.RoleOwner:     "👑 владелец",
+	bank.RoleFinance:   "💰 финансы",
+	bank.RoleModerator: "🛡 модератор",
+	bank.RoleSupport:   "💬 поддержка",
+}
+
+var statusTitles = map[string]string{
+	storage.StatusPending:   "⏳ ожидает",
+	storage.StatusApproved:  "✅ одобрена",
+	storage.StatusRejected:  "❌ отклонена",
+	storage.StatusExpired:   "⌛ истекла",
+	storage.StatusCancelled: "🚫 отменена",
+	storage.StatusReview:    "🔎 на проверке",
+}
+
+var requestTitles = map[string]string{
+	storage.RequestWithdraw: "вывод",
+	storage.RequestDeposit:  "пополнение",
+}
+
+var txTitles = map[string]string{
+	storage.TxOpening:      "📂 Входящий остаток",
+	storage.TxTransfer:     "💸 Перевод",
+	storage.TxBuyBond:      "📈 Покупка облигации",
+	storage.TxSellBond:     "💰 Закрытие вклада",
+	storage.TxWithdraw:     "🏧 Вывод",
+	storage.TxDeposit:      "💳 Пополнение",
+	storage.TxAdminDeposit: "💳 Пополнение администрацией",
+	storage.TxCoupon:       "🎟 Купон по вкладу",
+	storage.TxBondTrade:    "🤝 Сделка с вкладом",
+	storage.TxBondPartial:  "💰 Частичное снятие со вклада",
+	storage.TxBondTopUp:    "📈 Пополнение вклада",
+	storage.TxTreasury:     "🏦 Казна банка",
+}
+
+var interestTitles = map[string]string{
+	storage.InterestDaily:   "сложный %, ежедневно",
+	storage.InterestSimple:  "простой %, ежедневно",
+	storage.InterestMonthly: "сложный %, раз в 30 дней",
+	storage.InterestCoupon:  "купон раз в 30 дней",
+}
+
+// earlyTitle — условия досрочного закрытия для сообщений.
+func earlyTitle(policy string, penaltyPct float64) string {
+	switch policy {
+	case storage.EarlyForfeit:
+		return "досрочно — без процентов"
+	case storage.EarlyPenalty:
+		return fmt.Sprintf("досрочно — штраф %.2f%%", penaltyPct)
+	}
+	return "досрочно — запрещено"
+}
+
+// termTitle — срок облигации для сообщений.
+func termTitle(days int, autoRedeem bool) string {
+	if days <= 0 {
+		return "бессрочно"
+	}
+	res := fmt.Sprintf("%d дн.", days)
+	if autoRedeem {
+		res += ", автопогашение"
+	}
+	return res
+}
+
+// limitsTitle — лимиты выпуска облигации для сообщений.
+func limitsTitle(p storage.Product) string {
+	var parts []string
+	if p.MaxSupply > 0 {
+		parts = append(parts, fmt.Sprintf("выпуск %s GOLD", p.MaxSupply))
+	}
+	if p.PerUserMax > 0 {
+		parts = append(parts, fmt.Sprintf("до %s GOLD на игрока", p.PerUserMax))
+	}
+	if !p.OpensAt.IsZero() {
+		parts = append(parts, "с "+p.OpensAt.Format("02.01.2006"))
+	}
+	if !p.ClosesAt.IsZero() {
+		parts = append(parts, "до "+p.ClosesAt.Format("02.01.2006"))
+	}
+	if len(parts) == 0 {
+		return "без лимитов"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// feeLine — строка о комиссии для сообщений; пустая, если комиссии нет.
+func feeLine(fee money.Money) string {
+	if fee <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n💳 Комиссия: %s GOLD", fee)
+}
+
+// breachLine — строка о превышенном лимите для сообщений администрации.
+func breachLine(l *bank.LimitBreach) string {
+	if l == nil {
+		return ""
+	}
+	return "\n🚧 Превышен " + l.String()
+}
+
+// editText меняет текст сообщения с кнопками; у фото и файлов, которые
+// бот пересылает администрации, меняется подпись.
+func editText(c telebot.Context, text string, opts ...interface{}) error {
+	if m := c.Message(); m != nil && (m.Photo != nil || m.Document != nil) {
+		return c.EditCaption(text, opts...)
+	}
+	return c.Edit(text, opts...)
+}
+
+func senderID(c telebot.Context) string {
+	return strconv.FormatInt(c.Sender().ID, 10)
+}
+
+func (h *Handlers) can(c telebot.Context, p bank.Permission) bool {
+	return h.bank.Can(senderID(c), p)
+}
+
+// notify отправляет сообщение игроку по его tg_id.
+func (h *Handlers) notify(uid string, what interface{}, opts ...interface{}) error {
+	id, err := strconv.ParseInt(uid, 10, 64)
+	if err != nil {
+		return err
+	}
+	_, err = h.tg.Send(&telebot.User{ID: id}, what, opts...)
+	return err
+}
+
+// notifyAdmins отправляет сообщение каждому администратору с правом p.
+func (h *Handlers) notifyAdmins(p bank.Permission, what interface{}, opts ...interface{}) {
+	ids, err := h.bank.AdminsWith(p)
+	if err != nil {
+		log.Println("❌ Ошибка получения списка администраторов:", err)
+		return
+	}
+	for _, id := range ids {
+		h.notify(id, what, opts...)
+	}
+}
+
+// webAppMenu — клавиатура с кнопкой WebApp. Список игроков, рынок и баланс
+// передаются фронтенду прямо в URL.
+func (h *Handlers) webAppMenu(uid string, exists bool, nick, role string) *telebot.ReplyMarkup {
+	uL := []storage.User{}
+	if users, err := h.bank.Users(); err == nil {
+		uL = append(uL, users...)
+	}
+	uJ, _ := json.Marshal(uL)
+
+	mL := []bank.MarketItem{}
+	if market, err := h.bank.Market(uid); err == nil {
+		mL = append(mL, market...)
+	}
+	mJ, _ := json.Marshal(mL)
+
+	bal, _ := h.bank.Balance(uid)
+	fURL := fmt.Sprintf("%s?tg_id=%s&exists=%t&nick=%s&role=%s&bal=%s&users=%s&market=%s",
+		h.webAppURL, uid, exists, url.QueryEscape(nick), url.QueryEscape(role), bal,
+		url.QueryEscape(string(uJ)), url.QueryEscape(string(mJ)))
+
+	menu := &telebot.ReplyMarkup{ResizeKeyboard: true}
+	menu.Reply(menu.Row(menu.WebApp("🇸🇪 Открыть банк", &telebot.WebApp{URL: fURL})))
+	return menu
+}
@@ -0,0 +1,739 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"mybot/internal/bank"
+	"mybot/internal/money"
+	"mybot/internal/storage"
+	"mybot/internal/storage/memory"
+)
+
+type sent struct {
+	to   string
+	what interface{}
+	opts []interface{}
+}
+
+// fakeSender запоминает сообщения вместо отправки в Telegram.
+// Получателям из fail вместо доставки возвращается ошибка.
+type fakeSender struct {
+	sent  []sent
+	edits []sent
+	fail  map[string]error
+}
+
+func (s *fakeSender) Send(to telebot.Recipient, what interface{}, opts ...interface{}) (*telebot.Message, error) {
+	if err := s.fail[to.Recipient()]; err != nil {
+		return nil, err
+	}
+	s.sent = append(s.sent, sent{to.Recipient(), what, opts})
+	return &telebot.Message{ID: len(s.sent)}, nil
+}
+
+func (s *fakeSender) Edit(msg telebot.Editable, what interface{}, opts ...interface{}) (*telebot.Message, error) {
+	_, chatID := msg.MessageSig()
+	s.edits = append(s.edits, sent{fmt.Sprint(chatID), what, opts})
+	return &telebot.Message{}, nil
+}
+
+func (s *fakeSender) to(uid string) []string {
+	var res []string
+	for _, m := range s.sent {
+		if text, ok := m.what.(string); ok && m.to == uid {
+			res = append(res, text)
+		}
+	}
+	return res
+}
+
+// fakeContext — telebot.Context с отправителем, аргументами и callback;
+// ответы обработчика собираются в replies, edits и responses.
+type fakeContext struct {
+	telebot.Context
+	sender   *telebot.User
+	args     []string
+	callback *telebot.Callback
+	message  *telebot.Message
+
+	replies   []string
+	edits     []string
+	responses []*telebot.CallbackResponse
+}
+
+func (c *fakeContext) Sender() *telebot.User       { return c.sender }
+func (c *fakeContext) Args() []string              { return c.args }
+func (c *fakeContext) Callback() *telebot.Callback { return c.callback }
+func (c *fakeContext) Message() *telebot.Message   { return c.message }
+
+func (c *fakeContext) Send(what interface{}, opts ...interface{}) error {
+	c.replies = append(c.replies, fmt.Sprint(what))
+	return nil
+}
+
+func (c *fakeContext) Edit(what interface{}, opts ...interface{}) error {
+	c.edits = append(c.edits, fmt.Sprint(what))
+	return nil
+}
+
+func (c *fakeContext) EditCaption(caption string, opts ...interface{}) error {
+	c.edits = append(c.edits, caption)
+	return nil
+}
+
+func (c *fakeContext) Respond(resp ...*telebot.CallbackResponse) error {
+	c.responses = append(c.responses, resp...)
+	return nil
+}
+
+const ownerID = 1
+
+func newTestHandlers(t *testing.T) (*Handlers, *bank.Bank, *fakeSender) {
+	t.Helper()
+	b := bank.New(memory.New(), "1")
+	if err := b.SeedOwners(); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []struct{ id, nick string }{{"100", "alice"}, {"200", "bob"}} {
+		if err := b.Register(u.id, u.nick, "player"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tg := &fakeSender{}
+	return New(b, tg, "https://example.org/app/"), b, tg
+}
+
+func command(from int64, args ...string) *fakeContext {
+	return &fakeContext{sender: &telebot.User{ID: from}, args: args}
+}
+
+func webApp(from int64, data string) *fakeContext {
+	return &fakeContext{sender: &telebot.User{ID: from}, message: &telebot.Message{WebAppData: &telebot.WebAppData{Data: data}}}
+}
+
+func press(from int64, data string) *fakeContext {
+	return &fakeContext{sender: &telebot.User{ID: from}, callback: &telebot.Callback{Data: "\f" + data}}
+}
+
+func last(s []string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return s[len(s)-1]
+}
+
+func TestDepositRequiresPermission(t *testing.T) {
+	h, b, _ := newTestHandlers(t)
+
+	c := command(100, "100", "50")
+	if err := h.deposit(c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.replies) != 0 {
+		t.Errorf("player got reply %q", c.replies)
+	}
+
+	c = command(ownerID, "100", "50,5")
+	h.deposit(c)
+	if got, _ := b.Balance("100"); got != 5050 {
+		t.Errorf("balance = %s, reply %q", got, c.replies)
+	}
+}
+
+func TestWebAppTransferNotifiesReceiver(t *testing.T) {
+	h, b, tg := newTestHandlers(t)
+	b.AdminDeposit("1", "100", money.FromInt(10))
+
+	c := webApp(100, `{"action":"transfer","target":"@Bob","amount":4,"comment":"за ужин"}`)
+	if err := h.onWebApp(c); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(last(c.replies), "bob") || !strings.Contains(last(c.replies), "за ужин") {
+		t.Fatalf("confirmation = %q", c.replies)
+	}
+	if bal, _ := b.Balance("200"); bal != 0 || len(tg.to("200")) != 0 {
+		t.Fatal("money moved before confirmation")
+	}
+
+	// Чужой перевод подтвердить нельзя.
+	c = press(200, "confirm_transfer|confirm_transfer:1")
+	h.onCallback(c)
+	if bal, _ := b.Balance("200"); bal != 0 {
+		t.Fatalf("receiver confirmed the transfer: balance %s", bal)
+	}
+
+	c = press(100, "confirm_transfer|confirm_transfer:1")
+	h.onCallback(c)
+	if !strings.Contains(last(c.edits), "bob") {
+		t.Errorf("edit = %q", c.edits)
+	}
+	if msg := last(tg.to("200")); !strings.Contains(msg, "alice") || !strings.Contains(msg, "4.00") || !strings.Contains(msg, "за ужин") {
+		t.Errorf("receiver message = %q", msg)
+	}
+	h.onCallback(c)
+	if bal, _ := b.Balance("200"); bal != money.FromInt(4) {
+		t.Errorf("double confirmation: receiver balance %s", bal)
+	}
+
+	for data, want := range map[string]string{
+		`{"action":"transfer","target_id":"200","amount":7}`:    "❌ Недостаточно средств для перевода",
+		`{"action":"transfer","target":"carol","amount":1}`:     "❌ " + bank.ErrUnknownRecipient.Error(),
+		`{"action":"transfer","target":"alice","amount":1}`:     "❌ " + bank.ErrSelfTransfer.Error(),
+		`{"action":"transfer","target_id":"100500","amount":1}`: "❌ " + bank.ErrUnknownRecipient.Error(),
+	} {
+		c = webApp(100, data)
+		h.onWebApp(c)
+		if last(c.replies) != want {
+			t.Errorf("%s: reply = %q", data, c.replies)
+		}
+	}
+}
+
+func TestWithdrawFlow(t *testing.T) {
+	h, b, tg := newTestHandlers(t)
+	b.AdminDeposit("1", "100", money.FromInt(10))
+
+	h.onWebApp(webApp(100, `{"action":"withdraw","nick":"alice","amount":3}`))
+	if msg := last(tg.to("1")); !strings.Contains(msg, "ЗАПРОС НА ВЫВОД #1") {
+		t.Fatalf("admin notification = %q", msg)
+	}
+
+	// Игрок без прав не может одобрить заявку.
+	c := press(200, "approve|approve:1")
+	h.onCallback(c)
+	if len(c.responses) != 1 || !strings.Contains(c.responses[0].Text, "Недостаточно прав") {
+		t.Errorf("responses = %+v", c.responses)
+	}
+
+	c = press(ownerID, "approve|approve:1")
+	h.onCallback(c)
+	if !strings.HasPrefix(last(c.edits), "✅ ОДОБРЕНО") {
+		t.Errorf("edits = %q", c.edits)
+	}
+	if got, _ := b.Balance("100"); got != money.FromInt(7) {
+		t.Errorf("balance = %s", got)
+	}
+	if msg := last(tg.to("100")); !strings.Contains(msg, "Вывод одобрен") {
+		t.Errorf("player message = %q", msg)
+	}
+
+	// Повторное нажатие не списывает деньги второй раз.
+	c = press(ownerID, "reject|reject:1")
+	h.onCallback(c)
+	if !strings.Contains(last(c.edits), "уже обработана") {
+		t.Errorf("edits = %q", c.edits)
+	}
+	if got, _ := b.Balance("100"); got != money.FromInt(7) {
+		t.Errorf("balance after second press = %s", got)
+	}
+}
+
+func TestLegacyCallback(t *testing.T) {
+	h, _, _ := newTestHandlers(t)
+	c := press(ownerID, "approve|approve:100:5.00")
+	h.onCallback(c)
+	if !strings.Contains(last(c.edits), "Устаревшая заявка") {
+		t.Errorf("edits = %q", c.edits)
+	}
+}
+
+func TestExpireRequestsNotifies(t *testing.T) {
+	h, b, tg := newTestHandlers(t)
+	r, _ := b.RequestMoney("100", storage.RequestDeposit, money.FromInt(5))
+	b.Now = func() time.Time { return r.CreatedAt.Add(bank.RequestTTL + time.Minute) }
+
+	h.expireRequests()
+	if msg := last(tg.to("100")); !strings.Contains(msg, "истекла") {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestEditBond(t *testing.T) {
+	h, b, _ := newTestHandlers(t)
+	p, err := b.CreateProduct("1", storage.Product{Name: "SE-1", Price: money.FromInt(10), Rate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := command(100, fmt.Sprint(p.ID), "rate=5")
+	h.editBond(c)
+	if len(c.replies) != 0 {
+		t.Errorf("player got reply %q", c.replies)
+	}
+
+	c = command(ownerID, fmt.Sprint(p.ID), "rate=1.5", "supply=500")
+	h.editBond(c)
+	if reply := last(c.replies); !strings.Contains(reply, "rate: 1 → 1.5") || !strings.Contains(reply, "max_supply: 0.00 → 500.00") {
+		t.Errorf("edit reply = %q", reply)
+	}
+
+	c = command(ownerID, fmt.Sprint(p.ID), "model=weekly")
+	h.editBond(c)
+	if reply := last(c.replies); !strings.Contains(reply, bank.ErrBadModel.Error()) {
+		t.Errorf("bad model reply = %q", reply)
+	}
+
+	h.retireBond(command(ownerID, fmt.Sprint(p.ID)))
+	c = command(ownerID, fmt.Sprint(p.ID))
+	h.market(c)
+	if reply := last(c.replies); !strings.Contains(reply, "снята с рынка") || !strings.Contains(reply, "rate 1 → 1.5") {
+		t.Errorf("market card = %q", reply)
+	}
+}
+
+func TestBuyListingNotifiesSeller(t *testing.T) {
+	h, b, tg := newTestHandlers(t)
+	b.AdminDeposit("1", "100", money.FromInt(50))
+	b.AdminDeposit("1", "200", money.FromInt(50))
+	p, _ := b.CreateProduct("1", storage.Product{Name: "SE-1", Price: money.FromInt(10), Rate: 1})
+	bond, _ := b.BuyBond("100", p.ID, money.FromInt(20))
+
+	c := webApp(100, fmt.Sprintf(`{"action":"list_bond","bond_id":%d,"amount":25}`, bond.ID))
+	h.onWebApp(c)
+	if !strings.Contains(last(c.replies), "🔒") {
+		t.Fatalf("list locked bond reply = %q", c.replies)
+	}
+	b.SetBondLock(bond.ID, true)
+	h.onWebApp(c)
+	listings, _ := b.Listings("200")
+	if len(listings) != 1 {
+		t.Fatalf("listings = %+v, reply %q", listings, c.replies)
+	}
+
+	c = webApp(200, fmt.Sprintf(`{"action":"buy_listing","listing_id":%d}`, listings[0].ID))
+	h.onWebApp(c)
+	if !strings.Contains(last(c.replies), "alice") {
+		t.Errorf("buyer reply = %q", c.replies)
+	}
+	if msg := last(tg.to("100")); !strings.Contains(msg, "bob") || !strings.Contains(msg, "25.00") {
+		t.Errorf("seller message = %q", msg)
+	}
+}
+
+func TestCheckTreasuryWarnsOnce(t *testing.T) {
+	h, b, tg := newTestHandlers(t)
+	b.FundTreasury("1", money.FromInt(100), "")
+	b.AdminDeposit("1", "100", money.FromInt(100))
+	p, _ := b.CreateProduct("1", storage.Product{Name: "SE-1", Price: money.FromInt(10), Rate: 1})
+	b.BuyBond("100", p.ID, money.FromInt(100))
+	start := time.Now()
+	b.Now = func() time.Time { return start.Add(3 * 24 * time.Hour) }
+
+	h.checkTreasury()
+	h.checkTreasury()
+	if msgs := tg.to("1"); len(msgs) != 1 || !strings.Contains(msgs[0], "ДЕФИЦИТ") {
+		t.Fatalf("owner messages = %q", msgs)
+	}
+
+	h.treasuryFund(command(ownerID, "10", "резерв"))
+	h.checkTreasury()
+	if !strings.Contains(last(tg.to("1")), "ДЕФИЦИТ") || len(tg.to("1")) != 1 || h.treasuryShort {
+		t.Errorf("after funding: messages %q, short %v", tg.to("1"), h.treasuryShort)
+	}
+}
+
+func TestFeesCommand(t *testing.T) {
+	h, b, _ := newTestHandlers(t)
+	b.AdminDeposit("1", "100", money.FromInt(100))
+
+	c := command(100, "transfer", "1%")
+	h.fees(c)
+	if len(c.replies) != 0 {
+		t.Fatalf("player changed fees: %q", c.replies)
+	}
+	c = command(ownerID, "transfer", "0:1%,", "1000:5")
+	h.fees(c)
+	if !strings.Contains(last(c.replies), "от 1000.00: 5.00 GOLD") {
+		t.Fatalf("set reply = %q", c.replies)
+	}
+	c = command(ownerID, "transfer", "abc")
+	h.fees(c)
+	if !strings.HasPrefix(last(c.replies), "❌") {
+		t.Errorf("bad schedule reply = %q", c.replies)
+	}
+
+	c = webApp(100, `{"action":"transfer","target_id":"200","amount":50}`)
+	h.onWebApp(c)
+	if !strings.Contains(last(c.replies), "Комиссия: 0.50 GOLD") {
+		t.Errorf("transfer reply = %q", c.replies)
+	}
+	c = command(ownerID, "free", "player")
+	h.fees(c)
+	if !strings.HasPrefix(last(c.replies), "❌") {
+		t.Errorf("player role among admin roles reply = %q", c.replies)
+	}
+	h.fees(command(ownerID, "free_players", "player"))
+	c = command(ownerID)
+	h.fees(c)
+	if !strings.Contains(last(c.replies), "transfer): от 0.00: 1%, от 1000.00: 5.00 GOLD") || !strings.Contains(last(c.replies), "игроки — player") {
+		t.Errorf("fees = %q", c.replies)
+	}
+}
+
+func TestWebAppRejectsInvalidInput(t *testing.T) {
+	h, b, _ := newTestHandlers(t)
+	b.AdminDeposit("1", "100", money.FromInt(10))
+
+	for data, want := range map[string]error{
+		`{"action":"transfer","target_id":"200","amount":-5}`: bank.ErrAmountNotPositive,
+		`{"action":"transfer","amount":5}`:                    bank.ErrNoRecipient,
+		`{"action":"transfer","target_id":"bob","amount":5}`:  bank.ErrBadUserID,
+		`{"action":"withdraw","amount":"0"}`:                  bank.ErrAmountNotPositive,
+		`{"action":"withdraw","amount":10000000000}`:          bank.ErrAmountTooLarge,
+		`{"action":"sell_bond"}`:                              bank.ErrBadBondID,
+		`{"action":"buy_listing","listing_id":-1}`:            bank.ErrBadListingID,
+	} {
+		c := webApp(100, data)
+		h.onWebApp(c)
+		if last(c.replies) != "❌ "+want.Error() {
+			t.Errorf("%s: reply = %q", data, c.replies)
+		}
+	}
+	if r, _ := b.PendingRequests("100"); len(r) != 0 {
+		t.Errorf("invalid requests were created: %+v", r)
+	}
+
+	c := command(ownerID, "200", "-5")
+	h.deposit(c)
+	if !strings.Contains(last(c.replies), bank.ErrAmountNotPositive.Error()) {
+		t.Errorf("/deposit reply = %q", c.replies)
+	}
+	c = command(ownerID, "300", "5")
+	h.deposit(c)
+	if !strings.Contains(last(c.replies), "не зарегистрирован") {
+		t.Errorf("/deposit unknown reply = %q", c.replies)
+	}
+	for name, cmd := range map[string]func(telebot.Context) error{"/ban": h.ban, "/unban": h.unban} {
+		c = command(ownerID, "bob")
+		cmd(c)
+		if last(c.replies) != "❌ "+bank.ErrBadUserID.Error() {
+			t.Errorf("%s reply = %q", name, c.replies)
+		}
+	}
+	c = command(ownerID, "1", "yes")
+	h.setLock(c)
+	if !strings.HasPrefix(last(c.replies), "⚠️") {
+		t.Errorf("/set_lock reply = %q", c.replies)
+	}
+}
+
+func TestTransferOverLimitNeedsApproval(t *testing.T) {
+	h, b, tg := newTestHandlers(t)
+	b.AdminDeposit("1", "100", money.FromInt(100))
+
+	c := command(100, "role:player", "transfer", "10")
+	h.setLimit(c)
+	if len(c.replies) != 0 {
+		t.Fatalf("player set a limit: %q", c.replies)
+	}
+	c = command(ownerID, "role:player", "transfer", "10")
+	h.setLimit(c)
+	if !strings.Contains(last(c.replies), "10.00 GOLD") {
+		t.Fatalf("set_limit reply = %q", c.replies)
+	}
+
+	h.onWebApp(webApp(100, `{"action":"transfer","target_id":"200","amount":30}`))
+	c = press(100, "confirm_transfer|confirm_transfer:1")
+	h.onCallback(c)
+	if !strings.Contains(last(c.edits), "на проверку") {
+		t.Fatalf("edit = %q", c.edits)
+	}
+	if msg := last(tg.to("1")); !strings.Contains(msg, "СВЕРХ ЛИМИТА #1") || !strings.Contains(msg, "на один перевод") {
+		t.Errorf("admin message = %q", msg)
+	}
+	if bal, _ := b.Balance("200"); bal != 0 {
+		t.Fatalf("transfer over limit went through: balance %s", bal)
+	}
+
+	c = press(100, "approve_transfer|approve_transfer:1")
+	h.onCallback(c)
+	if bal, _ := b.Balance("200"); bal != 0 {
+		t.Fatalf("sender approved own transfer: balance %s", bal)
+	}
+	c = press(ownerID, "approve_transfer|approve_transfer:1")
+	h.onCallback(c)
+	if bal, _ := b.Balance("200"); bal != money.FromInt(30) {
+		t.Errorf("approved: balance %s, edits %q", bal, c.edits)
+	}
+	if !strings.Contains(last(tg.to("100")), "одобрен") || !strings.Contains(last(tg.to("200")), "alice") {
+		t.Errorf("notifications: %q, %q", tg.to("100"), tg.to("200"))
+	}
+
+	c = command(ownerID, "role:player", "transfer", "off")
+	h.setLimit(c)
+	c = command(ownerID, "role:player")
+	h.setLimit(c)
+	if !strings.Contains(last(c.replies), "нет") {
+		t.Errorf("limits after off = %q", c.replies)
+	}
+}
+
+func TestTicketFlow(t *testing.T) {
+	h, _, tg := newTestHandlers(t)
+
+	c := webApp(100, `{"action":"complaint","nick":"alice","complaint":"не пришёл вывод"}`)
+	h.onWebApp(c)
+	if !strings.Contains(last(c.replies), "#1") || !strings.Contains(last(tg.to("1")), "НОВАЯ ЖАЛОБА #1") {
+		t.Fatalf("complaint reply %q, admin %q", c.replies, tg.to("1"))
+	}
+
+	c = press(ownerID, "reply_ticket|reply_ticket:1")
+	h.onCallback(c)
+	prompt := last(c.replies)
+	if !strings.HasPrefix(prompt, replyPrompt+"1") {
+		t.Fatalf("prompt = %q", c.replies)
+	}
+	c = &fakeContext{sender: &telebot.User{ID: ownerID}, message: &telebot.Message{Text: "уже проверяем", ReplyTo: &telebot.Message{Text: prompt}}}
+	h.onText(c)
+	if msg := last(tg.to("100")); !strings.Contains(msg, "#1") || !strings.Contains(msg, "уже проверяем") {
+		t.Errorf("player got %q", msg)
+	}
+
+	// Игрок не может отвечать за администрацию.
+	c = &fakeContext{sender: &telebot.User{ID: 100}, message: &telebot.Message{Text: "сам себе", ReplyTo: &telebot.Message{Text: prompt}}}
+	h.onText(c)
+	if len(c.replies) != 0 {
+		t.Errorf("player replied as admin: %q", c.replies)
+	}
+
+	h.onWebApp(webApp(100, `{"action":"ticket_reply","ticket_id":1,"complaint":"жду"}`))
+	if !strings.Contains(last(tg.to("1")), "ДОПОЛНЕНИЕ К ОБРАЩЕНИЮ #1") {
+		t.Errorf("assignee got %q", tg.to("1"))
+	}
+
+	c = command(ownerID, "1")
+	h.ticket(c)
+	if r := last(c.replies); !strings.Contains(r, "в работе") || !strings.Contains(r, "уже проверяем") || !strings.Contains(r, "жду") {
+		t.Errorf("ticket = %q", r)
+	}
+
+	h.onCallback(press(ownerID, "resolve_ticket|resolve_ticket:1"))
+	if !strings.Contains(last(tg.to("100")), "решено") {
+		t.Errorf("player got %q", tg.to("100"))
+	}
+	c = command(ownerID)
+	h.tickets(c)
+	if !strings.Contains(last(c.replies), "нет") {
+		t.Errorf("open tickets = %q", c.replies)
+	}
+}
+
+func TestDepositScreenshotGoesToAdmins(t *testing.T) {
+	h, b, tg := newTestHandlers(t)
+
+	c := webApp(100, `{"action":"deposit_request","nick":"alice","amount":25}`)
+	h.onWebApp(c)
+	if !strings.Contains(last(c.replies), "в этот чат") || !strings.Contains(last(c.replies), "24 ч") {
+		t.Fatalf("deposit reply = %q", c.replies)
+	}
+
+	c = &fakeContext{sender: &telebot.User{ID: 100}, message: &telebot.Message{Photo: &telebot.Photo{File: telebot.File{FileID: "shot"}}}}
+	h.onMedia(c)
+	if !strings.Contains(last(c.replies), "#1") {
+		t.Fatalf("media reply = %q", c.replies)
+	}
+	m := tg.sent[len(tg.sent)-1]
+	photo, ok := m.what.(*telebot.Photo)
+	if m.to != "1" || !ok || photo.FileID != "shot" || !strings.Contains(photo.Caption, "#1") {
+		t.Fatalf("admin got %+v", m)
+	}
+
+	c = press(ownerID, "approve_deposit|approve_deposit:1")
+	c.message = &telebot.Message{Photo: photo}
+	h.onCallback(c)
+	if bal, _ := b.Balance("100"); bal != money.FromInt(25) || !strings.Contains(last(c.edits), "ПОДТВЕРЖДЕНО") {
+		t.Errorf("balance after approval = %s, caption %q", bal, c.edits)
+	}
+}
+
+func TestBroadcastWorker(t *testing.T) {
+	h, b, tg := newTestHandlers(t)
+	var paused time.Duration
+	h.pause = func(_ context.Context, d time.Duration) { paused += d }
+	tg.fail = map[string]error{"200": telebot.ErrBlockedByUser}
+
+	c := command(100, "привет")
+	h.broadcast(c)
+	if len(tg.sent) != 0 {
+		t.Fatalf("player started a broadcast: %+v", tg.sent)
+	}
+
+	h.broadcast(command(ownerID, "турнир", "в", "субботу"))
+	if len(tg.sent) != 2 || !strings.Contains(tg.sent[1].what.(string), "Получателей: 2") {
+		t.Fatalf("preview = %+v", tg.sent)
+	}
+	h.processBroadcasts(context.Background())
+	if len(tg.to("100")) != 0 {
+		t.Fatal("draft sent before confirmation")
+	}
+	c = press(ownerID, "send_broadcast|send_broadcast:1")
+	h.onCallback(c)
+	if !strings.Contains(last(c.edits), "в очереди") {
+		t.Fatalf("confirm edits = %q", c.edits)
+	}
+	h.processBroadcasts(context.Background())
+
+	if got := last(tg.to("100")); got != "📢 ОБЪЯВЛЕНИЕ ОТ АДМИНИСТРАЦИИ:\n\nтурнир в субботу" {
+		t.Errorf("alice got %q", got)
+	}
+	progress := tg.edits[len(tg.edits)-1].what.(string)
+	if !strings.Contains(progress, "завершена") || !strings.Contains(progress, "Заблокировали бота: 1") {
+		t.Errorf("final progress = %q", progress)
+	}
+	if paused == 0 {
+		t.Error("worker does not pace messages")
+	}
+	if u, _ := b.User("200"); !u.BlockedBot {
+		t.Error("bob not marked as blocked")
+	}
+	// Любое сообщение от игрока возвращает его в рассылки, не только /start.
+	h.touch(func(telebot.Context) error { return nil })(command(200, "привет"))
+	if u, _ := b.User("200"); u.BlockedBot {
+		t.Error("bob still marked as blocked after writing to the bot")
+	}
+
+	c = command(ownerID)
+	h.broadcastCancel(c)
+	if !strings.Contains(last(c.replies), "нет активных") {
+		t.Errorf("cancel reply = %q", c.replies)
+	}
+}
+
+func TestBroadcastStopsDuringFloodWait(t *testing.T) {
+	h, b, tg := newTestHandlers(t)
+	ctx, stop := context.WithCancel(context.Background())
+	// Бота останавливают, пока он пережидает flood-wait.
+	h.pause = func(context.Context, time.Duration) { stop() }
+	tg.fail = map[string]error{"100": telebot.FloodError{RetryAfter: 60}}
+
+	seg, _, _ := bank.ParseSegment([]string{"ids=100"})
+	job, _ := b.StartBroadcast("1", storage.Broadcast{Text: "турнир"}, seg)
+	b.ConfirmBroadcast(job.ID)
+	h.processBroadcasts(ctx)
+
+	job, batch, err := b.BroadcastBatch(job.ID, 10)
+	if err != nil || job.Failed != 0 || len(batch) != 1 || batch[0].Attempts != 0 {
+		t.Errorf("after shutdown: job %+v, batch %+v, %v", job, batch, err)
+	}
+}
+
+func TestBroadcastSegmentsAndMedia(t *testing.T) {
+	h, b, tg := newTestHandlers(t)
+	h.pause = func(context.Context, time.Duration) {}
+
+	c := command(ownerID, "role=player", "balance=..10")
+	c.message = &telebot.Message{ReplyTo: &telebot.Message{
+		Photo:           &telebot.Photo{File: telebot.File{FileID: "poster"}},
+		Caption:         "Турнир!\n[Записаться](https://example.org/t)",
+		CaptionEntities: telebot.Entities{{Type: telebot.EntityBold, Offset: 0, Length: 7}, {Type: telebot.EntityItalic, Offset: 8, Length: 10}},
+	}}
+	b.AdminDeposit("1", "200", money.FromInt(50))
+	h.broadcast(c)
+	if len(tg.sent) != 2 || !strings.Contains(tg.sent[1].what.(string), "роль player; баланс до 10.00 GOLD") {
+		t.Fatalf("preview = %+v, replies %q", tg.sent, c.replies)
+	}
+	h.onCallback(press(ownerID, "send_broadcast|send_broadcast:1"))
+	h.processBroadcasts(context.Background())
+
+	m := tg.sent[len(tg.sent)-1]
+	photo, ok := m.what.(*telebot.Photo)
+	if m.to != "100" || !ok || photo.FileID != "poster" || photo.Caption != "📢 ОБЪЯВЛЕНИЕ ОТ АДМИНИСТРАЦИИ:\n\nТурнир!" {
+		t.Fatalf("alice got %+v", m)
+	}
+	var entities telebot.Entities
+	var markup *telebot.ReplyMarkup
+	for _, o := range m.opts {
+		switch o := o.(type) {
+		case telebot.Entities:
+			entities = o
+		case *telebot.ReplyMarkup:
+			markup = o
+		}
+	}
+	if len(entities) != 1 || entities[0].Offset != 33 || entities[0].Length != 7 {
+		t.Errorf("entities = %+v", entities)
+	}
+	if markup == nil || markup.InlineKeyboard[0][0].URL != "https://example.org/t" {
+		t.Errorf("markup = %+v", markup)
+	}
+	if got := tg.to("200"); len(got) != 0 {
+		t.Errorf("bob is outside the segment, got %q", got)
+	}
+
+	c = command(ownerID, "active=7", "привет")
+	h.broadcast(c)
+	if !strings.Contains(last(c.replies), "ни один игрок") {
+		t.Errorf("inactive players: reply %q", c.replies)
+	}
+	h.touch(func(telebot.Context) error { return nil })(command(100))
+	c = command(ownerID, "active=7", "привет")
+	h.broadcast(c)
+	if !strings.Contains(tg.sent[len(tg.sent)-1].what.(string), "Получателей: 1") {
+		t.Errorf("active players preview = %+v", tg.sent[len(tg.sent)-1])
+	}
+
+	c = command(ownerID, "bond=x", "привет")
+	h.broadcast(c)
+	if !strings.Contains(last(c.replies), "bond=x") {
+		t.Errorf("bad filter reply = %q", c.replies)
+	}
+}
+
+func TestScheduleCommands(t *testing.T) {
+	h, b, tg := newTestHandlers(t)
+	h.pause = func(context.Context, time.Duration) {}
+
+	c := command(ownerID, "in=1h", "every=7d", "role=player", "турнир", "в", "субботу")
+	h.schedule(c)
+	if !strings.Contains(last(c.replies), "каждые 7 дн.") || !strings.Contains(last(c.replies), "роль player") {
+		t.Fatalf("schedule reply = %q", c.replies)
+	}
+	if got := tg.to("1"); len(got) != 1 || got[0] != "📢 ОБЪЯВЛЕНИЕ ОТ АДМИНИСТРАЦИИ:\n\nтурнир в субботу" {
+		t.Errorf("preview = %q", got)
+	}
+
+	c = command(ownerID, "every=10m", "at=01.01.2020-10:00", "привет")
+	h.schedule(c)
+	if !strings.Contains(last(c.replies), "не чаще раза в час") {
+		t.Errorf("every 10m reply = %q", c.replies)
+	}
+	h.schedule(command(ownerID, "at=01.01.2020-10:00", "ids=100", "давно", "пора"))
+	h.schedule(command(ownerID, "info", "priority=3", "Скидки", "на", "вклады"))
+	if info, _ := b.InfoLine(); info != "Скидки на вклады" {
+		t.Errorf("info line = %q", info)
+	}
+
+	c = command(ownerID)
+	h.schedules(c)
+	if list := last(c.replies); strings.Count(list, "\n#") != 3 || !strings.Contains(list, "ℹ️") {
+		t.Errorf("schedules = %q", list)
+	}
+
+	h.startScheduled()
+	h.processBroadcasts(context.Background())
+	if got := last(tg.to("100")); got != "📢 ОБЪЯВЛЕНИЕ ОТ АДМИНИСТРАЦИИ:\n\nдавно пора" {
+		t.Errorf("alice got %q", got)
+	}
+	if len(tg.to("200")) != 0 {
+		t.Errorf("bob got %q", tg.to("200"))
+	}
+	if progress := tg.edits[len(tg.edits)-1].what.(string); !strings.Contains(progress, "завершена") {
+		t.Errorf("progress = %q", progress)
+	}
+
+	c = command(ownerID, "3")
+	h.unschedule(c)
+	h.unschedule(c)
+	if !strings.Contains(last(c.replies), "не найдена") {
+		t.Errorf("unschedule twice = %q", c.replies)
+	}
+	if info, _ := b.InfoLine(); info != "" {
+		t.Errorf("info after unschedule = %q", info)
+	}
+}
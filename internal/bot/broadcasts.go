@@ -0,0 +1,421 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"gopkg.in/telebot.v3"
+
+	"mybot/internal/bank"
+	"mybot/internal/storage"
+)
+
+const (
+	// broadcastBatch — сколько сообщений воркер берёт из очереди за раз;
+	// после каждой пачки обновляется сообщение с ходом рассылки.
+	broadcastBatch = 25
+	// broadcastDelay — пауза между сообщениями, чтобы не упираться в
+	// ограничение Telegram на частоту отправки.
+	broadcastDelay = 50 * time.Millisecond
+	// broadcastTick — как часто воркер проверяет очередь и расписание.
+	broadcastTick = 30 * time.Second
+)
+
+var broadcastStatusTitles = map[string]string{
+	storage.BroadcastDraft:     "👁 предпросмотр",
+	storage.BroadcastQueued:    "⏳ в очереди",
+	storage.BroadcastRunning:   "📤 идёт",
+	storage.BroadcastDone:      "✅ завершена",
+	storage.BroadcastCancelled: "🛑 отменена",
+}
+
+// broadcastHeader — начало каждого сообщения рассылки.
+const broadcastHeader = "📢 ОБЪЯВЛЕНИЕ ОТ АДМИНИСТРАЦИИ:\n\n"
+
+// linkLine — строка «[Текст](https://…)» в конце сообщения рассылки,
+// которая превращается в кнопку со ссылкой.
+var linkLine = regexp.MustCompile(`^\[([^\]]+)\]\((https?://\S+)\)$`)
+
+// linkButton — кнопка со ссылкой под сообщением рассылки.
+type linkButton struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+// broadcast готовит рассылку: /broadcast [фильтры] [сообщение] или ответом
+// на сообщение — /broadcast [фильтры]; тогда рассылается это сообщение с
+// фото или файлом, форматированием и кнопками. Администратор видит
+// предпросмотр и подтверждает отправку кнопкой, дальше рассылку доставляет
+// воркер, а ход обновляется в сообщении с предпросмотром.
+func (h *Handlers) broadcast(c telebot.Context) error {
+	if !h.can(c, bank.PermBroadcast) {
+		return nil
+	}
+	const usage = "⚠️ Формат: /broadcast [фильтры] [сообщение] или ответом на сообщение: /broadcast [фильтры]\n" +
+		"Фильтры: role=player balance=100..500 bond=3 active=7 ids=100,200"
+	seg, rest, err := bank.ParseSegment(c.Args())
+	if err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+
+	var msg storage.Broadcast
+	if m := c.Message(); m != nil && m.ReplyTo != nil {
+		var ok bool
+		if msg, ok = broadcastFrom(m.ReplyTo); !ok || len(rest) > 0 {
+			return c.Send(usage + "\n\nРассылать можно текст, фото и файлы.")
+		}
+	} else {
+		msg.Text = strings.Join(rest, " ")
+	}
+
+	job, err := h.bank.StartBroadcast(senderID(c), msg, seg)
+	switch {
+	case errors.Is(err, bank.ErrEmptyBroadcast):
+		return c.Send(usage)
+	case errors.Is(err, bank.ErrNoRecipients):
+		return c.Send("📭 " + err.Error())
+	case errors.Is(err, storage.ErrNotFound):
+		return c.Send("❌ Облигация не найдена")
+	case err != nil:
+		log.Println("❌ Ошибка создания рассылки:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+
+	what, opts := broadcastMessage(job)
+	if _, err := h.tg.Send(c.Sender(), what, opts...); err != nil {
+		h.bank.CancelBroadcast(job.ID)
+		return c.Send("❌ Telegram не принял сообщение рассылки: " + err.Error())
+	}
+	preview, err := h.tg.Send(c.Sender(), broadcastProgress(job), broadcastMarkup(job))
+	if err != nil {
+		log.Println("❌ Ошибка отправки предпросмотра рассылки:", err)
+	} else if err := h.bank.SetBroadcastMessage(job.ID, c.Sender().ID, preview.ID); err != nil {
+		log.Println("❌ Ошибка сохранения хода рассылки:", err)
+	}
+	return nil
+}
+
+// broadcastFrom берёт содержимое рассылки из сообщения администратора:
+// текст или фото и файл с подписью, форматирование и кнопки со ссылками.
+// Кнопками становятся кнопки сообщения и строки «[Текст](https://…)» в его
+// конце. Другие виды сообщений не рассылаются.
+func broadcastFrom(m *telebot.Message) (storage.Broadcast, bool) {
+	var b storage.Broadcast
+	text, entities := m.Text, m.Entities
+	switch {
+	case m.Photo != nil:
+		b.FileID, b.FileType = m.Photo.FileID, storage.FilePhoto
+		text, entities = m.Caption, m.CaptionEntities
+	case m.Document != nil:
+		b.FileID, b.FileType = m.Document.FileID, storage.FileDocument
+		text, entities = m.Caption, m.CaptionEntities
+	case m.Text == "":
+		return b, false
+	}
+
+	var rows [][]linkButton
+	if m.ReplyMarkup != nil {
+		for _, row := range m.ReplyMarkup.InlineKeyboard {
+			var links []linkButton
+			for _, btn := range row {
+				if btn.URL != "" {
+					links = append(links, linkButton{btn.Text, btn.URL})
+				}
+			}
+			if len(links) > 0 {
+				rows = append(rows, links)
+			}
+		}
+	}
+	lines := strings.Split(strings.TrimRight(text, "\n "), "\n")
+	var links [][]linkButton
+	for len(lines) > 0 {
+		match := linkLine.FindStringSubmatch(strings.TrimSpace(lines[len(lines)-1]))
+		if match == nil {
+			break
+		}
+		links = append([][]linkButton{{{match[1], match[2]}}}, links...)
+		lines = lines[:len(lines)-1]
+	}
+	if len(links) > 0 {
+		text = strings.TrimRight(strings.Join(lines, "\n"), "\n ")
+		entities = clipEntities(entities, utf16Len(text))
+		rows = append(rows, links...)
+	}
+
+	b.Text = text
+	if len(entities) > 0 {
+		data, _ := json.Marshal(entities)
+		b.Entities = string(data)
+	}
+	if len(rows) > 0 {
+		data, _ := json.Marshal(rows)
+		b.Buttons = string(data)
+	}
+	return b, true
+}
+
+// utf16Len — длина текста в единицах UTF-16, в которых Telegram считает
+// смещения форматирования.
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// clipEntities обрезает форматирование по длине текста n.
+func clipEntities(entities telebot.Entities, n int) telebot.Entities {
+	var res telebot.Entities
+	for _, e := range entities {
+		if e.Offset >= n {
+			continue
+		}
+		if e.Offset+e.Length > n {
+			e.Length = n - e.Offset
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+// broadcastMessage — сообщение рассылки job для отправки игроку: текст
+// или фото и файл с подписью под заголовком broadcastHeader.
+func broadcastMessage(job storage.Broadcast) (interface{}, []interface{}) {
+	text := broadcastHeader + job.Text
+	var opts []interface{}
+
+	var entities telebot.Entities
+	if job.Entities != "" && json.Unmarshal([]byte(job.Entities), &entities) == nil && len(entities) > 0 {
+		shift := utf16Len(broadcastHeader)
+		for i := range entities {
+			entities[i].Offset += shift
+		}
+		opts = append(opts, entities)
+	}
+	var rows [][]linkButton
+	if job.Buttons != "" && json.Unmarshal([]byte(job.Buttons), &rows) == nil && len(rows) > 0 {
+		markup := &telebot.ReplyMarkup{}
+		for _, row := range rows {
+			var btns []telebot.InlineButton
+			for _, l := range row {
+				btns = append(btns, telebot.InlineButton{Text: l.Text, URL: l.URL})
+			}
+			markup.InlineKeyboard = append(markup.InlineKeyboard, btns)
+		}
+		opts = append(opts, markup)
+	}
+
+	switch job.FileType {
+	case storage.FilePhoto:
+		return &telebot.Photo{File: telebot.File{FileID: job.FileID}, Caption: text}, opts
+	case storage.FileDocument:
+		return &telebot.Document{File: telebot.File{FileID: job.FileID}, Caption: text}, opts
+	}
+	return text, opts
+}
+
+// broadcastCancel отменяет рассылку: /broadcast_cancel [номер], без номера —
+// последнюю активную.
+func (h *Handlers) broadcastCancel(c telebot.Context) error {
+	if !h.can(c, bank.PermBroadcast) {
+		return nil
+	}
+	var id int64
+	if len(c.Args()) > 0 {
+		var err error
+		if id, err = parseRequestID(c.Args()[0]); err != nil {
+			return c.Send("❌ Некорректный номер рассылки")
+		}
+	}
+	job, err := h.bank.CancelBroadcast(id)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return c.Send("❌ Рассылка не найдена")
+	case errors.Is(err, bank.ErrBroadcastClosed), errors.Is(err, bank.ErrNoActiveBroadcast):
+		return c.Send("ℹ️ " + err.Error())
+	case err != nil:
+		return c.Send("❌ Ошибка БД")
+	}
+	h.updateProgress(job)
+	return c.Send(fmt.Sprintf("🛑 Рассылка #%d отменена. Отправлено: %d из %d", job.ID, job.Sent, job.Total))
+}
+
+// onBroadcastCallback — кнопки под предпросмотром и ходом рассылки:
+// send_broadcast запускает черновик, cancel_broadcast отменяет рассылку.
+func (h *Handlers) onBroadcastCallback(c telebot.Context, action, arg string) error {
+	if !h.can(c, bank.PermBroadcast) {
+		c.Respond(&telebot.CallbackResponse{Text: "⛔ Недостаточно прав"})
+		return nil
+	}
+	id, err := parseRequestID(arg)
+	if err != nil {
+		c.Respond(&telebot.CallbackResponse{Text: "Рассылка не найдена"})
+		return nil
+	}
+	var job storage.Broadcast
+	if action == "send_broadcast" {
+		job, err = h.bank.ConfirmBroadcast(id)
+	} else {
+		job, err = h.bank.CancelBroadcast(id)
+	}
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.Respond(&telebot.CallbackResponse{Text: "Рассылка не найдена"})
+	case errors.Is(err, bank.ErrBroadcastClosed):
+		c.Respond(&telebot.CallbackResponse{Text: "Рассылка уже запущена или завершена"})
+	case err != nil:
+		log.Println("❌ Ошибка рассылки:", err)
+		c.Respond(&telebot.CallbackResponse{Text: "Ошибка БД"})
+	case action == "send_broadcast":
+		editText(c, broadcastProgress(job), broadcastMarkup(job))
+		h.wakeBroadcasts()
+		c.Respond(&telebot.CallbackResponse{Text: "📤 Рассылка запущена"})
+	default:
+		editText(c, broadcastProgress(job))
+		c.Respond(&telebot.CallbackResponse{Text: "🛑 Рассылка отменена"})
+	}
+	return nil
+}
+
+func broadcastProgress(job storage.Broadcast) string {
+	res := fmt.Sprintf("📢 Рассылка #%d — %s\n🎯 Кому: %s", job.ID, broadcastStatusTitles[job.Status], job.Segment)
+	if job.Status == storage.BroadcastDraft {
+		return res + fmt.Sprintf("\n👥 Получателей: %d\n\nПроверьте сообщение выше и подтвердите отправку.", job.Total)
+	}
+	res += fmt.Sprintf("\n📤 Отправлено: %d из %d", job.Sent, job.Total)
+	if job.Blocked > 0 {
+		res += fmt.Sprintf("\n🚫 Заблокировали бота: %d", job.Blocked)
+	}
+	if job.Failed > 0 {
+		res += fmt.Sprintf("\n❌ Не доставлено: %d", job.Failed)
+	}
+	if job.Active() {
+		res += fmt.Sprintf("\n\n🛑 Отменить: /broadcast_cancel %d", job.ID)
+	}
+	return res
+}
+
+func broadcastMarkup(job storage.Broadcast) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	btnCancel := markup.Data("🛑 Отменить", "cancel_broadcast", fmt.Sprintf("cancel_broadcast:%d", job.ID))
+	switch {
+	case job.Status == storage.BroadcastDraft:
+		btnSend := markup.Data("✅ Отправить", "send_broadcast", fmt.Sprintf("send_broadcast:%d", job.ID))
+		markup.Inline(markup.Row(btnSend, btnCancel))
+	case job.Active():
+		markup.Inline(markup.Row(btnCancel))
+	}
+	return markup
+}
+
+// updateProgress обновляет сообщение с ходом рассылки у администратора.
+func (h *Handlers) updateProgress(job storage.Broadcast) {
+	if job.MessageID == 0 {
+		return
+	}
+	msg := telebot.StoredMessage{MessageID: strconv.Itoa(job.MessageID), ChatID: job.ChatID}
+	if _, err := h.tg.Edit(msg, broadcastProgress(job), broadcastMarkup(job)); err != nil && !errors.Is(err, telebot.ErrSameMessageContent) {
+		log.Println("❌ Ошибка обновления хода рассылки:", err)
+	}
+}
+
+func (h *Handlers) wakeBroadcasts() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runBroadcasts запускает рассылки по расписанию и доставляет рассылки из
+// очереди, пока не отменён ctx. Незавершённые рассылки после перезапуска
+// продолжаются с того же места.
+func (h *Handlers) runBroadcasts(ctx context.Context) {
+	tick := time.NewTicker(broadcastTick)
+	defer tick.Stop()
+	for {
+		h.startScheduled()
+		h.processBroadcasts(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.wake:
+		case <-tick.C:
+		}
+	}
+}
+
+// processBroadcasts доставляет все активные рассылки по очереди.
+func (h *Handlers) processBroadcasts(ctx context.Context) {
+	jobs, err := h.bank.ActiveBroadcasts()
+	if err != nil {
+		log.Println("❌ Ошибка чтения очереди рассылок:", err)
+		return
+	}
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		h.runBroadcast(ctx, job.ID)
+	}
+}
+
+// runBroadcast доставляет рассылку id пачками до конца, отмены или
+// остановки бота.
+func (h *Handlers) runBroadcast(ctx context.Context, id int64) {
+	for ctx.Err() == nil {
+		job, batch, err := h.bank.BroadcastBatch(id, broadcastBatch)
+		if err != nil {
+			log.Println("❌ Ошибка рассылки:", err)
+			return
+		}
+		if len(batch) == 0 {
+			h.updateProgress(job)
+			return
+		}
+		for _, d := range batch {
+			if job, err = h.deliver(ctx, job, d); err != nil {
+				log.Println("❌ Ошибка учёта доставки:", err)
+				return
+			}
+			if !job.Active() || ctx.Err() != nil {
+				h.updateProgress(job)
+				return
+			}
+			h.pause(ctx, broadcastDelay)
+		}
+		h.updateProgress(job)
+	}
+}
+
+// deliver отправляет рассылку одному игроку. Flood-wait от Telegram
+// пережидается и не считается попыткой; при остановке бота неотправленное
+// сообщение остаётся в очереди без записи попытки.
+func (h *Handlers) deliver(ctx context.Context, job storage.Broadcast, d storage.Delivery) (storage.Broadcast, error) {
+	what, opts := broadcastMessage(job)
+	for {
+		err := h.notify(d.UserID, what, opts...)
+		var flood telebot.FloodError
+		switch {
+		case err == nil:
+			return h.bank.RecordDelivery(d, storage.DeliverySent, "")
+		case ctx.Err() != nil:
+			return job, nil
+		case errors.As(err, &flood):
+			log.Printf("⏳ Flood-wait рассылки #%d: %d с", d.BroadcastID, flood.RetryAfter)
+			h.pause(ctx, time.Duration(flood.RetryAfter)*time.Second)
+			if ctx.Err() != nil {
+				return job, nil
+			}
+		case errors.Is(err, telebot.ErrBlockedByUser), errors.Is(err, telebot.ErrUserIsDeactivated), errors.Is(err, telebot.ErrChatNotFound):
+			return h.bank.RecordDelivery(d, storage.DeliveryBlocked, err.Error())
+		default:
+			return h.bank.RecordDelivery(d, storage.DeliveryFailed, err.Error())
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+
+	"mybot/internal/bank"
+	"mybot/internal/storage"
+)
+
+// onCallback обрабатывает кнопки решений по заявкам:
+// approve:<id>, reject:<id>, approve_deposit:<id>, reject_deposit:<id> —
+// подтверждения переводов: confirm_transfer:<id>, cancel_transfer:<id> —
+// решения по переводам сверх лимита: approve_transfer:<id>, reject_transfer:<id> —
+// действия с обращениями: reply_ticket, assign_ticket, resolve_ticket, reject_ticket —
+// и кнопки рассылки: send_broadcast:<id>, cancel_broadcast:<id>.
+func (h *Handlers) onCallback(c telebot.Context) error {
+	data := c.Callback().Data
+	log.Println("📥 Получен callback:", data)
+
+	// Убираем префикс до | если он есть
+	if strings.Contains(data, "|") {
+		parts := strings.Split(data, "|")
+		if len(parts) > 1 {
+			data = parts[1]
+		}
+	}
+
+	action, arg, _ := strings.Cut(data, ":")
+	if action == "confirm_transfer" || action == "cancel_transfer" {
+		return h.onTransferCallback(c, action, arg)
+	}
+	if action == "approve_transfer" || action == "reject_transfer" {
+		return h.onTransferReview(c, action == "approve_transfer", arg)
+	}
+	if strings.HasSuffix(action, "_ticket") {
+		return h.onTicketCallback(c, action, arg)
+	}
+	if action == "send_broadcast" || action == "cancel_broadcast" {
+		return h.onBroadcastCallback(c, action, arg)
+	}
+	if action != "approve" && action != "reject" && action != "approve_deposit" && action != "reject_deposit" {
+		return nil
+	}
+	if !h.can(c, bank.PermFinance) {
+		c.Respond(&telebot.CallbackResponse{Text: "⛔ Недостаточно прав"})
+		return nil
+	}
+	id, err := parseRequestID(arg)
+	if err != nil {
+		// Кнопки старого формата несли сумму прямо в callback, такие заявки нигде не сохранены.
+		editText(c, "⚠️ Устаревшая заявка. Попросите игрока отправить запрос заново.")
+		c.Respond(&telebot.CallbackResponse{Text: "Устаревшая заявка"})
+		return nil
+	}
+	approve := strings.HasPrefix(action, "approve")
+
+	r, err := h.bank.DecideRequest(id, senderID(c), approve)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.Respond(&telebot.CallbackResponse{Text: "Заявка не найдена"})
+		return nil
+	case errors.Is(err, storage.ErrRequestClosed):
+		editText(c, fmt.Sprintf("ℹ️ Заявка #%d уже обработана: %s\n👤 ID: %s\n💰 Сумма: %s GOLD", id, statusTitles[r.Status], r.UserID, r.Amount))
+		c.Respond(&telebot.CallbackResponse{Text: "Заявка уже обработана"})
+		return nil
+	case errors.Is(err, bank.ErrInsufficientFunds):
+		// Заявка остаётся в ожидании: её можно одобрить позже или отклонить.
+		c.Respond(&telebot.CallbackResponse{Text: "❌ Недостаточно средств у игрока", ShowAlert: true})
+		return nil
+	case err != nil:
+		log.Println("❌ Ошибка обработки заявки:", err)
+		c.Respond(&telebot.CallbackResponse{Text: "Ошибка БД"})
+		return nil
+	}
+
+	switch {
+	case approve && r.Kind == storage.RequestWithdraw:
+		h.notify(r.UserID, fmt.Sprintf("✅ Вывод одобрен!\n💰 Сумма: %s GOLD списано с вашего баланса.", r.Amount)+feeLine(r.Fee))
+		editText(c, fmt.Sprintf("✅ ОДОБРЕНО\n📄 Заявка #%d\n👤 ID: %s\n💰 Сумма: %s GOLD", id, r.UserID, r.Amount)+feeLine(r.Fee))
+		c.Respond(&telebot.CallbackResponse{Text: "✅ Выполнено"})
+	case approve:
+		h.notify(r.UserID, fmt.Sprintf("✅ Пополнение подтверждено!\n💰 Сумма: %s GOLD зачислено на ваш баланс.", r.Amount))
+		editText(c, fmt.Sprintf("✅ ПОПОЛНЕНИЕ ПОДТВЕРЖДЕНО\n📄 Заявка #%d\n👤 ID: %s\n💰 Сумма: %s GOLD", id, r.UserID, r.Amount))
+		c.Respond(&telebot.CallbackResponse{Text: "✅ Зачислено"})
+	case r.Kind == storage.RequestWithdraw:
+		h.notify(r.UserID, "❌ Ваш запрос на вывод средств был отклонен администрацией.")
+		editText(c, fmt.Sprintf("❌ ОТКЛОНЕНО\n📄 Заявка #%d", id))
+		c.Respond(&telebot.CallbackResponse{Text: "❌ Отклонено"})
+	default:
+		h.notify(r.UserID, "❌ Ваш запрос на пополнение был отклонен администрацией.")
+		editText(c, fmt.Sprintf("❌ ПОПОЛНЕНИЕ ОТКЛОНЕНО\n📄 Заявка #%d", id))
+		c.Respond(&telebot.CallbackResponse{Text: "❌ Отклонено"})
+	}
+	return nil
+}
+
+// onTransferCallback подтверждает или отменяет перевод; нажать кнопку может
+// только отправитель.
+func (h *Handlers) onTransferCallback(c telebot.Context, action, arg string) error {
+	id, err := parseRequestID(arg)
+	if err != nil {
+		c.Respond(&telebot.CallbackResponse{Text: "Перевод не найден"})
+		return nil
+	}
+	uid := senderID(c)
+
+	if action == "cancel_transfer" {
+		_, err := h.bank.CancelTransfer(uid, id)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			c.Respond(&telebot.CallbackResponse{Text: "Перевод не найден"})
+		case errors.Is(err, storage.ErrTransferClosed):
+			c.Respond(&telebot.CallbackResponse{Text: "Перевод уже обработан"})
+		case err != nil:
+			log.Println("❌ Ошибка отмены перевода:", err)
+			c.Respond(&telebot.CallbackResponse{Text: "Ошибка БД"})
+		default:
+			editText(c, "❌ Перевод отменён.")
+			c.Respond(&telebot.CallbackResponse{Text: "Отменено"})
+		}
+		return nil
+	}
+
+	t, err := h.bank.ConfirmTransfer(uid, id)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.Respond(&telebot.CallbackResponse{Text: "Перевод не найден"})
+		return nil
+	case errors.Is(err, storage.ErrTransferClosed):
+		c.Respond(&telebot.CallbackResponse{Text: "Перевод уже обработан"})
+		return nil
+	case errors.Is(err, bank.ErrInsufficientFunds):
+		// Перевод остаётся ожидающим: можно пополнить баланс и нажать снова.
+		c.Respond(&telebot.CallbackResponse{Text: "❌ Недостаточно средств для перевода", ShowAlert: true})
+		return nil
+	case errors.Is(err, bank.ErrTransferExpired):
+		editText(c, "⌛ Время на подтверждение перевода истекло. Отправьте перевод заново.")
+		c.Respond(&telebot.CallbackResponse{Text: "Перевод истёк"})
+		return nil
+	case isTransferError(err):
+		c.Respond(&telebot.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+		return nil
+	case err != nil:
+		log.Println("❌ Ошибка перевода:", err)
+		c.Respond(&telebot.CallbackResponse{Text: "Ошибка БД"})
+		return nil
+	}
+
+	if t.Review != nil {
+		markup := &telebot.ReplyMarkup{}
+		btnApprove := markup.Data("✅ Одобрить", "approve_transfer", fmt.Sprintf("approve_transfer:%d", t.ID))
+		btnReject := markup.Data("❌ Отклонить", "reject_transfer", fmt.Sprintf("reject_transfer:%d", t.ID))
+		markup.Inline(markup.Row(btnApprove, btnReject))
+		h.notifyAdmins(bank.PermFinance, fmt.Sprintf("🔎 ПЕРЕВОД СВЕРХ ЛИМИТА #%d\n👤 От: %s (ID: %s)\n👤 Кому: %s (ID: %s)\n💰 Сумма: %s GOLD", t.ID, t.FromNick, t.FromID, t.ToNick, t.ToID, t.Amount)+feeLine(t.Fee)+breachLine(t.Review), markup)
+
+		editText(c, fmt.Sprintf("🔎 Перевод #%d превышает %s.\nОн отправлен на проверку администрации, средства спишутся после одобрения.", t.ID, t.Review))
+		c.Respond(&telebot.CallbackResponse{Text: "Перевод на проверке"})
+		return nil
+	}
+
+	h.notifyTransfer(t)
+	editText(c, fmt.Sprintf("✅ Перевод выполнен успешно!\n👤 Получатель: %s\n💸 Сумма: %s GOLD", t.ToNick, t.Amount)+feeLine(t.Fee))
+	c.Respond(&telebot.CallbackResponse{Text: "✅ Выполнено"})
+	return nil
+}
+
+// notifyTransfer сообщает получателю о проведённом переводе.
+func (h *Handlers) notifyTransfer(t bank.Transfer) {
+	notice := fmt.Sprintf("💰 Вам поступил перевод!\n👤 От: %s\n💵 Сумма: %s GOLD", t.FromNick, t.Amount)
+	if t.Comment != "" {
+		notice += "\n💬 Комментарий: " + t.Comment
+	}
+	h.notify(t.ToID, notice)
+}
+
+// onTransferReview применяет решение администратора по переводу сверх лимита.
+func (h *Handlers) onTransferReview(c telebot.Context, approve bool, arg string) error {
+	if !h.can(c, bank.PermFinance) {
+		c.Respond(&telebot.CallbackResponse{Text: "⛔ Недостаточно прав"})
+		return nil
+	}
+	id, err := parseRequestID(arg)
+	if err != nil {
+		c.Respond(&telebot.CallbackResponse{Text: "Перевод не найден"})
+		return nil
+	}
+
+	t, err := h.bank.DecideTransfer(id, senderID(c), approve)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.Respond(&telebot.CallbackResponse{Text: "Перевод не найден"})
+		return nil
+	case errors.Is(err, storage.ErrTransferClosed):
+		editText(c, fmt.Sprintf("ℹ️ Перевод #%d уже обработан.\n👤 От ID: %s\n💰 Сумма: %s GOLD", id, t.FromID, t.Amount))
+		c.Respond(&telebot.CallbackResponse{Text: "Перевод уже обработан"})
+		return nil
+	case errors.Is(err, bank.ErrInsufficientFunds):
+		// Перевод остаётся на проверке: его можно одобрить позже или отклонить.
+		c.Respond(&telebot.CallbackResponse{Text: "❌ Недостаточно средств у отправителя", ShowAlert: true})
+		return nil
+	case isTransferError(err):
+		c.Respond(&telebot.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+		return nil
+	case err != nil:
+		log.Println("❌ Ошибка решения по переводу:", err)
+		c.Respond(&telebot.CallbackResponse{Text: "Ошибка БД"})
+		return nil
+	}
+
+	if !approve {
+		h.notify(t.FromID, fmt.Sprintf("❌ Перевод #%d на %s GOLD отклонён администрацией.", id, t.Amount))
+		editText(c, fmt.Sprintf("❌ ПЕРЕВОД ОТКЛОНЁН\n📄 Перевод #%d", id))
+		c.Respond(&telebot.CallbackResponse{Text: "❌ Отклонено"})
+		return nil
+	}
+	h.notifyTransfer(t)
+	h.notify(t.FromID, fmt.Sprintf("✅ Перевод #%d одобрен!\n👤 Получатель: %s\n💸 Сумма: %s GOLD", id, t.ToNick, t.Amount)+feeLine(t.Fee))
+	editText(c, fmt.Sprintf("✅ ПЕРЕВОД ОДОБРЕН\n📄 Перевод #%d\n👤 %s → %s\n💰 Сумма: %s GOLD", id, t.FromNick, t.ToNick, t.Amount)+feeLine(t.Fee))
+	c.Respond(&telebot.CallbackResponse{Text: "✅ Выполнено"})
+	return nil
+}
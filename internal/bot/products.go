@@ -0,0 +1,240 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"mybot/internal/bank"
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+const bondOptionsHelp = "Опции: auto — погасить автоматически; model=daily|simple|monthly|coupon; early=forbidden|forfeit|penalty:5; " +
+	"supply=[объём выпуска]; per_user=[лимит на игрока]; opens=[дд.мм.гггг]; closes=[дд.мм.гггг]"
+
+var productStatusTitles = map[string]string{
+	storage.ProductActive:  "🟢 продаётся",
+	storage.ProductPaused:  "⏸ приостановлена",
+	storage.ProductRetired: "⛔ снята с рынка",
+}
+
+// applyBondOptions применяет к облигации опции вида ключ=значение из
+// /create_bond и /edit_bond. Пустое значение даты или лимита снимает его.
+func applyBondOptions(p *storage.Product, opts []string) error {
+	for _, opt := range opts {
+		key, val, _ := strings.Cut(opt, "=")
+		var err error
+		switch key {
+		case "name":
+			p.Name = val
+		case "price":
+			if p.Price, err = money.Parse(val); err != nil {
+				return fmt.Errorf("Мин_Цена: %w", err)
+			}
+		case "rate":
+			if p.Rate, err = strconv.ParseFloat(val, 64); err != nil {
+				return errors.New("Процент должен быть числом")
+			}
+		case "term":
+			if p.TermDays, err = strconv.Atoi(val); err != nil || p.TermDays < 0 {
+				return errors.New("Срок должен быть целым числом дней")
+			}
+		case "auto":
+			p.AutoRedeem = val == "" || val == "1" || val == "on"
+		case "model":
+			p.InterestModel = val
+		case "early":
+			policy, pct, ok := strings.Cut(val, ":")
+			p.EarlyPolicy, p.PenaltyPct = policy, 0
+			if ok {
+				if p.PenaltyPct, err = strconv.ParseFloat(pct, 64); err != nil {
+					return bank.ErrBadEarlyPolicy
+				}
+			}
+		case "supply", "per_user":
+			var v money.Money
+			if val != "" {
+				if v, err = money.Parse(val); err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+			}
+			if key == "supply" {
+				p.MaxSupply = v
+			} else {
+				p.PerUserMax = v
+			}
+		case "opens", "closes":
+			var d time.Time
+			if val != "" {
+				if d, err = time.ParseInLocation("02.01.2006", val, time.Local); err != nil {
+					return errors.New("Дата должна быть в формате дд.мм.гггг")
+				}
+			}
+			if key == "opens" {
+				p.OpensAt = d
+			} else {
+				p.ClosesAt = d
+			}
+		default:
+			return fmt.Errorf("Неизвестная опция: %s", opt)
+		}
+	}
+	return nil
+}
+
+// isProductError — ошибка в условиях облигации, которую стоит показать
+// администратору как есть.
+func isProductError(err error) bool {
+	for _, e := range []error{bank.ErrBadProduct, bank.ErrBadTerm, bank.ErrBadModel, bank.ErrBadEarlyPolicy, bank.ErrBadLimits, bank.ErrProductRetired} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// productTerms — условия облигации для сообщений администрации.
+func productTerms(p storage.Product) string {
+	return fmt.Sprintf("💰 От %s GOLD, %.2f%%\n⏳ Срок: %s\n📈 Проценты: %s\n🚪 %s\n📦 %s",
+		p.Price, p.Rate, termTitle(p.TermDays, p.AutoRedeem), interestTitles[p.InterestModel], earlyTitle(p.EarlyPolicy, p.PenaltyPct), limitsTitle(p))
+}
+
+func (h *Handlers) editBond(c telebot.Context) error {
+	if !h.can(c, bank.PermBonds) {
+		return nil
+	}
+	args := c.Args()
+	if len(args) < 2 {
+		return c.Send("⚠️ Формат: /edit_bond [ID] [поле=значение ...]\nПоля: name, price, rate, term. " + bondOptionsHelp)
+	}
+	id, err := parseBondID(args[0])
+	if err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+
+	var optErr error
+	p, edits, err := h.bank.EditProduct(senderID(c), id, func(p *storage.Product) error {
+		optErr = applyBondOptions(p, args[1:])
+		return optErr
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.Send("❌ Облигация не найдена.")
+	}
+	if optErr != nil || isProductError(err) {
+		return c.Send("❌ " + err.Error())
+	}
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	if len(edits) == 0 {
+		return c.Send("ℹ️ Условия не изменились.")
+	}
+
+	res := fmt.Sprintf("✏️ Облигация #%d %s изменена:\n", p.ID, p.Name)
+	for _, e := range edits {
+		res += fmt.Sprintf("• %s: %s → %s\n", e.Field, e.Old, e.New)
+	}
+	return c.Send(res + "\nОткрытые вклады сохраняют прежние условия.")
+}
+
+func (h *Handlers) pauseBond(c telebot.Context) error {
+	return h.setProductStatus(c, "/pause_bond", storage.ProductPaused)
+}
+
+func (h *Handlers) resumeBond(c telebot.Context) error {
+	return h.setProductStatus(c, "/resume_bond", storage.ProductActive)
+}
+
+func (h *Handlers) retireBond(c telebot.Context) error {
+	return h.setProductStatus(c, "/retire_bond", storage.ProductRetired)
+}
+
+func (h *Handlers) setProductStatus(c telebot.Context, cmd, status string) error {
+	if !h.can(c, bank.PermBonds) {
+		return nil
+	}
+	args := c.Args()
+	if len(args) < 1 {
+		return c.Send(fmt.Sprintf("⚠️ Формат: %s [ID]", cmd))
+	}
+	id, err := parseBondID(args[0])
+	if err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+
+	p, err := h.bank.SetProductStatus(senderID(c), id, status)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.Send("❌ Облигация не найдена.")
+	}
+	if errors.Is(err, bank.ErrProductRetired) {
+		return c.Send("❌ Облигация уже снята с рынка.")
+	}
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	return c.Send(fmt.Sprintf("✅ Облигация #%d %s: %s", p.ID, p.Name, productStatusTitles[p.Status]))
+}
+
+// market — все облигации с состоянием и остатками выпуска, а с ID — условия
+// одной облигации и журнал её изменений.
+func (h *Handlers) market(c telebot.Context) error {
+	if !h.can(c, bank.PermReports) {
+		return nil
+	}
+	items, err := h.bank.AllProducts()
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+
+	if args := c.Args(); len(args) > 0 {
+		id, err := parseBondID(args[0])
+		if err != nil {
+			return c.Send("❌ " + err.Error())
+		}
+		for _, it := range items {
+			if it.ID == id {
+				return c.Send(h.productCard(it))
+			}
+		}
+		return c.Send("❌ Облигация не найдена.")
+	}
+
+	if len(items) == 0 {
+		return c.Send("🏪 На рынке нет облигаций.")
+	}
+	res := "🏪 Облигации:\n\n"
+	for _, it := range items {
+		res += fmt.Sprintf("[%d] %s — %s\n📈 %.2f%%, от %s GOLD, продано %s GOLD", it.ID, it.Name, productStatusTitles[it.Status], it.Rate, it.Price, it.Issued)
+		if it.Remaining != nil {
+			res += fmt.Sprintf(", осталось %s", *it.Remaining)
+		}
+		res += "\n\n"
+	}
+	return c.Send(res + "Подробнее: /market [ID]")
+}
+
+func (h *Handlers) productCard(it bank.MarketItem) string {
+	res := fmt.Sprintf("🏪 [%d] %s — %s\n%s\n💵 Продано: %s GOLD\n", it.ID, it.Name, productStatusTitles[it.Status], productTerms(it.Product), it.Issued)
+	changes, err := h.bank.ProductChanges(it.ID, 10)
+	if err != nil || len(changes) == 0 {
+		return res
+	}
+	res += "\n📝 Изменения:\n"
+	for _, ch := range changes {
+		who := ch.AdminID
+		if u, err := h.bank.User(ch.AdminID); err == nil && u.Nick != "" {
+			who = u.Nick
+		}
+		if ch.Field == "created" {
+			res += fmt.Sprintf("%s %s: создана\n", ch.CreatedAt.Format("02.01 15:04"), who)
+			continue
+		}
+		res += fmt.Sprintf("%s %s: %s %s → %s\n", ch.CreatedAt.Format("02.01 15:04"), who, ch.Field, ch.OldValue, ch.NewValue)
+	}
+	return res
+}
@@ -0,0 +1,239 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"mybot/internal/bank"
+	"mybot/internal/storage"
+)
+
+const scheduleUsage = "⚠️ Формат:\n" +
+	"/schedule at=дд.мм.гггг-чч:мм|in=2h [every=24h|7d] [until=дд.мм.гггг] [фильтры] [сообщение] — рассылка; " +
+	"ответом на сообщение рассылается оно\n" +
+	"/schedule info [from=дд.мм.гггг-чч:мм] [until=дд.мм.гггг-чч:мм] [priority=N] текст — информационная строка"
+
+// parseWhen разбирает момент времени: дд.мм.гггг-чч:мм или дд.мм.гггг.
+func parseWhen(s string) (time.Time, error) {
+	for _, layout := range []string{"02.01.2006-15:04", "02.01.2006"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("Время должно быть в формате дд.мм.гггг-чч:мм или дд.мм.гггг")
+}
+
+// parseEvery разбирает длительность: 30m, 2h или 7d.
+func parseEvery(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	} else if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, errors.New("Длительность должна быть вида 30m, 2h или 7d")
+}
+
+// everyTitle — период повтора для сообщений.
+func everyTitle(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%d дн.", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%d ч", d/time.Hour)
+	}
+	return fmt.Sprintf("%d мин", d/time.Minute)
+}
+
+// scheduleOptions заполняет s из опций в начале args (at=, in=, every=,
+// until=, from=, priority=) и собирает фильтры получателей рассылки в
+// любом порядке с ними. Возвращает оставшиеся аргументы — текст.
+func (h *Handlers) scheduleOptions(s *storage.Schedule, args []string) ([]string, error) {
+	var filters []string
+	for len(args) > 0 {
+		key, val, ok := strings.Cut(args[0], "=")
+		if !ok {
+			break
+		}
+		var err error
+		switch key {
+		case "at", "from":
+			s.StartsAt, err = parseWhen(val)
+		case "in":
+			var d time.Duration
+			if d, err = parseEvery(val); err == nil {
+				s.StartsAt = h.bank.Now().Add(d)
+			}
+		case "every":
+			s.Every, err = parseEvery(val)
+		case "until":
+			s.EndsAt, err = parseWhen(val)
+		case "priority":
+			if s.Priority, err = strconv.Atoi(val); err != nil {
+				err = errors.New("Приоритет должен быть целым числом")
+			}
+		default:
+			_, rest, err := bank.ParseSegment(args[:1])
+			if err != nil {
+				return args, err
+			}
+			if len(rest) > 0 {
+				s.Filters = strings.Join(filters, " ")
+				return args, nil
+			}
+			filters = append(filters, args[0])
+		}
+		if err != nil {
+			return args, fmt.Errorf("%s: %w", key, err)
+		}
+		args = args[1:]
+	}
+	s.Filters = strings.Join(filters, " ")
+	return args, nil
+}
+
+// formatSchedule — запись расписания для администратора.
+func formatSchedule(s storage.Schedule) string {
+	text := shorten(s.Text, 60)
+	if s.FileID != "" {
+		text = "📎 " + text
+	}
+	if s.Kind == storage.ScheduleInfo {
+		res := fmt.Sprintf("#%d ℹ️ Информационная строка · приоритет %d · с %s", s.ID, s.Priority, s.StartsAt.Format("02.01.2006 15:04"))
+		if !s.EndsAt.IsZero() {
+			res += " до " + s.EndsAt.Format("02.01.2006 15:04")
+		}
+		return res + "\n💬 " + text
+	}
+	res := fmt.Sprintf("#%d 📢 Рассылка · %s", s.ID, s.StartsAt.Format("02.01.2006 15:04"))
+	if s.Every > 0 {
+		res += " · каждые " + everyTitle(s.Every)
+	}
+	if !s.EndsAt.IsZero() {
+		res += " · до " + s.EndsAt.Format("02.01.2006 15:04")
+	}
+	seg, _, _ := bank.ParseSegment(strings.Fields(s.Filters))
+	return res + "\n🎯 " + seg.String() + "\n💬 " + text
+}
+
+// schedule добавляет в расписание рассылку или информационную строку.
+func (h *Handlers) schedule(c telebot.Context) error {
+	if !h.can(c, bank.PermBroadcast) {
+		return nil
+	}
+	args := c.Args()
+	s := storage.Schedule{Kind: storage.ScheduleBroadcast}
+	if len(args) > 0 && args[0] == "info" {
+		s.Kind, args = storage.ScheduleInfo, args[1:]
+	}
+	args, err := h.scheduleOptions(&s, args)
+	if err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+	if m := c.Message(); m != nil && m.ReplyTo != nil && s.Kind == storage.ScheduleBroadcast {
+		msg, ok := broadcastFrom(m.ReplyTo)
+		if !ok || len(args) > 0 {
+			return c.Send(scheduleUsage)
+		}
+		s.Text, s.FileID, s.FileType, s.Entities, s.Buttons = msg.Text, msg.FileID, msg.FileType, msg.Entities, msg.Buttons
+	} else {
+		s.Text = strings.Join(args, " ")
+	}
+
+	s, err = h.bank.AddSchedule(senderID(c), s)
+	switch {
+	case errors.Is(err, bank.ErrEmptyBroadcast):
+		return c.Send(scheduleUsage)
+	case errors.Is(err, storage.ErrNotFound):
+		return c.Send("❌ Облигация не найдена")
+	case errors.Is(err, bank.ErrScheduleTime), errors.Is(err, bank.ErrScheduleEvery), errors.Is(err, bank.ErrScheduleEnds),
+		errors.Is(err, bank.ErrBadScheduleKind), errors.Is(err, bank.ErrBadSegment):
+		return c.Send("❌ " + err.Error())
+	case err != nil:
+		log.Println("❌ Ошибка добавления в расписание:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+
+	if s.Kind == storage.ScheduleBroadcast {
+		what, opts := broadcastMessage(s.Broadcast())
+		if _, err := h.tg.Send(c.Sender(), what, opts...); err != nil {
+			h.bank.Unschedule(s.ID)
+			return c.Send("❌ Telegram не принял сообщение рассылки: " + err.Error())
+		}
+	}
+	return c.Send(fmt.Sprintf("🗓 Запланировано:\n\n%s\n\nУдалить: /unschedule %d", formatSchedule(s), s.ID))
+}
+
+// schedules показывает расписание рассылок и информационных строк.
+func (h *Handlers) schedules(c telebot.Context) error {
+	if !h.can(c, bank.PermBroadcast) {
+		return nil
+	}
+	list, err := h.bank.Schedules()
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	if len(list) == 0 {
+		return c.Send("📭 Расписание пусто")
+	}
+	res := "🗓 Расписание:"
+	for _, s := range list {
+		res += "\n\n" + formatSchedule(s)
+	}
+	return c.Send(res + "\n\nУдалить: /unschedule [номер]")
+}
+
+// unschedule удаляет запись расписания: /unschedule [номер].
+func (h *Handlers) unschedule(c telebot.Context) error {
+	if !h.can(c, bank.PermBroadcast) {
+		return nil
+	}
+	if len(c.Args()) < 1 {
+		return c.Send("⚠️ Формат: /unschedule [номер]")
+	}
+	id, err := parseRequestID(c.Args()[0])
+	if err != nil {
+		return c.Send("❌ Некорректный номер записи")
+	}
+	err = h.bank.Unschedule(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.Send("❌ Запись расписания не найдена")
+	}
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	return c.Send(fmt.Sprintf("🗑 Запись #%d удалена из расписания", id))
+}
+
+// startScheduled ставит в очередь рассылки, время которых наступило, и
+// присылает автору сообщение с ходом рассылки.
+func (h *Handlers) startScheduled() {
+	jobs, skipped, err := h.bank.RunSchedules()
+	for _, s := range skipped {
+		log.Printf("⏰ Рассылка по расписанию #%d пропущена: %v", s.ID, s.Err)
+	}
+	if err != nil {
+		log.Println("❌ Ошибка рассылок по расписанию:", err)
+	}
+	for _, job := range jobs {
+		chatID, err := strconv.ParseInt(job.CreatedBy, 10, 64)
+		if err != nil {
+			continue
+		}
+		msg, err := h.tg.Send(&telebot.User{ID: chatID}, broadcastProgress(job), broadcastMarkup(job))
+		if err != nil {
+			log.Println("❌ Ошибка отправки хода рассылки:", err)
+			continue
+		}
+		if err := h.bank.SetBroadcastMessage(job.ID, chatID, msg.ID); err != nil {
+			log.Println("❌ Ошибка сохранения хода рассылки:", err)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/telebot.v3"
+
+	"mybot/internal/bank"
+	"mybot/internal/storage"
+)
+
+var ticketStatusTitles = map[string]string{
+	storage.TicketOpen:       "🆕 открыто",
+	storage.TicketInProgress: "🛠 в работе",
+	storage.TicketResolved:   "✅ решено",
+	storage.TicketRejected:   "🚫 отклонено",
+}
+
+// replyPrompt — начало сообщения, на которое администратор отвечает текстом
+// ответа игроку; по нему onText находит номер обращения.
+const replyPrompt = "✍️ Ответ на обращение #"
+
+// ticketMarkup — кнопки действий по открытому обращению.
+func ticketMarkup(id int64) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	btnReply := markup.Data("✍️ Ответить", "reply_ticket", fmt.Sprintf("reply_ticket:%d", id))
+	btnAssign := markup.Data("🙋 Взять себе", "assign_ticket", fmt.Sprintf("assign_ticket:%d", id))
+	btnResolve := markup.Data("✅ Решено", "resolve_ticket", fmt.Sprintf("resolve_ticket:%d", id))
+	btnReject := markup.Data("🚫 Отклонить", "reject_ticket", fmt.Sprintf("reject_ticket:%d", id))
+	markup.Inline(markup.Row(btnReply, btnAssign), markup.Row(btnResolve, btnReject))
+	return markup
+}
+
+func shorten(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
+
+// formatTicket — обращение с перепиской для администратора.
+func formatTicket(t bank.Ticket) string {
+	res := fmt.Sprintf("📋 Обращение #%d — %s\n👤 От: %s (ID: %s)\n📅 %s", t.ID, ticketStatusTitles[t.Status], t.Nick, t.UserID, t.CreatedAt.Format("02.01.2006 15:04"))
+	if t.AssignedTo != "" {
+		res += "\n🧑‍💼 Исполнитель: " + t.AssignedTo
+	}
+	res += "\n\n💬 " + t.Text
+	for _, m := range t.Messages {
+		who := "👤 Игрок"
+		if m.FromAdmin {
+			who = "🛡 " + m.AuthorID
+		}
+		res += fmt.Sprintf("\n\n%s, %s:\n%s", who, m.CreatedAt.Format("02.01 15:04"), m.Text)
+	}
+	return res
+}
+
+// tickets показывает обращения: /tickets — открытые и в работе,
+// /tickets mine — назначенные мне, /tickets all — все последние.
+func (h *Handlers) tickets(c telebot.Context) error {
+	if !h.can(c, bank.PermComplaints) {
+		return nil
+	}
+	f := storage.ComplaintFilter{Statuses: []string{storage.TicketOpen, storage.TicketInProgress}, Limit: 30}
+	if args := c.Args(); len(args) > 0 && args[0] == "mine" {
+		f.AssignedTo = senderID(c)
+	} else if len(args) > 0 && args[0] == "all" {
+		f.Statuses = nil
+	}
+	list, err := h.bank.Tickets(f)
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	if len(list) == 0 {
+		return c.Send("📭 Обращений нет")
+	}
+	res := "📋 Обращения:\n"
+	for _, t := range list {
+		res += fmt.Sprintf("\n#%d %s · %s · %s", t.ID, ticketStatusTitles[t.Status], t.Nick, shorten(t.Text, 40))
+	}
+	return c.Send(res + "\n\nПодробнее: /ticket [номер]")
+}
+
+// ticket показывает обращение с перепиской и кнопками: /ticket [номер].
+func (h *Handlers) ticket(c telebot.Context) error {
+	if !h.can(c, bank.PermComplaints) {
+		return nil
+	}
+	if len(c.Args()) < 1 {
+		return c.Send("⚠️ Формат: /ticket [номер]")
+	}
+	id, err := parseRequestID(c.Args()[0])
+	if err != nil {
+		return c.Send("❌ Некорректный номер обращения")
+	}
+	t, err := h.bank.Ticket(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.Send("❌ Обращение не найдено")
+	}
+	if err != nil {
+		return c.Send("❌ Ошибка БД")
+	}
+	if t.Closed() {
+		c.Send(formatTicket(t))
+	} else {
+		c.Send(formatTicket(t), ticketMarkup(id))
+	}
+	h.sendAttachments(c, storage.AttachTicket, id)
+	return nil
+}
+
+// onTicketCallback обрабатывает кнопки обращения: reply_ticket,
+// assign_ticket, resolve_ticket и reject_ticket.
+func (h *Handlers) onTicketCallback(c telebot.Context, action, arg string) error {
+	if !h.can(c, bank.PermComplaints) {
+		c.Respond(&telebot.CallbackResponse{Text: "⛔ Недостаточно прав"})
+		return nil
+	}
+	id, err := parseRequestID(arg)
+	if err != nil {
+		c.Respond(&telebot.CallbackResponse{Text: "Обращение не найдено"})
+		return nil
+	}
+	uid := senderID(c)
+
+	var t storage.Complaint
+	switch action {
+	case "reply_ticket":
+		c.Respond()
+		return c.Send(fmt.Sprintf("%s%d\nОтветьте на это сообщение текстом для игрока.", replyPrompt, id), &telebot.ReplyMarkup{ForceReply: true})
+	case "assign_ticket":
+		t, err = h.bank.AssignTicket(id, uid)
+	case "resolve_ticket":
+		t, err = h.bank.CloseTicket(id, uid, storage.TicketResolved)
+	case "reject_ticket":
+		t, err = h.bank.CloseTicket(id, uid, storage.TicketRejected)
+	default:
+		return nil
+	}
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.Respond(&telebot.CallbackResponse{Text: "Обращение не найдено"})
+		return nil
+	case errors.Is(err, bank.ErrTicketClosed):
+		editText(c, fmt.Sprintf("ℹ️ Обращение #%d уже закрыто: %s", id, ticketStatusTitles[t.Status]))
+		c.Respond(&telebot.CallbackResponse{Text: "Обращение уже закрыто"})
+		return nil
+	case err != nil:
+		log.Println("❌ Ошибка обработки обращения:", err)
+		c.Respond(&telebot.CallbackResponse{Text: "Ошибка БД"})
+		return nil
+	}
+
+	switch t.Status {
+	case storage.TicketInProgress:
+		editText(c, fmt.Sprintf("🛠 Обращение #%d в работе у %s\n👤 От: %s (ID: %s)\n\n💬 %s", id, uid, t.Nick, t.UserID, t.Text), ticketMarkup(id))
+		c.Respond(&telebot.CallbackResponse{Text: "Обращение назначено вам"})
+	case storage.TicketResolved:
+		h.notify(t.UserID, fmt.Sprintf("✅ Ваше обращение #%d решено. Спасибо, что написали!", id))
+		editText(c, fmt.Sprintf("✅ ОБРАЩЕНИЕ #%d РЕШЕНО\n👤 От: %s (ID: %s)", id, t.Nick, t.UserID))
+		c.Respond(&telebot.CallbackResponse{Text: "✅ Решено"})
+	case storage.TicketRejected:
+		h.notify(t.UserID, fmt.Sprintf("🚫 Ваше обращение #%d отклонено администрацией.", id))
+		editText(c, fmt.Sprintf("🚫 ОБРАЩЕНИЕ #%d ОТКЛОНЕНО\n👤 От: %s (ID: %s)", id, t.Nick, t.UserID))
+		c.Respond(&telebot.CallbackResponse{Text: "🚫 Отклонено"})
+	}
+	return nil
+}
+
+// onText принимает ответ администратора игроку: ответ на сообщение
+// replyPrompt. Остальной текст бот не обрабатывает.
+func (h *Handlers) onText(c telebot.Context) error {
+	m := c.Message()
+	if m == nil || m.ReplyTo == nil || !strings.HasPrefix(m.ReplyTo.Text, replyPrompt) {
+		return nil
+	}
+	if !h.can(c, bank.PermComplaints) {
+		return nil
+	}
+	num, _, _ := strings.Cut(strings.TrimPrefix(m.ReplyTo.Text, replyPrompt), "\n")
+	id, err := parseRequestID(num)
+	if err != nil {
+		return c.Send("❌ Некорректный номер обращения")
+	}
+
+	t, err := h.bank.ReplyTicket(id, senderID(c), true, m.Text)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return c.Send("❌ Обращение не найдено")
+	case errors.Is(err, bank.ErrTicketClosed), errors.Is(err, bank.ErrComplaintEmpty), errors.Is(err, bank.ErrComplaintTooLong):
+		return c.Send("❌ " + err.Error())
+	case err != nil:
+		log.Println("❌ Ошибка ответа на обращение:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+	h.notify(t.UserID, fmt.Sprintf("💬 Ответ администрации по обращению #%d:\n\n%s", id, strings.TrimSpace(m.Text)))
+	return c.Send(fmt.Sprintf("✅ Ответ по обращению #%d отправлен игроку %s", id, t.Nick), ticketMarkup(id))
+}
+
+// ticketReply — дополнение игрока к своему обращению из WebApp.
+func (h *Handlers) ticketReply(c telebot.Context, uid string, d WebAppData) error {
+	t, err := h.bank.ReplyTicket(d.TicketID, uid, false, d.Complaint)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return c.Send("❌ Обращение не найдено.")
+	case errors.Is(err, bank.ErrTicketClosed), errors.Is(err, bank.ErrComplaintEmpty), errors.Is(err, bank.ErrComplaintTooLong):
+		return c.Send("❌ " + err.Error())
+	case err != nil:
+		log.Println("❌ Ошибка сообщения по обращению:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+
+	msg := fmt.Sprintf("💬 ДОПОЛНЕНИЕ К ОБРАЩЕНИЮ #%d\n👤 От: %s (ID: %s)\n\n%s", t.ID, t.Nick, uid, strings.TrimSpace(d.Complaint))
+	if t.AssignedTo != "" {
+		h.notify(t.AssignedTo, msg, ticketMarkup(t.ID))
+	} else {
+		h.notifyAdmins(bank.PermComplaints, msg, ticketMarkup(t.ID))
+	}
+	return c.Send(fmt.Sprintf("✅ Сообщение добавлено к обращению #%d.", t.ID))
+}
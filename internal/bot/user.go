@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"mybot/internal/storage"
+)
+
+func (h *Handlers) start(c telebot.Context) error {
+	uid := senderID(c)
+
+	if h.bank.IsBanned(uid) {
+		return c.Send("🚫 Ваш аккаунт заблокирован. Обратитесь к администрации.")
+	}
+
+	u, _ := h.bank.User(uid)
+	return c.Send("🇸🇪 Добро пожаловать в финансовую систему Швеции.", h.webAppMenu(uid, u.Nick != "", u.Nick, u.Role))
+}
+
+func (h *Handlers) history(c telebot.Context) error {
+	uid := senderID(c)
+	if h.bank.IsBanned(uid) {
+		return c.Send("🚫 Ваш аккаунт заблокирован.")
+	}
+
+	f := storage.HistoryFilter{Limit: 15}
+	for _, arg := range c.Args() {
+		key, val, _ := strings.Cut(arg, "=")
+		var err error
+		switch key {
+		case "type":
+			f.Kind = val
+		case "from":
+			f.From, err = time.Parse("02.01.2006", val)
+		case "to":
+			f.To, err = time.Parse("02.01.2006", val)
+			f.To = f.To.AddDate(0, 0, 1)
+		case "before":
+			f.Before, err = strconv.ParseInt(val, 10, 64)
+		default:
+			err = fmt.Errorf("неизвестный параметр %q", key)
+		}
+		if err != nil {
+			return c.Send("⚠️ Формат: /history [type=transfer|buy_bond|sell_bond|withdraw|deposit|admin_deposit] [from=дд.мм.гггг] [to=дд.мм.гггг]")
+		}
+	}
+
+	items, err := h.bank.History(uid, f)
+	if err != nil {
+		log.Println("❌ Ошибка истории:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+	if len(items) == 0 {
+		return c.Send("📜 Операций не найдено.")
+	}
+
+	res := "📜 История операций:\n\n"
+	for _, it := range items {
+		res += fmt.Sprintf("%s %s\n%s GOLD · %s", it.CreatedAt.Format("02.01 15:04"), txTitles[it.Kind], it.Amount.Signed(), it.Counterparty)
+		if it.BalanceAfter != nil {
+			res += fmt.Sprintf("\n💼 Остаток: %s GOLD", *it.BalanceAfter)
+		}
+		res += "\n\n"
+	}
+	if len(items) == f.Limit {
+		next := []string{fmt.Sprintf("before=%d", items[len(items)-1].ID)}
+		for _, arg := range c.Args() {
+			if !strings.HasPrefix(arg, "before=") {
+				next = append(next, arg)
+			}
+		}
+		res += "➡️ Дальше: /history " + strings.Join(next, " ")
+	}
+	return c.Send(res)
+}
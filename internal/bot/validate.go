@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"errors"
+	"strconv"
+
+	"mybot/internal/bank"
+	"mybot/internal/money"
+)
+
+// Какие поля WebAppData обязательны для действия.
+var (
+	amountActions  = map[string]bool{"buy_bond": true, "withdraw_bond": true, "topup_bond": true, "transfer": true, "withdraw": true, "deposit_request": true, "list_bond": true}
+	bondActions    = map[string]bool{"buy_bond": true, "sell_bond": true, "withdraw_bond": true, "topup_bond": true, "list_bond": true}
+	listingActions = map[string]bool{"cancel_listing": true, "buy_listing": true}
+)
+
+// validate проверяет суммы и номера, пришедшие из WebApp, до обращения к
+// банку. Банк проверяет суммы ещё раз, здесь — понятные сообщения игроку.
+func (d WebAppData) validate() error {
+	if amountActions[d.Action] {
+		if err := bank.CheckAmount(d.Amount); err != nil {
+			return err
+		}
+	}
+	if bondActions[d.Action] {
+		if err := bank.CheckBondID(d.BondID); err != nil {
+			return err
+		}
+	}
+	if listingActions[d.Action] && d.ListingID <= 0 {
+		return bank.ErrBadListingID
+	}
+	if d.Action == "cancel_request" && d.RequestID <= 0 {
+		return bank.ErrBadRequestID
+	}
+	if d.Action == "ticket_reply" && d.TicketID <= 0 {
+		return bank.ErrBadTicketID
+	}
+	if d.Action == "transfer" {
+		if d.Target == "" && d.TargetID == "" {
+			return bank.ErrNoRecipient
+		}
+		if d.Target == "" {
+			return bank.CheckUserID(d.TargetID)
+		}
+	}
+	return nil
+}
+
+// isInputError — ошибки проверки входных данных; их текст показывается как есть.
+func isInputError(err error) bool {
+	for _, e := range []error{bank.ErrAmountNotPositive, bank.ErrAmountTooLarge, bank.ErrBadBondID,
+		bank.ErrBadListingID, bank.ErrBadRequestID, bank.ErrBadTicketID, bank.ErrBadUserID, bank.ErrNoRecipient} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAmount разбирает сумму из аргумента команды или кнопки и проверяет её.
+func parseAmount(s string) (money.Money, error) {
+	v, err := money.Parse(s)
+	if err != nil {
+		return 0, err
+	}
+	return v, bank.CheckAmount(v)
+}
+
+// parseBondID разбирает номер вклада или облигации из аргумента команды.
+func parseBondID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, bank.ErrBadBondID
+	}
+	return id, bank.CheckBondID(id)
+}
+
+// parseRequestID разбирает номер заявки или перевода из callback-данных.
+func parseRequestID(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, bank.ErrBadRequestID
+	}
+	return id, nil
+}
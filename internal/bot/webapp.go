@@ -0,0 +1,364 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"gopkg.in/telebot.v3"
+
+	"mybot/internal/bank"
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+// WebAppData — данные, которые WebApp отправляет боту через sendData.
+type WebAppData struct {
+	Action   string `json:"action"`
+	Nick     string `json:"nick"`
+	Role     string `json:"role"`
+	TargetID string `json:"target_id"`
+	// Target — получатель перевода: ID или ник; старые версии WebApp шлют TargetID.
+	Target    string      `json:"target"`
+	Comment   string      `json:"comment"`
+	Amount    money.Money `json:"amount"`
+	BondID    int         `json:"bond_id"`
+	RequestID int64       `json:"request_id"`
+	ListingID int64       `json:"listing_id"`
+	// Complaint — текст жалобы или, для ticket_reply, сообщения по обращению TicketID.
+	Complaint string `json:"complaint"`
+	TicketID  int64  `json:"ticket_id"`
+}
+
+func (h *Handlers) onWebApp(c telebot.Context) error {
+	if c.Message().WebAppData == nil {
+		return nil
+	}
+	var d WebAppData
+	if err := json.Unmarshal([]byte(c.Message().WebAppData.Data), &d); err != nil {
+		log.Println("❌ Некорректные данные WebApp:", err)
+		return c.Send("❌ Некорректные данные: " + err.Error())
+	}
+	uid := senderID(c)
+
+	if h.bank.IsBanned(uid) {
+		return c.Send("🚫 Ваш аккаунт заблокирован.")
+	}
+	if err := d.validate(); err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+
+	switch d.Action {
+	case "register":
+		return h.register(c, uid, d)
+	case "buy_bond":
+		return h.buyBond(c, uid, d)
+	case "sell_bond":
+		return h.sellBond(c, uid, d)
+	case "withdraw_bond":
+		return h.withdrawFromBond(c, uid, d)
+	case "topup_bond":
+		return h.topUpBond(c, uid, d)
+	case "transfer":
+		return h.transfer(c, uid, d)
+	case "withdraw", "deposit_request":
+		return h.requestMoney(c, uid, d)
+	case "cancel_request":
+		return h.cancelRequest(c, uid, d)
+	case "complaint":
+		return h.complaint(c, uid, d)
+	case "ticket_reply":
+		return h.ticketReply(c, uid, d)
+	case "list_bond":
+		return h.listBond(c, uid, d)
+	case "cancel_listing":
+		return h.cancelListing(c, uid, d)
+	case "buy_listing":
+		return h.buyListing(c, uid, d)
+	}
+	return nil
+}
+
+// register заводит игрока; роль уже зарегистрированного не меняется.
+func (h *Handlers) register(c telebot.Context, uid string, d WebAppData) error {
+	err := h.bank.Register(uid, d.Nick, d.Role)
+	if errors.Is(err, bank.ErrBadRole) || errors.Is(err, bank.ErrNickTaken) {
+		return c.Send("❌ " + err.Error())
+	}
+	if err != nil {
+		return c.Send("❌ Ошибка регистрации")
+	}
+	u, err := h.bank.User(uid)
+	if err != nil {
+		return c.Send("❌ Ошибка регистрации")
+	}
+	return c.Send("✅ Регистрация завершена! Аккаунт активирован:", h.webAppMenu(uid, true, u.Nick, u.Role))
+}
+
+func (h *Handlers) buyBond(c telebot.Context, uid string, d WebAppData) error {
+	bond, err := h.bank.BuyBond(uid, d.BondID, d.Amount)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, bank.ErrBelowMinimum) || errors.Is(err, bank.ErrInsufficientFunds) {
+		return c.Send("❌ Ошибка покупки: проверьте баланс или сумму.")
+	}
+	if errors.Is(err, bank.ErrNotOnSale) || errors.Is(err, bank.ErrSubscriptionClosed) || errors.Is(err, bank.ErrSupplyExhausted) || errors.Is(err, bank.ErrHoldingLimit) {
+		return c.Send("❌ Ошибка покупки: " + err.Error() + ".")
+	}
+	if err != nil {
+		log.Println("❌ Ошибка покупки облигации:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+
+	maturity := "бессрочно"
+	if !bond.MaturesAt.IsZero() {
+		maturity = bond.MaturesAt.Format("02.01.2006 15:04")
+	}
+	h.notifyAdmins(bank.PermFinance, fmt.Sprintf("📈 НОВАЯ ИНВЕСТИЦИЯ\n👤 Игрок: %s\n💰 Сумма: %s GOLD\n📊 Облигация: %s\n📈 Процент: %.2f%%\n📅 Дата: %s\n⏳ Погашение: %s",
+		d.Nick, d.Amount, bond.Name, bond.Rate, bond.CreatedAt.Format("02.01.2006 15:04"), maturity))
+
+	if bond.MaturesAt.IsZero() {
+		return c.Send(fmt.Sprintf("✅ Вы инвестировали %s GOLD в %s", d.Amount, bond.Name))
+	}
+	return c.Send(fmt.Sprintf("✅ Вы инвестировали %s GOLD в %s\n⏳ Дата погашения: %s", d.Amount, bond.Name, maturity))
+}
+
+func (h *Handlers) sellBond(c telebot.Context, uid string, d WebAppData) error {
+	sale, err := h.bank.SellBond(uid, d.BondID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.Send("❌ Инвестиция не найдена.")
+	}
+	if errors.Is(err, bank.ErrBondLocked) {
+		return c.Send("🔒 Эта инвестиция заморожена администрацией. Обратитесь к админу.")
+	}
+	if err != nil {
+		log.Println("❌ Ошибка закрытия вклада:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+	return c.Send(fmt.Sprintf("💰 Вклад закрыт! Получено %s GOLD", sale.Paid) + feeLine(sale.Fee))
+}
+
+// withdrawFromBond снимает часть стоимости вклада на баланс.
+func (h *Handlers) withdrawFromBond(c telebot.Context, uid string, d WebAppData) error {
+	v, err := h.bank.WithdrawFromBond(uid, d.BondID, d.Amount)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.Send("❌ Инвестиция не найдена.")
+	}
+	if errors.Is(err, bank.ErrBondLocked) {
+		return c.Send("🔒 Частично снять можно только разблокированный или погашенный вклад.")
+	}
+	if errors.Is(err, bank.ErrPartialTooLarge) || isInputError(err) {
+		return c.Send("❌ " + err.Error())
+	}
+	if err != nil {
+		log.Println("❌ Ошибка частичного снятия:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+	return c.Send(fmt.Sprintf("💰 Со вклада %s #%d снято %s GOLD\n📊 Остаток вклада: %s GOLD", v.Name, v.ID, d.Amount, v.CurrentValue))
+}
+
+// topUpBond пополняет открытый вклад под его прежний процент.
+func (h *Handlers) topUpBond(c telebot.Context, uid string, d WebAppData) error {
+	v, err := h.bank.TopUpBond(uid, d.BondID, d.Amount)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.Send("❌ Инвестиция не найдена.")
+	}
+	if errors.Is(err, bank.ErrInsufficientFunds) {
+		return c.Send("❌ Недостаточно средств для пополнения")
+	}
+	if errors.Is(err, bank.ErrBondMatured) || errors.Is(err, bank.ErrNotOnSale) || errors.Is(err, bank.ErrSubscriptionClosed) || errors.Is(err, bank.ErrSupplyExhausted) ||
+		errors.Is(err, bank.ErrHoldingLimit) || isInputError(err) {
+		return c.Send("❌ Ошибка пополнения: " + err.Error() + ".")
+	}
+	if err != nil {
+		log.Println("❌ Ошибка пополнения вклада:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+	return c.Send(fmt.Sprintf("✅ Вклад %s #%d пополнен на %s GOLD\n📊 Стоимость вклада: %s GOLD (%.2f%%)", v.Name, v.ID, d.Amount, v.CurrentValue, v.Rate))
+}
+
+// transfer готовит перевод и просит отправителя подтвердить его кнопкой;
+// деньги уходят только в confirmTransfer.
+func (h *Handlers) transfer(c telebot.Context, uid string, d WebAppData) error {
+	target := d.Target
+	if target == "" {
+		target = d.TargetID
+	}
+	t, err := h.bank.PrepareTransfer(uid, target, d.Amount, d.Comment)
+	if errors.Is(err, bank.ErrInsufficientFunds) {
+		return c.Send("❌ Недостаточно средств для перевода" + feeLine(t.Fee))
+	}
+	if isTransferError(err) || isInputError(err) {
+		return c.Send("❌ " + err.Error())
+	}
+	if err != nil {
+		log.Println("❌ Ошибка подготовки перевода:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+
+	markup := &telebot.ReplyMarkup{}
+	markup.Inline(markup.Row(
+		markup.Data("✅ Подтвердить", "confirm_transfer", fmt.Sprintf("confirm_transfer:%d", t.ID)),
+		markup.Data("❌ Отмена", "cancel_transfer", fmt.Sprintf("cancel_transfer:%d", t.ID)),
+	))
+	msg := fmt.Sprintf("💸 Подтвердите перевод\n👤 Получатель: %s (ID: %s)\n💰 Сумма: %s GOLD", t.ToNick, t.ToID, t.Amount) + feeLine(t.Fee)
+	if t.Fee > 0 {
+		msg += fmt.Sprintf("\n🧾 Итого к списанию: %s GOLD", t.Amount+t.Fee)
+	}
+	if t.Comment != "" {
+		msg += "\n💬 Комментарий: " + t.Comment
+	}
+	msg += fmt.Sprintf("\n\n⏳ Подтверждение действует %d мин.", int(bank.TransferTTL.Minutes()))
+	return c.Send(msg, markup)
+}
+
+// isTransferError — ошибки проверки перевода, которые можно показать игроку как есть.
+func isTransferError(err error) bool {
+	for _, e := range []error{bank.ErrUnknownRecipient, bank.ErrAmbiguousRecipient, bank.ErrSelfTransfer,
+		bank.ErrRecipientBanned, bank.ErrCommentTooLong, bank.ErrTransferExpired, storage.ErrTransferClosed} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestMoney создаёт заявку на вывод или пополнение и рассылает её
+// финансовым администраторам с кнопками решения.
+func (h *Handlers) requestMoney(c telebot.Context, uid string, d WebAppData) error {
+	kind := storage.RequestWithdraw
+	if d.Action == "deposit_request" {
+		kind = storage.RequestDeposit
+	}
+	// Вывод и так одобряет администрация, поэтому превышение лимита не
+	// останавливает заявку, а только помечается в сообщении администраторам.
+	var breach *bank.LimitBreach
+	if kind == storage.RequestWithdraw {
+		var err error
+		if breach, err = h.bank.CheckLimits(uid, bank.FeeWithdraw, d.Amount); err != nil {
+			log.Println("❌ Ошибка проверки лимитов:", err)
+			return c.Send("❌ Ошибка БД")
+		}
+	}
+	r, err := h.bank.RequestMoney(uid, kind, d.Amount)
+	if err != nil {
+		log.Println("❌ Ошибка создания заявки:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+
+	markup := &telebot.ReplyMarkup{}
+	if kind == storage.RequestWithdraw {
+		btnApprove := markup.Data("✅ Одобрить", "approve", fmt.Sprintf("approve:%d", r.ID))
+		btnReject := markup.Data("❌ Отклонить", "reject", fmt.Sprintf("reject:%d", r.ID))
+		markup.Inline(markup.Row(btnApprove, btnReject))
+
+		h.notifyAdmins(bank.PermFinance, fmt.Sprintf("⚠️ ЗАПРОС НА ВЫВОД #%d\n👤 От: %s (ID: %s)\n💰 Сумма: %s GOLD", r.ID, d.Nick, uid, d.Amount)+feeLine(r.Fee)+breachLine(breach), markup)
+		return c.Send(fmt.Sprintf("✅ Ваш запрос на вывод средств #%d отправлен на проверку администратору.", r.ID) + feeLine(r.Fee))
+	}
+
+	window, err := h.bank.EvidenceWindow()
+	if err != nil {
+		log.Println("❌ Ошибка чтения настроек:", err)
+	}
+	deadline := ""
+	if window > 0 {
+		deadline = fmt.Sprintf("\n⏳ Без скриншота заявка истечёт через %.0f ч.", window.Hours())
+	}
+	h.notifyAdmins(bank.PermFinance, fmt.Sprintf("💳 ЗАПРОС НА ПОПОЛНЕНИЕ #%d\n👤 От: %s (ID: %s)\n💰 Сумма: %s GOLD\n\n📸 Скриншот пополнения казны придёт отдельным сообщением.", r.ID, d.Nick, uid, d.Amount), depositMarkup(r.ID))
+	return c.Send(fmt.Sprintf("✅ Ваш запрос на пополнение #%d отправлен администратору.\n\n📸 Отправьте скриншот пополнения казны (/n deposit ваша сумма) в этот чат — фото или файлом.", r.ID) + deadline)
+}
+
+func (h *Handlers) cancelRequest(c telebot.Context, uid string, d WebAppData) error {
+	r, err := h.bank.CancelRequest(uid, d.RequestID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.Send("❌ Заявка не найдена.")
+	}
+	if errors.Is(err, storage.ErrRequestClosed) {
+		return c.Send(fmt.Sprintf("ℹ️ Заявку #%d уже нельзя отменить: %s", r.ID, statusTitles[r.Status]))
+	}
+	if err != nil {
+		log.Println("❌ Ошибка отмены заявки:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+	return c.Send(fmt.Sprintf("🚫 Заявка #%d на %s %s GOLD отменена.", r.ID, requestTitles[r.Kind], r.Amount))
+}
+
+func (h *Handlers) complaint(c telebot.Context, uid string, d WebAppData) error {
+	t, wait, err := h.bank.Complain(uid, d.Nick, d.Complaint)
+	if errors.Is(err, bank.ErrComplaintCooldown) {
+		return c.Send(fmt.Sprintf("⏳ Вы сможете отправить новую жалобу через %.1f часов", wait.Hours()))
+	}
+	if errors.Is(err, bank.ErrComplaintEmpty) {
+		return c.Send("❌ Жалоба не может быть пустой")
+	}
+	if errors.Is(err, bank.ErrComplaintTooLong) {
+		return c.Send(fmt.Sprintf("❌ Жалоба слишком длинная: не больше %d символов", bank.MaxTicketText))
+	}
+	if err != nil {
+		log.Println("❌ Ошибка сохранения жалобы:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+
+	h.notifyAdmins(bank.PermComplaints, fmt.Sprintf("📋 НОВАЯ ЖАЛОБА #%d\n👤 От: %s (ID: %s)\n📅 Время: %s\n\n💬 Жалоба:\n%s",
+		t.ID, d.Nick, uid, t.CreatedAt.Format("02.01.2006 15:04"), t.Text), ticketMarkup(t.ID))
+
+	return c.Send(fmt.Sprintf("✅ Ваша жалоба #%d отправлена администрации. Ответ придёт в этот чат.\n📎 Скриншоты и файлы можно прислать сюда же.", t.ID))
+}
+
+// listBond выставляет вклад на вторичный рынок; Amount — цена продажи.
+func (h *Handlers) listBond(c telebot.Context, uid string, d WebAppData) error {
+	l, err := h.bank.ListBond(uid, d.BondID, d.Amount)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.Send("❌ Инвестиция не найдена.")
+	}
+	if errors.Is(err, bank.ErrBondLocked) {
+		return c.Send("🔒 Заблокированные вклады нельзя продавать другим игрокам.")
+	}
+	if errors.Is(err, bank.ErrAlreadyListed) || isInputError(err) {
+		return c.Send("❌ " + err.Error())
+	}
+	if err != nil {
+		log.Println("❌ Ошибка выставления вклада:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+	return c.Send(fmt.Sprintf("🏷 Вклад #%d выставлен на продажу за %s GOLD (объявление #%d).", l.BondID, l.Price, l.ID))
+}
+
+func (h *Handlers) cancelListing(c telebot.Context, uid string, d WebAppData) error {
+	l, err := h.bank.CancelListing(uid, d.ListingID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.Send("❌ Объявление не найдено.")
+	}
+	if errors.Is(err, storage.ErrListingClosed) {
+		return c.Send(fmt.Sprintf("ℹ️ Объявление #%d уже закрыто.", l.ID))
+	}
+	if err != nil {
+		log.Println("❌ Ошибка снятия объявления:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+	return c.Send(fmt.Sprintf("🚫 Объявление #%d снято с продажи.", l.ID))
+}
+
+// buyListing покупает вклад у другого игрока и сообщает продавцу о сделке.
+func (h *Handlers) buyListing(c telebot.Context, uid string, d WebAppData) error {
+	t, err := h.bank.BuyListing(uid, d.ListingID)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrListingClosed) {
+		return c.Send("❌ Объявление уже неактуально.")
+	}
+	if errors.Is(err, bank.ErrOwnListing) {
+		return c.Send("❌ " + err.Error())
+	}
+	if errors.Is(err, bank.ErrBondLocked) {
+		return c.Send("🔒 Продажа заблокированных вкладов сейчас запрещена.")
+	}
+	if errors.Is(err, bank.ErrInsufficientFunds) {
+		return c.Send("❌ Недостаточно средств для покупки")
+	}
+	if err != nil {
+		log.Println("❌ Ошибка покупки вклада:", err)
+		return c.Send("❌ Ошибка БД")
+	}
+
+	h.notify(t.Listing.SellerID, fmt.Sprintf("🤝 Ваш вклад %s #%d куплен!\n👤 Покупатель: %s\n💵 Зачислено: %s GOLD (комиссия %s GOLD)",
+		t.Bond.Name, t.Bond.ID, t.BuyerNick, t.Listing.Price-t.Fee, t.Fee))
+	return c.Send(fmt.Sprintf("✅ Вы купили вклад %s #%d у %s за %s GOLD", t.Bond.Name, t.Bond.ID, t.SellerNick, t.Listing.Price))
+}
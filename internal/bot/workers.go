@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"mybot/internal/bank"
+)
+
+// RunWorkers запускает фоновые задачи бота и блокируется до отмены ctx.
+func (h *Handlers) RunWorkers(ctx context.Context) {
+	go h.runBroadcasts(ctx)
+
+	tick := time.NewTicker(10 * time.Minute)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			h.expireRequests()
+			h.expireUnverified()
+			h.matureBonds()
+			h.payCoupons()
+			h.checkTreasury()
+		}
+	}
+}
+
+// expireRequests закрывает просроченные заявки и сообщает об этом игрокам.
+func (h *Handlers) expireRequests() {
+	expired, err := h.bank.ExpireRequests()
+	if err != nil {
+		log.Println("❌ Ошибка истечения заявок:", err)
+		return
+	}
+	for _, r := range expired {
+		h.notify(r.UserID, fmt.Sprintf("⌛ Заявка #%d на %s %s GOLD истекла без ответа администрации. Отправьте запрос заново.", r.ID, requestTitles[r.Kind], r.Amount))
+	}
+}
+
+// expireUnverified закрывает заявки на пополнение, к которым так и не
+// прислали скриншот.
+func (h *Handlers) expireUnverified() {
+	expired, err := h.bank.ExpireUnverified()
+	if err != nil {
+		log.Println("❌ Ошибка истечения заявок без скриншота:", err)
+		return
+	}
+	for _, r := range expired {
+		h.notify(r.UserID, fmt.Sprintf("⌛ Заявка #%d на пополнение %s GOLD истекла: скриншот пополнения так и не пришёл. Отправьте запрос заново.", r.ID, r.Amount))
+	}
+}
+
+// matureBonds разблокирует или погашает вклады с наступившим сроком и
+// сообщает владельцам.
+func (h *Handlers) matureBonds() {
+	matured, err := h.bank.MatureBonds()
+	if err != nil {
+		log.Println("❌ Ошибка погашения вкладов:", err)
+	}
+	for _, m := range matured {
+		if m.Redeemed {
+			h.notify(m.Bond.UserID, fmt.Sprintf("💰 Вклад %s #%d погашен!\n💵 Зачислено на баланс: %s GOLD", m.Bond.Name, m.Bond.ID, m.Value))
+			continue
+		}
+		h.notify(m.Bond.UserID, fmt.Sprintf("🔓 Вклад %s #%d достиг срока погашения и доступен к выводу.\n💵 Стоимость: %s GOLD", m.Bond.Name, m.Bond.ID, m.Value))
+	}
+}
+
+// payCoupons выплачивает наступившие купоны и сообщает владельцам.
+func (h *Handlers) payCoupons() {
+	paid, err := h.bank.PayCoupons()
+	if err != nil {
+		log.Println("❌ Ошибка выплаты купонов:", err)
+	}
+	for _, p := range paid {
+		h.notify(p.Bond.UserID, fmt.Sprintf("🎟 Купон по вкладу %s #%d: +%s GOLD на баланс", p.Bond.Name, p.Bond.ID, p.Amount))
+	}
+}
+
+// checkTreasury предупреждает администрацию, когда начисленные проценты по
+// вкладам превышают резервы казны.
+func (h *Handlers) checkTreasury() {
+	t, err := h.bank.Treasury()
+	if err != nil {
+		log.Println("❌ Ошибка проверки казны:", err)
+		return
+	}
+	short := t.Shortfall() > 0
+	if short && !h.treasuryShort {
+		h.notifyAdmins(bank.PermFinance, fmt.Sprintf("⚠️ КАЗНА В ДЕФИЦИТЕ\n💰 Резервы: %s GOLD\n📈 Начисленные проценты по вкладам: %s GOLD\n❗ Недостаёт: %s GOLD\n\nПополните казну: /treasury_fund [Сумма]", t.Reserves, t.Interest, t.Shortfall()))
+	}
+	h.treasuryShort = short
+}
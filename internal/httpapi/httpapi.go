@@ -0,0 +1,163 @@
+// Package httpapi — HTTP API для WebApp. Каждый запрос подписан initData
+// Telegram, пользователь берётся только из подписанных данных.
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mybot/internal/bank"
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+type API struct {
+	bank     *bank.Bank
+	botToken string
+	origin   string
+	now      func() time.Time
+}
+
+func New(b *bank.Bank, botToken, webAppURL string) *API {
+	return &API{bank: b, botToken: botToken, origin: originOf(webAppURL), now: time.Now}
+}
+
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/get_user_data", a.auth(a.getUserData))
+	mux.HandleFunc("/api/history", a.auth(a.history))
+	mux.HandleFunc("/api/get_users", a.auth(a.getUsers))
+	mux.HandleFunc("/api/get_market", a.auth(a.getMarket))
+	mux.HandleFunc("/api/get_listings", a.auth(a.getListings))
+	mux.HandleFunc("/api/fee_quote", a.auth(a.feeQuote))
+	mux.HandleFunc("/api/tickets", a.auth(a.tickets))
+	return mux
+}
+
+func (a *API) getUserData(w http.ResponseWriter, r *http.Request, uid string) {
+	o, err := a.bank.Overview(uid)
+	if err != nil {
+		log.Println("❌ Ошибка данных пользователя:", err)
+		http.Error(w, "DB error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(o)
+}
+
+func (a *API) history(w http.ResponseWriter, r *http.Request, uid string) {
+	q := r.URL.Query()
+	f := storage.HistoryFilter{Kind: q.Get("type")}
+	var err error
+	if v := q.Get("from"); v != "" {
+		if f.From, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "Bad from", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if f.To, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "Bad to", http.StatusBadRequest)
+			return
+		}
+		f.To = f.To.AddDate(0, 0, 1)
+	}
+	if v := q.Get("before"); v != "" {
+		if f.Before, err = strconv.ParseInt(v, 10, 64); err != nil {
+			http.Error(w, "Bad before", http.StatusBadRequest)
+			return
+		}
+	}
+	f.Limit = 20
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Bad limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	items, err := a.bank.History(uid, f)
+	if err != nil {
+		log.Println("❌ Ошибка истории:", err)
+		http.Error(w, "DB error", http.StatusInternalServerError)
+		return
+	}
+	var next int64
+	if len(items) > 0 && len(items) == f.Limit {
+		next = items[len(items)-1].ID
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":       items,
+		"next_before": next,
+	})
+}
+
+func (a *API) getUsers(w http.ResponseWriter, r *http.Request, _ string) {
+	users, err := a.bank.Users()
+	if err != nil {
+		log.Println("❌ Ошибка списка игроков:", err)
+	}
+	json.NewEncoder(w).Encode(users)
+}
+
+func (a *API) getMarket(w http.ResponseWriter, r *http.Request, uid string) {
+	market, err := a.bank.Market(uid)
+	if err != nil {
+		log.Println("❌ Ошибка рынка:", err)
+	}
+	json.NewEncoder(w).Encode(market)
+}
+
+// getListings — открытые объявления вторичного рынка.
+func (a *API) getListings(w http.ResponseWriter, r *http.Request, uid string) {
+	listings, err := a.bank.Listings(uid)
+	if err != nil {
+		log.Println("❌ Ошибка вторичного рынка:", err)
+	}
+	if listings == nil {
+		listings = []bank.ListingView{}
+	}
+	json.NewEncoder(w).Encode(listings)
+}
+
+// feeQuote — комиссия за операцию op на сумму amount для показа до
+// подтверждения: /api/fee_quote?op=transfer&amount=10.
+func (a *API) feeQuote(w http.ResponseWriter, r *http.Request, uid string) {
+	q := r.URL.Query()
+	amount, err := money.Parse(q.Get("amount"))
+	if err == nil {
+		err = bank.CheckAmount(amount)
+	}
+	if err != nil {
+		http.Error(w, "Bad amount: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	quote, err := a.bank.QuoteFee(uid, q.Get("op"), amount)
+	if errors.Is(err, bank.ErrUnknownFeeOp) {
+		http.Error(w, "Bad op", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("❌ Ошибка расчёта комиссии:", err)
+		http.Error(w, "DB error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(quote)
+}
+
+// tickets — последние обращения игрока с перепиской.
+func (a *API) tickets(w http.ResponseWriter, r *http.Request, uid string) {
+	list, err := a.bank.UserTickets(uid, 20)
+	if err != nil {
+		log.Println("❌ Ошибка обращений пользователя:", err)
+		http.Error(w, "DB error", http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []bank.Ticket{}
+	}
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,98 @@
+package httpapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mybot/internal/bank"
+	"mybot/internal/money"
+	"mybot/internal/storage/memory"
+)
+
+const testToken = "123:abc"
+
+// signInitData собирает initData так же, как это делает Telegram.
+func signInitData(token string, vals url.Values) string {
+	pairs := make([]string, 0, len(vals))
+	for k := range vals {
+		pairs = append(pairs, k+"="+vals.Get(k))
+	}
+	sort.Strings(pairs)
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(token))
+	mac := hmac.New(sha256.New, secret.Sum(nil))
+	mac.Write([]byte(strings.Join(pairs, "\n")))
+	vals.Set("hash", hex.EncodeToString(mac.Sum(nil)))
+	return vals.Encode()
+}
+
+func initDataFor(uid int64, authDate time.Time) string {
+	return signInitData(testToken, url.Values{
+		"auth_date": {strconv.FormatInt(authDate.Unix(), 10)},
+		"user":      {`{"id":` + strconv.FormatInt(uid, 10) + `,"first_name":"A"}`},
+	})
+}
+
+func TestValidateInitData(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	good := initDataFor(42, now.Add(-time.Hour))
+
+	u, err := validateInitData(good, testToken, initDataMaxAge, now)
+	if err != nil || u.ID != 42 {
+		t.Fatalf("valid initData: %v, %v", u, err)
+	}
+	if _, err := validateInitData(good, "other:token", initDataMaxAge, now); err != errInitDataHash {
+		t.Errorf("wrong token: err = %v", err)
+	}
+	if _, err := validateInitData(strings.Replace(good, "42", "43", 1), testToken, initDataMaxAge, now); err != errInitDataHash {
+		t.Errorf("tampered user: err = %v", err)
+	}
+	if _, err := validateInitData(initDataFor(42, now.Add(-25*time.Hour)), testToken, initDataMaxAge, now); err != errInitDataExpired {
+		t.Errorf("stale: err = %v", err)
+	}
+	if _, err := validateInitData("", testToken, initDataMaxAge, now); err != errInitDataMissing {
+		t.Errorf("missing: err = %v", err)
+	}
+}
+
+func TestUserDataUsesSignedUser(t *testing.T) {
+	b := bank.New(memory.New(), "1")
+	b.Register("42", "alice", "player")
+	b.AdminDeposit("1", "42", money.FromInt(12))
+	api := New(b, testToken, "https://example.org/app/")
+	srv := api.Handler()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/api/get_user_data?tg_id=7", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("no initData: code %d", rec.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/api/get_user_data?tg_id=7", nil)
+	req.Header.Set("X-Telegram-Init-Data", initDataFor(42, time.Now()))
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("code %d: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://example.org" {
+		t.Errorf("CORS origin = %q", got)
+	}
+	var o bank.Overview
+	if err := json.NewDecoder(rec.Body).Decode(&o); err != nil {
+		t.Fatal(err)
+	}
+	if o.Balance != money.FromInt(12) {
+		t.Errorf("balance = %s, want the signed user's", o.Balance)
+	}
+}
@@ -0,0 +1,113 @@
+package httpapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// initDataMaxAge — сколько после выдачи Telegram'ом принимается initData.
+const initDataMaxAge = 24 * time.Hour
+
+var (
+	errInitDataMissing = errors.New("initData не передан")
+	errInitDataHash    = errors.New("неверная подпись initData")
+	errInitDataExpired = errors.New("initData устарел")
+	errInitDataUser    = errors.New("в initData нет пользователя")
+)
+
+// WebAppUser — поле user из initData.
+type WebAppUser struct {
+	ID        int64  `json:"id"`
+	FirstName string `json:"first_name"`
+	Username  string `json:"username"`
+}
+
+// validateInitData проверяет строку initData WebApp по алгоритму Telegram:
+// secret = HMAC_SHA256("WebAppData", botToken), hash = HMAC_SHA256(secret,
+// data_check_string), где data_check_string — отсортированные пары key=value
+// без hash, разделённые \n. Затем проверяется свежесть auth_date.
+func validateInitData(initData, botToken string, maxAge time.Duration, now time.Time) (WebAppUser, error) {
+	var u WebAppUser
+	if initData == "" {
+		return u, errInitDataMissing
+	}
+	vals, err := url.ParseQuery(initData)
+	if err != nil {
+		return u, errInitDataHash
+	}
+	hash := vals.Get("hash")
+	if hash == "" {
+		return u, errInitDataHash
+	}
+
+	pairs := make([]string, 0, len(vals))
+	for k := range vals {
+		if k != "hash" {
+			pairs = append(pairs, k+"="+vals.Get(k))
+		}
+	}
+	sort.Strings(pairs)
+
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(botToken))
+	mac := hmac.New(sha256.New, secret.Sum(nil))
+	mac.Write([]byte(strings.Join(pairs, "\n")))
+	got, err := hex.DecodeString(hash)
+	if err != nil || !hmac.Equal(got, mac.Sum(nil)) {
+		return u, errInitDataHash
+	}
+
+	authDate, err := strconv.ParseInt(vals.Get("auth_date"), 10, 64)
+	if err != nil || now.Sub(time.Unix(authDate, 0)) > maxAge {
+		return u, errInitDataExpired
+	}
+
+	if err := json.Unmarshal([]byte(vals.Get("user")), &u); err != nil || u.ID == 0 {
+		return u, errInitDataUser
+	}
+	return u, nil
+}
+
+// originOf — origin фронтенда WebApp, которому разрешён CORS.
+func originOf(webAppURL string) string {
+	u, err := url.Parse(webAppURL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// auth пропускает к обработчику только запросы с валидным initData
+// (заголовок X-Telegram-Init-Data или параметр init_data) и передаёт ему
+// ID пользователя из подписанных данных.
+func (a *API) auth(next func(w http.ResponseWriter, r *http.Request, uid string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", a.origin)
+		w.Header().Set("Access-Control-Allow-Headers", "X-Telegram-Init-Data")
+		w.Header().Set("Vary", "Origin")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		initData := r.Header.Get("X-Telegram-Init-Data")
+		if initData == "" {
+			initData = r.URL.Query().Get("init_data")
+		}
+		u, err := validateInitData(initData, a.botToken, initDataMaxAge, a.now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next(w, r, strconv.FormatInt(u.ID, 10))
+	}
+}
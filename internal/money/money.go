@@ -0,0 +1,182 @@
+// Package money — денежный тип банка: суммы GOLD в целых сотых.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money — сумма в GOLD в сотых долях. Все денежные расчёты идут в целых
+// числах; в БД суммы хранятся как NUMERIC(20,2), в JSON — как число с двумя
+// знаками после точки.
+type Money int64
+
+// scale — количество сотых в одном GOLD.
+const scale = 100
+
+var errFormat = errors.New("сумма должна быть числом с не более чем двумя знаками после запятой")
+
+// Parse разбирает сумму вида "12", "12.5", "12,50" или "-3.07".
+// Больше двух знаков после запятой — ошибка, а не молчаливое округление.
+func Parse(s string) (Money, error) {
+	s = strings.TrimSpace(strings.Replace(s, ",", ".", 1))
+	neg := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || len(frac) > 2 || len(whole) > 15 {
+		return 0, errFormat
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return 0, errFormat
+		}
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	v, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, errFormat
+	}
+	if neg {
+		v = -v
+	}
+	return Money(v), nil
+}
+
+// FromInt — сумма в целых GOLD.
+func FromInt(gold int64) Money {
+	return Money(gold * scale)
+}
+
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/scale, v%scale)
+}
+
+// Signed — String со знаком "+" у положительных сумм, для выписок.
+func (m Money) Signed() string {
+	if m > 0 {
+		return "+" + m.String()
+	}
+	return m.String()
+}
+
+// Grow начисляет сложный процент: m * (1 + rate/100)^periods. Расчёт идёт в
+// big.Float, результат округляется до сотых по правилу half away from zero —
+// один раз, в конце, а не на каждом периоде.
+func (m Money) Grow(ratePercent float64, periods int) Money {
+	if periods <= 0 {
+		return m
+	}
+	factor := new(big.Float).SetPrec(256).SetFloat64(ratePercent)
+	factor.Quo(factor, big.NewFloat(100))
+	factor.Add(factor, big.NewFloat(1))
+
+	pow := new(big.Float).SetPrec(256).SetInt64(1)
+	for n := periods; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			pow.Mul(pow, factor)
+		}
+		factor.Mul(factor, factor)
+	}
+
+	v := new(big.Float).SetPrec(256).SetInt64(int64(m))
+	v.Mul(v, pow)
+	return roundCents(v)
+}
+
+// Percent — ratePercent процентов от суммы, округлённые до сотых так же,
+// как в Grow.
+func (m Money) Percent(ratePercent float64) Money {
+	v := new(big.Float).SetPrec(256).SetInt64(int64(m))
+	v.Mul(v, new(big.Float).SetPrec(256).SetFloat64(ratePercent))
+	v.Quo(v, big.NewFloat(100))
+	return roundCents(v)
+}
+
+func roundCents(v *big.Float) Money {
+	half := big.NewFloat(0.5)
+	if v.Sign() < 0 {
+		v.Sub(v, half)
+	} else {
+		v.Add(v, half)
+	}
+	i, _ := v.Int64()
+	return Money(i)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if unq, err := strconv.Unquote(s); err == nil {
+		s = unq
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case int64:
+		*m = Money(v * scale)
+	case float64:
+		*m = Money(math.Round(v * scale))
+	default:
+		return fmt.Errorf("money: неподдерживаемый тип %T", src)
+	}
+	return nil
+}
+
+func (m *Money) scanString(s string) error {
+	// NUMERIC без масштаба может прийти с лишними нулями: "12.5000".
+	if whole, frac, ok := strings.Cut(s, "."); ok && len(frac) > 2 {
+		frac = strings.TrimRight(frac, "0")
+		s = whole
+		if frac != "" {
+			s += "." + frac
+		}
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return fmt.Errorf("money: %q: %w", s, err)
+	}
+	*m = v
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
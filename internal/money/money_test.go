@@ -0,0 +1,94 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Money
+	}{
+		{"10", 1000},
+		{"10.5", 1050},
+		{"10,05", 1005},
+		{"-0.01", -1},
+		{" 7 ", 700},
+	}
+	for _, c := range cases {
+		got, err := Parse(c.in)
+		if err != nil || got != c.want {
+			t.Errorf("Parse(%q) = %v, %v; want %v", c.in, got, err, c.want)
+		}
+	}
+
+	for _, in := range []string{"", "abc", "1.001", "1e3", "."} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q): want error", in)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	if s := Money(-105).String(); s != "-1.05" {
+		t.Errorf("String = %q", s)
+	}
+	if s := Money(1000).Signed(); s != "+10.00" {
+		t.Errorf("Signed = %q", s)
+	}
+}
+
+func TestGrow(t *testing.T) {
+	cases := []struct {
+		m       Money
+		rate    float64
+		periods int
+		want    Money
+	}{
+		{FromInt(100), 1, 0, FromInt(100)},
+		{FromInt(100), 1, 1, FromInt(101)},
+		{FromInt(100), 1, 10, 11046},  // 110.4622…
+		{FromInt(100), 0.5, 2, 10100}, // 101.0025
+		{1, 50, 1, 2},                 // 0.015 → 0.02, half away from zero
+	}
+	for _, c := range cases {
+		if got := c.m.Grow(c.rate, c.periods); got != c.want {
+			t.Errorf("%v.Grow(%v, %d) = %v, want %v", c.m, c.rate, c.periods, got, c.want)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	cases := []struct {
+		m    Money
+		rate float64
+		want Money
+	}{
+		{FromInt(100), 1, FromInt(1)},
+		{FromInt(100), 12.5, 1250},
+		{333, 10, 33}, // 0.333 → 0.33
+		{5, 10, 1},    // 0.005 → 0.01
+		{-5, 10, -1},  // симметрично для отрицательных
+		{FromInt(7), 0, 0},
+	}
+	for _, c := range cases {
+		if got := c.m.Percent(c.rate); got != c.want {
+			t.Errorf("%v.Percent(%v) = %v, want %v", c.m, c.rate, got, c.want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct{ A Money }
+	if err := json.Unmarshal([]byte(`{"A": 12.3}`), &v); err != nil || v.A != 1230 {
+		t.Fatalf("unmarshal = %v, %v", v.A, err)
+	}
+	if err := json.Unmarshal([]byte(`{"A": 1.234}`), &v); err == nil {
+		t.Error("unmarshal 1.234: want error")
+	}
+	b, _ := json.Marshal(v)
+	if string(b) != `{"A":12.30}` {
+		t.Errorf("marshal = %s", b)
+	}
+}
@@ -0,0 +1,954 @@
+// Package memory — реализация storage.Store в памяти для тестов. Транзакции
+// сериализуются мьютексом; при ошибке состояние восстанавливается из снимка.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+type request struct {
+	storage.MoneyRequest
+	DecidedBy string
+	DecidedAt time.Time
+	TxID      int64
+}
+
+type state struct {
+	users        map[string]storage.User
+	balances     map[string]money.Money
+	transactions []storage.Transaction
+	products     []storage.Product
+	bonds        []storage.Bond
+	requests     []request
+	complaints   []storage.Complaint
+	info         string
+	admins       []storage.Admin
+	changes      []storage.ProductChange
+	listings     []storage.Listing
+	settings     map[string]storage.Setting
+	transfers    []storage.PendingTransfer
+	limits       []storage.Limit
+	messages     []storage.ComplaintMessage
+	attachments  []storage.Attachment
+	broadcasts   []storage.Broadcast
+	schedules    []storage.Schedule
+	deliveries   []storage.Delivery
+
+	nextTx, nextProduct, nextBond, nextRequest, nextChange, nextListing, nextTransfer, nextComplaint, nextMessage, nextAttachment, nextBroadcast, nextSchedule int64
+}
+
+func (s *state) clone() *state {
+	c := *s
+	c.users = make(map[string]storage.User, len(s.users))
+	for k, v := range s.users {
+		c.users[k] = v
+	}
+	c.balances = make(map[string]money.Money, len(s.balances))
+	for k, v := range s.balances {
+		c.balances[k] = v
+	}
+	c.transactions = append([]storage.Transaction(nil), s.transactions...)
+	c.products = append([]storage.Product(nil), s.products...)
+	c.bonds = append([]storage.Bond(nil), s.bonds...)
+	c.requests = append([]request(nil), s.requests...)
+	c.complaints = append([]storage.Complaint(nil), s.complaints...)
+	c.admins = append([]storage.Admin(nil), s.admins...)
+	c.changes = append([]storage.ProductChange(nil), s.changes...)
+	c.listings = append([]storage.Listing(nil), s.listings...)
+	c.transfers = append([]storage.PendingTransfer(nil), s.transfers...)
+	c.limits = append([]storage.Limit(nil), s.limits...)
+	c.messages = append([]storage.ComplaintMessage(nil), s.messages...)
+	c.attachments = append([]storage.Attachment(nil), s.attachments...)
+	c.broadcasts = append([]storage.Broadcast(nil), s.broadcasts...)
+	c.schedules = append([]storage.Schedule(nil), s.schedules...)
+	c.deliveries = append([]storage.Delivery(nil), s.deliveries...)
+	c.settings = make(map[string]storage.Setting, len(s.settings))
+	for k, v := range s.settings {
+		c.settings[k] = v
+	}
+	return &c
+}
+
+type Store struct {
+	mu chan struct{}
+	st *state
+}
+
+func New() *Store {
+	return &Store{
+		mu: make(chan struct{}, 1),
+		st: &state{users: map[string]storage.User{}, balances: map[string]money.Money{}},
+	}
+}
+
+func (s *Store) Tx(ctx context.Context, fn func(tx storage.Tx) error) error {
+	select {
+	case s.mu <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.mu }()
+
+	work := s.st.clone()
+	if err := fn(&memTx{work}); err != nil {
+		return err
+	}
+	s.st = work
+	return nil
+}
+
+type memTx struct {
+	*state
+}
+
+func (t *memTx) User(id string) (storage.User, error) {
+	u, ok := t.users[id]
+	if !ok {
+		return storage.User{ID: id}, storage.ErrNotFound
+	}
+	return u, nil
+}
+
+func (t *memTx) UpsertUser(u storage.User) error {
+	if old, ok := t.users[u.ID]; ok {
+		u.Role, u.Banned, u.BlockedBot, u.LastSeenAt = old.Role, old.Banned, old.BlockedBot, old.LastSeenAt
+	}
+	t.users[u.ID] = u
+	return nil
+}
+
+func (t *memTx) Users(includeBanned bool) ([]storage.User, error) {
+	var res []storage.User
+	for _, u := range t.users {
+		if includeBanned || !u.Banned {
+			res = append(res, u)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Nick < res[j].Nick })
+	return res, nil
+}
+
+func (t *memTx) UsersByNick(nick string) ([]storage.User, error) {
+	var res []storage.User
+	for _, u := range t.users {
+		if strings.EqualFold(u.Nick, nick) {
+			res = append(res, u)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+func (t *memTx) SetBanned(id string, banned bool) (bool, error) {
+	u, ok := t.users[id]
+	if !ok {
+		return false, nil
+	}
+	u.Banned = banned
+	t.users[id] = u
+	return true, nil
+}
+
+func (t *memTx) SetBlockedBot(id string, blocked bool) error {
+	if u, ok := t.users[id]; ok {
+		u.BlockedBot = blocked
+		t.users[id] = u
+	}
+	return nil
+}
+
+func (t *memTx) TouchUser(id string, at, stale time.Time) error {
+	if u, ok := t.users[id]; ok && (u.LastSeenAt.Before(stale) || u.BlockedBot) {
+		u.LastSeenAt, u.BlockedBot = at, false
+		t.users[id] = u
+	}
+	return nil
+}
+
+func (t *memTx) Balance(id string) (money.Money, error) {
+	return t.balances[id], nil
+}
+
+func (t *memTx) LockBalances(ids ...string) (map[string]money.Money, error) {
+	res := map[string]money.Money{}
+	for _, id := range ids {
+		if storage.IsSystemAccount(id) {
+			continue
+		}
+		if _, ok := t.balances[id]; !ok {
+			t.balances[id] = 0
+		}
+		res[id] = t.balances[id]
+	}
+	return res, nil
+}
+
+func (t *memTx) AddBalance(id string, delta money.Money) error {
+	t.balances[id] += delta
+	return nil
+}
+
+func (t *memTx) Balances() ([]storage.AccountBalance, error) {
+	var res []storage.AccountBalance
+	for id, a := range t.balances {
+		res = append(res, storage.AccountBalance{UserID: id, Nick: t.users[id].Nick, Amount: a})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Nick < res[j].Nick })
+	return res, nil
+}
+
+// entryID — ID проводки: номер транзакции и позиция в ней, чтобы курсор
+// истории сортировался так же, как BIGSERIAL в Postgres.
+func entryID(txID int64, i int) int64 {
+	return txID*100 + int64(i)
+}
+
+func (t *memTx) InsertTransaction(tr *storage.Transaction) error {
+	t.nextTx++
+	tr.ID = t.nextTx
+	c := *tr
+	c.Entries = append([]storage.Entry(nil), tr.Entries...)
+	t.transactions = append(t.transactions, c)
+	return nil
+}
+
+func (t *memTx) Statement(account string, limit int) ([]storage.StatementLine, error) {
+	var res []storage.StatementLine
+	for i := len(t.transactions) - 1; i >= 0 && len(res) < limit; i-- {
+		tr := t.transactions[i]
+		for j := len(tr.Entries) - 1; j >= 0 && len(res) < limit; j-- {
+			if e := tr.Entries[j]; e.Account == account {
+				res = append(res, storage.StatementLine{TxID: tr.ID, Kind: tr.Kind, Memo: tr.Memo, Amount: e.Amount, CreatedAt: tr.CreatedAt})
+			}
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) LedgerTotals() (map[string]money.Money, error) {
+	res := map[string]money.Money{}
+	for _, tr := range t.transactions {
+		for _, e := range tr.Entries {
+			if !storage.IsSystemAccount(e.Account) {
+				res[e.Account] += e.Amount
+			}
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) AccountTotal(account string) (money.Money, error) {
+	var total money.Money
+	for _, tr := range t.transactions {
+		for _, e := range tr.Entries {
+			if e.Account == account {
+				total += e.Amount
+			}
+		}
+	}
+	return total, nil
+}
+
+func (t *memTx) Outflow(uid string, since time.Time) (money.Money, error) {
+	var total money.Money
+	for _, tr := range t.transactions {
+		if tr.Kind != storage.TxTransfer && tr.Kind != storage.TxWithdraw || tr.CreatedAt.Before(since) {
+			continue
+		}
+		for _, e := range tr.Entries {
+			if e.Account == uid && e.Amount < 0 {
+				total -= e.Amount
+			}
+		}
+	}
+	return total, nil
+}
+
+func (t *memTx) History(uid string, f storage.HistoryFilter) ([]storage.HistoryItem, error) {
+	var res []storage.HistoryItem
+	for i := len(t.transactions) - 1; i >= 0 && len(res) < f.Limit; i-- {
+		tr := t.transactions[i]
+		if f.Kind != "" && tr.Kind != f.Kind || tr.CreatedAt.Before(f.From) || !f.To.IsZero() && !tr.CreatedAt.Before(f.To) {
+			continue
+		}
+		for j := len(tr.Entries) - 1; j >= 0 && len(res) < f.Limit; j-- {
+			e := tr.Entries[j]
+			id := entryID(tr.ID, j)
+			if e.Account != uid || f.Before != 0 && id >= f.Before {
+				continue
+			}
+			h := storage.HistoryItem{ID: id, TxID: tr.ID, Kind: tr.Kind, Amount: e.Amount, BalanceAfter: e.BalanceAfter, Memo: tr.Memo, CreatedAt: tr.CreatedAt}
+			// Контрагент — первый другой игрок в транзакции, иначе первый системный счёт.
+			for _, o := range tr.Entries {
+				if o.Account == uid {
+					continue
+				}
+				if !storage.IsSystemAccount(o.Account) {
+					h.Counterparty = o.Account
+					break
+				}
+				if h.Counterparty == "" {
+					h.Counterparty = o.Account
+				}
+			}
+			h.CounterpartyNick = t.users[h.Counterparty].Nick
+			res = append(res, h)
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) Products(includeRetired bool) ([]storage.Product, error) {
+	var res []storage.Product
+	for _, p := range t.products {
+		if includeRetired || p.Status != storage.ProductRetired {
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) Product(id int) (storage.Product, error) {
+	for _, p := range t.products {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return storage.Product{ID: id}, storage.ErrNotFound
+}
+
+func (t *memTx) LockProduct(id int) (storage.Product, error) {
+	return t.Product(id)
+}
+
+func (t *memTx) AddIssued(id int, amount money.Money) error {
+	for i := range t.products {
+		if t.products[i].ID == id {
+			t.products[i].Issued += amount
+		}
+	}
+	return nil
+}
+
+func (t *memTx) Holdings(uid string, productID int) (money.Money, error) {
+	var sum money.Money
+	for _, b := range t.bonds {
+		if b.UserID == uid && b.ProductID == productID {
+			sum += b.Amount
+		}
+	}
+	return sum, nil
+}
+
+func (t *memTx) UpdateProduct(p storage.Product) error {
+	for i := range t.products {
+		if t.products[i].ID == p.ID {
+			p.Issued = t.products[i].Issued
+			t.products[i] = p
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+func (t *memTx) InsertProductChange(c *storage.ProductChange) error {
+	t.nextChange++
+	c.ID = t.nextChange
+	t.changes = append(t.changes, *c)
+	return nil
+}
+
+func (t *memTx) ProductChanges(productID, limit int) ([]storage.ProductChange, error) {
+	var res []storage.ProductChange
+	for i := len(t.changes) - 1; i >= 0 && len(res) < limit; i-- {
+		if t.changes[i].ProductID == productID {
+			res = append(res, t.changes[i])
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) CreateProduct(p *storage.Product) error {
+	t.nextProduct++
+	p.ID = int(t.nextProduct)
+	t.products = append(t.products, *p)
+	return nil
+}
+
+func (t *memTx) Bonds(uid string) ([]storage.Bond, error) {
+	var res []storage.Bond
+	for _, b := range t.bonds {
+		if b.UserID == uid {
+			res = append(res, b)
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) AllBonds() ([]storage.Bond, error) {
+	var res []storage.Bond
+	for i := len(t.bonds) - 1; i >= 0; i-- {
+		res = append(res, t.bonds[i])
+	}
+	return res, nil
+}
+
+func (t *memTx) bond(id int) int {
+	for i, b := range t.bonds {
+		if b.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *memTx) LockBond(id int, uid string) (storage.Bond, error) {
+	i := t.bond(id)
+	if i < 0 || t.bonds[i].UserID != uid {
+		return storage.Bond{}, storage.ErrNotFound
+	}
+	return t.bonds[i], nil
+}
+
+func (t *memTx) InsertBond(b *storage.Bond) error {
+	t.nextBond++
+	b.ID = int(t.nextBond)
+	t.bonds = append(t.bonds, *b)
+	return nil
+}
+
+func (t *memTx) DeleteBond(id int) error {
+	if i := t.bond(id); i >= 0 {
+		t.bonds = append(t.bonds[:i:i], t.bonds[i+1:]...)
+	}
+	return nil
+}
+
+func (t *memTx) SetBondLock(id int, canWithdraw bool) (bool, error) {
+	i := t.bond(id)
+	if i < 0 {
+		return false, nil
+	}
+	t.bonds[i].CanWithdraw = canWithdraw
+	return true, nil
+}
+
+func (t *memTx) MaturedBonds(at time.Time) ([]storage.Bond, error) {
+	var res []storage.Bond
+	for _, b := range t.bonds {
+		if !b.MaturesAt.IsZero() && !b.MaturesAt.After(at) && (!b.CanWithdraw || b.AutoRedeem) {
+			res = append(res, b)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].MaturesAt.Before(res[j].MaturesAt) })
+	return res, nil
+}
+
+func (t *memTx) CouponBonds() ([]storage.Bond, error) {
+	var res []storage.Bond
+	for _, b := range t.bonds {
+		if b.InterestModel == storage.InterestCoupon {
+			res = append(res, b)
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) UpdateBondPosition(b storage.Bond) error {
+	i := t.bond(b.ID)
+	if i < 0 {
+		return storage.ErrNotFound
+	}
+	t.bonds[i].Amount, t.bonds[i].Accrued, t.bonds[i].AccruedSince, t.bonds[i].CouponsPaid = b.Amount, b.Accrued, b.AccruedSince, b.CouponsPaid
+	return nil
+}
+
+func (t *memTx) TransferBond(id int, to string) error {
+	if i := t.bond(id); i >= 0 {
+		t.bonds[i].UserID = to
+	}
+	return nil
+}
+
+func (t *memTx) listing(id int64) int {
+	for i, l := range t.listings {
+		if l.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *memTx) CreateListing(l *storage.Listing) error {
+	if _, err := t.OpenListing(l.BondID); err == nil {
+		return fmt.Errorf("вклад #%d уже выставлен на продажу", l.BondID)
+	}
+	t.nextListing++
+	l.ID = t.nextListing
+	t.listings = append(t.listings, *l)
+	return nil
+}
+
+func (t *memTx) Listing(id int64) (storage.Listing, error) {
+	i := t.listing(id)
+	if i < 0 {
+		return storage.Listing{}, storage.ErrNotFound
+	}
+	return t.listings[i], nil
+}
+
+func (t *memTx) LockListing(id int64) (storage.Listing, error) {
+	return t.Listing(id)
+}
+
+func (t *memTx) OpenListing(bondID int) (storage.Listing, error) {
+	for _, l := range t.listings {
+		if l.BondID == bondID && l.Status == storage.ListingOpen {
+			return l, nil
+		}
+	}
+	return storage.Listing{}, storage.ErrNotFound
+}
+
+func (t *memTx) OpenListings() ([]storage.Listing, error) {
+	var res []storage.Listing
+	for _, l := range t.listings {
+		if l.Status == storage.ListingOpen {
+			res = append(res, l)
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) CloseListing(id int64, status, buyer string, at time.Time) error {
+	i := t.listing(id)
+	if i < 0 {
+		return storage.ErrNotFound
+	}
+	if t.listings[i].Status != storage.ListingOpen {
+		return storage.ErrListingClosed
+	}
+	t.listings[i].Status, t.listings[i].BuyerID, t.listings[i].ClosedAt = status, buyer, at
+	return nil
+}
+
+func (t *memTx) request(id int64) int {
+	for i, r := range t.requests {
+		if r.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *memTx) CreatePendingTransfer(p *storage.PendingTransfer) error {
+	t.nextTransfer++
+	p.ID = t.nextTransfer
+	t.transfers = append(t.transfers, *p)
+	return nil
+}
+
+func (t *memTx) ClosePendingTransfer(id int64, uid, from, to string, at time.Time) (storage.PendingTransfer, error) {
+	for i := range t.transfers {
+		p := &t.transfers[i]
+		if p.ID != id || uid != "" && p.FromID != uid {
+			continue
+		}
+		if p.Status != from {
+			return *p, storage.ErrTransferClosed
+		}
+		p.Status, p.ClosedAt = to, at
+		return *p, nil
+	}
+	return storage.PendingTransfer{ID: id}, storage.ErrNotFound
+}
+
+func (t *memTx) Limits(scopes ...string) ([]storage.Limit, error) {
+	var res []storage.Limit
+	for _, l := range t.limits {
+		for _, s := range scopes {
+			if l.Scope == s {
+				res = append(res, l)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) SetLimit(l storage.Limit) error {
+	for i := range t.limits {
+		if t.limits[i].Scope == l.Scope && t.limits[i].Name == l.Name {
+			t.limits[i] = l
+			return nil
+		}
+	}
+	t.limits = append(t.limits, l)
+	return nil
+}
+
+func (t *memTx) DeleteLimit(scope, name string) (bool, error) {
+	for i, l := range t.limits {
+		if l.Scope == scope && l.Name == name {
+			t.limits = append(t.limits[:i:i], t.limits[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (t *memTx) CreateRequest(r *storage.MoneyRequest) error {
+	t.nextRequest++
+	r.ID = t.nextRequest
+	t.requests = append(t.requests, request{MoneyRequest: *r})
+	return nil
+}
+
+func (t *memTx) CloseRequest(id int64, uid, to, by string, at time.Time) (storage.MoneyRequest, error) {
+	i := t.request(id)
+	if i < 0 || uid != "" && t.requests[i].UserID != uid {
+		return storage.MoneyRequest{ID: id}, storage.ErrNotFound
+	}
+	r := &t.requests[i]
+	if r.Status != storage.StatusPending {
+		return r.MoneyRequest, storage.ErrRequestClosed
+	}
+	r.Status, r.DecidedBy, r.DecidedAt = to, by, at
+	return r.MoneyRequest, nil
+}
+
+func (t *memTx) SetRequestTx(id, txID int64) error {
+	if i := t.request(id); i >= 0 {
+		t.requests[i].TxID = txID
+	}
+	return nil
+}
+
+func (t *memTx) PendingRequests(uid string) ([]storage.MoneyRequest, error) {
+	var res []storage.MoneyRequest
+	for _, r := range t.requests {
+		if r.UserID == uid && r.Status == storage.StatusPending {
+			res = append(res, r.MoneyRequest)
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) ExpireRequests(before, at time.Time) ([]storage.MoneyRequest, error) {
+	var res []storage.MoneyRequest
+	for i := range t.requests {
+		r := &t.requests[i]
+		if r.Status == storage.StatusPending && r.CreatedAt.Before(before) {
+			r.Status, r.DecidedAt = storage.StatusExpired, at
+			res = append(res, r.MoneyRequest)
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) ExpireWithoutEvidence(kind string, before, at time.Time) ([]storage.MoneyRequest, error) {
+	var res []storage.MoneyRequest
+	for i := range t.requests {
+		r := &t.requests[i]
+		if r.Kind != kind || r.Status != storage.StatusPending || !r.CreatedAt.Before(before) {
+			continue
+		}
+		if a, _ := t.Attachments(storage.AttachRequest, r.ID); len(a) > 0 {
+			continue
+		}
+		r.Status, r.DecidedAt = storage.StatusExpired, at
+		res = append(res, r.MoneyRequest)
+	}
+	return res, nil
+}
+
+func (t *memTx) AddAttachment(a *storage.Attachment) error {
+	t.nextAttachment++
+	a.ID = t.nextAttachment
+	t.attachments = append(t.attachments, *a)
+	return nil
+}
+
+func (t *memTx) Attachments(ownerKind string, ownerID int64) ([]storage.Attachment, error) {
+	var res []storage.Attachment
+	for _, a := range t.attachments {
+		if a.OwnerKind == ownerKind && a.OwnerID == ownerID {
+			res = append(res, a)
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) LastComplaintAt(uid string) (time.Time, error) {
+	var last time.Time
+	for _, c := range t.complaints {
+		if c.UserID == uid && c.CreatedAt.After(last) {
+			last = c.CreatedAt
+		}
+	}
+	return last, nil
+}
+
+func (t *memTx) InsertComplaint(c *storage.Complaint) error {
+	t.nextComplaint++
+	c.ID = t.nextComplaint
+	t.complaints = append(t.complaints, *c)
+	return nil
+}
+
+func (t *memTx) Complaint(id int64) (storage.Complaint, error) {
+	for _, c := range t.complaints {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return storage.Complaint{}, storage.ErrNotFound
+}
+
+func (t *memTx) LockComplaint(id int64) (storage.Complaint, error) {
+	return t.Complaint(id)
+}
+
+func (t *memTx) Complaints(f storage.ComplaintFilter) ([]storage.Complaint, error) {
+	var res []storage.Complaint
+	for i := len(t.complaints) - 1; i >= 0; i-- {
+		c := t.complaints[i]
+		if f.UserID != "" && c.UserID != f.UserID || f.AssignedTo != "" && c.AssignedTo != f.AssignedTo {
+			continue
+		}
+		if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, c.Status) {
+			continue
+		}
+		if f.Limit > 0 && len(res) == f.Limit {
+			break
+		}
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+func (t *memTx) UpdateComplaint(c storage.Complaint) error {
+	for i := range t.complaints {
+		if t.complaints[i].ID == c.ID {
+			t.complaints[i].Status, t.complaints[i].AssignedTo, t.complaints[i].UpdatedAt = c.Status, c.AssignedTo, c.UpdatedAt
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+func (t *memTx) AddComplaintMessage(m *storage.ComplaintMessage) error {
+	t.nextMessage++
+	m.ID = t.nextMessage
+	t.messages = append(t.messages, *m)
+	return nil
+}
+
+func (t *memTx) ComplaintMessages(id int64) ([]storage.ComplaintMessage, error) {
+	var res []storage.ComplaintMessage
+	for _, m := range t.messages {
+		if m.ComplaintID == id {
+			res = append(res, m)
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) Settings() ([]storage.Setting, error) {
+	res := make([]storage.Setting, 0, len(t.settings))
+	for _, s := range t.settings {
+		res = append(res, s)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res, nil
+}
+
+func (t *memTx) SetSetting(s storage.Setting) error {
+	if t.settings == nil {
+		t.settings = map[string]storage.Setting{}
+	}
+	t.settings[s.Key] = s
+	return nil
+}
+
+func (t *memTx) InfoLine() (string, error) {
+	return t.info, nil
+}
+
+func (t *memTx) SetInfoLine(text string) error {
+	t.info = text
+	return nil
+}
+
+func (t *memTx) admin(id string) int {
+	for i, a := range t.admins {
+		if a.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *memTx) Admin(id string) (storage.Admin, error) {
+	i := t.admin(id)
+	if i < 0 {
+		return storage.Admin{ID: id}, storage.ErrNotFound
+	}
+	return t.admins[i], nil
+}
+
+func (t *memTx) Admins() ([]storage.Admin, error) {
+	return append([]storage.Admin(nil), t.admins...), nil
+}
+
+func (t *memTx) SetAdmin(a storage.Admin) error {
+	if i := t.admin(a.ID); i >= 0 {
+		t.admins[i] = a
+		return nil
+	}
+	t.admins = append(t.admins, a)
+	return nil
+}
+
+func (t *memTx) AddAdminIfMissing(a storage.Admin) error {
+	if t.admin(a.ID) < 0 {
+		t.admins = append(t.admins, a)
+	}
+	return nil
+}
+
+func (t *memTx) RemoveAdmin(id string) (bool, error) {
+	i := t.admin(id)
+	if i < 0 {
+		return false, nil
+	}
+	t.admins = append(t.admins[:i:i], t.admins[i+1:]...)
+	return true, nil
+}
+
+func (t *memTx) CreateBroadcast(b *storage.Broadcast, recipients []string) error {
+	t.nextBroadcast++
+	b.ID = t.nextBroadcast
+	t.broadcasts = append(t.broadcasts, *b)
+	for _, uid := range recipients {
+		t.deliveries = append(t.deliveries, storage.Delivery{BroadcastID: b.ID, UserID: uid, Status: storage.DeliveryPending, UpdatedAt: b.CreatedAt})
+	}
+	return nil
+}
+
+func (t *memTx) Broadcast(id int64) (storage.Broadcast, error) {
+	for _, b := range t.broadcasts {
+		if b.ID == id {
+			return b, nil
+		}
+	}
+	return storage.Broadcast{ID: id}, storage.ErrNotFound
+}
+
+func (t *memTx) LockBroadcast(id int64) (storage.Broadcast, error) {
+	return t.Broadcast(id)
+}
+
+func (t *memTx) ActiveBroadcasts() ([]storage.Broadcast, error) {
+	var res []storage.Broadcast
+	for _, b := range t.broadcasts {
+		if b.Active() {
+			res = append(res, b)
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) UpdateBroadcast(b storage.Broadcast) error {
+	for i := range t.broadcasts {
+		if t.broadcasts[i].ID == b.ID {
+			t.broadcasts[i] = b
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+func (t *memTx) PendingDeliveries(id int64, limit int) ([]storage.Delivery, error) {
+	var res []storage.Delivery
+	for _, d := range t.deliveries {
+		if d.BroadcastID == id && d.Status == storage.DeliveryPending && len(res) < limit {
+			res = append(res, d)
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) UpdateDelivery(d storage.Delivery) error {
+	for i := range t.deliveries {
+		if t.deliveries[i].BroadcastID == d.BroadcastID && t.deliveries[i].UserID == d.UserID {
+			t.deliveries[i] = d
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+func (t *memTx) CreateSchedule(s *storage.Schedule) error {
+	t.nextSchedule++
+	s.ID = t.nextSchedule
+	t.schedules = append(t.schedules, *s)
+	return nil
+}
+
+func (t *memTx) Schedules() ([]storage.Schedule, error) {
+	res := append([]storage.Schedule(nil), t.schedules...)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].StartsAt.Before(res[j].StartsAt) })
+	return res, nil
+}
+
+func (t *memTx) DueSchedules(at time.Time) ([]storage.Schedule, error) {
+	var res []storage.Schedule
+	for _, s := range t.schedules {
+		if s.Kind == storage.ScheduleBroadcast && !s.StartsAt.After(at) {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+func (t *memTx) InfoLines(at time.Time) ([]storage.Schedule, error) {
+	var res []storage.Schedule
+	for _, s := range t.schedules {
+		if s.Kind == storage.ScheduleInfo && !s.StartsAt.After(at) && (s.EndsAt.IsZero() || at.Before(s.EndsAt)) {
+			res = append(res, s)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Priority > res[j].Priority })
+	return res, nil
+}
+
+func (t *memTx) UpdateSchedule(s storage.Schedule) error {
+	for i := range t.schedules {
+		if t.schedules[i].ID == s.ID {
+			t.schedules[i] = s
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+func (t *memTx) DeleteSchedule(id int64) (bool, error) {
+	for i, s := range t.schedules {
+		if s.ID == id {
+			t.schedules = append(t.schedules[:i:i], t.schedules[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+var _ storage.Store = (*Store)(nil)
+var _ storage.Tx = (*memTx)(nil)
@@ -0,0 +1,242 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey — ключ pg_advisory_lock: пока один экземпляр бота
+// применяет миграции, второй ждёт.
+const migrationLockKey = 5077030801
+
+// Migration — пара файлов migrations/NNNN_name.up.sql и NNNN_name.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+func loadMigrations() ([]Migration, error) {
+	files, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, f := range files {
+		base := strings.TrimPrefix(f, "migrations/")
+		num, rest, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("миграция %s: имя должно быть NNNN_name.up.sql", f)
+		}
+		v, err := strconv.Atoi(num)
+		if err != nil {
+			return nil, fmt.Errorf("миграция %s: %w", f, err)
+		}
+		body, err := migrationFiles.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[v]
+		if m == nil {
+			m = &Migration{Version: v}
+			byVersion[v] = m
+		}
+		switch {
+		case strings.HasSuffix(rest, ".up.sql"):
+			m.Name = strings.TrimSuffix(rest, ".up.sql")
+			m.Up = string(body)
+		case strings.HasSuffix(rest, ".down.sql"):
+			m.Down = string(body)
+		default:
+			return nil, fmt.Errorf("миграция %s: ожидается .up.sql или .down.sql", f)
+		}
+	}
+
+	res := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("миграция %04d: нужны оба файла, up и down", m.Version)
+		}
+		res = append(res, *m)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+// withMigrationLock выполняет fn на одном соединении под advisory lock.
+func withMigrationLock(db *sql.DB, fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INT PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP DEFAULT NOW())`); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func appliedVersions(conn *sql.Conn) (map[int]bool, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := map[int]bool{}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		res[v] = true
+	}
+	return res, rows.Err()
+}
+
+// applyMigration выполняет SQL миграции и отмечает её в schema_migrations
+// в одной транзакции.
+func applyMigration(conn *sql.Conn, m Migration, up bool) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	body, mark, args := m.Up, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", []interface{}{m.Version, m.Name}
+	if !up {
+		body, mark, args = m.Down, "DELETE FROM schema_migrations WHERE version=$1", []interface{}{m.Version}
+	}
+	if _, err := tx.ExecContext(ctx, body); err != nil {
+		return fmt.Errorf("миграция %04d_%s: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, mark, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrateUp применяет все ещё не применённые миграции по возрастанию версий.
+func MigrateUp(db *sql.DB) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	return withMigrationLock(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if applied[m.Version] {
+				continue
+			}
+			if err := applyMigration(conn, m, true); err != nil {
+				return err
+			}
+			log.Printf("🗄 Применена миграция %04d_%s", m.Version, m.Name)
+		}
+		return nil
+	})
+}
+
+// MigrateDown откатывает steps последних применённых миграций.
+func MigrateDown(db *sql.DB, steps int) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	return withMigrationLock(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if !applied[m.Version] {
+				continue
+			}
+			if err := applyMigration(conn, m, false); err != nil {
+				return err
+			}
+			log.Printf("🗄 Откачена миграция %04d_%s", m.Version, m.Name)
+			steps--
+		}
+		return nil
+	})
+}
+
+func MigrationStatus(db *sql.DB) (string, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return "", err
+	}
+	var res string
+	err = withMigrationLock(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			mark := "[ ]"
+			if applied[m.Version] {
+				mark = "[x]"
+			}
+			res += fmt.Sprintf("%s %04d_%s\n", mark, m.Version, m.Name)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// RunMigrateCommand — подкоманда `migrate [up|down [N]|status]`.
+func RunMigrateCommand(db *sql.DB, args []string) error {
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "up":
+		return MigrateUp(db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("migrate down: число шагов должно быть положительным")
+			}
+			steps = n
+		}
+		return MigrateDown(db, steps)
+	case "status":
+		s, err := MigrationStatus(db)
+		if err != nil {
+			return err
+		}
+		fmt.Print(s)
+		return nil
+	default:
+		return fmt.Errorf("неизвестная команда migrate %q: используйте up, down [N] или status", cmd)
+	}
+}
@@ -0,0 +1,18 @@
+package postgres
+
+import "testing"
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %04d: versions must go without gaps", i, m.Version)
+		}
+		if m.Name == "" {
+			t.Errorf("migration %04d has no name", m.Version)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS complaints;
+DROP TABLE IF EXISTS balances;
+DROP TABLE IF EXISTS available_bonds;
+DROP TABLE IF EXISTS bonds;
+DROP TABLE IF EXISTS info_line;
+DROP TABLE IF EXISTS users;
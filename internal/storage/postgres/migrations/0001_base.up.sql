@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS users (tg_id TEXT PRIMARY KEY, nickname TEXT, role TEXT, banned BOOLEAN DEFAULT FALSE);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned BOOLEAN DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS info_line (id INT PRIMARY KEY, text TEXT);
+
+CREATE TABLE IF NOT EXISTS bonds (id SERIAL PRIMARY KEY, user_id TEXT, name TEXT, amount FLOAT, rate FLOAT, created_at TIMESTAMP DEFAULT NOW(), can_withdraw BOOLEAN DEFAULT FALSE);
+
+CREATE TABLE IF NOT EXISTS available_bonds (id SERIAL PRIMARY KEY, name TEXT, price FLOAT, rate FLOAT);
+
+CREATE TABLE IF NOT EXISTS balances (user_id TEXT PRIMARY KEY, amount FLOAT DEFAULT 0);
+
+CREATE TABLE IF NOT EXISTS complaints (id SERIAL PRIMARY KEY, user_id TEXT, nickname TEXT, complaint TEXT, created_at TIMESTAMP DEFAULT NOW());
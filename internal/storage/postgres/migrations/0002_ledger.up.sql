@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS transactions (id BIGSERIAL PRIMARY KEY, kind TEXT NOT NULL, initiator TEXT, memo TEXT, created_at TIMESTAMP DEFAULT NOW());
+
+CREATE TABLE IF NOT EXISTS ledger_entries (id BIGSERIAL PRIMARY KEY, tx_id BIGINT NOT NULL REFERENCES transactions(id), account TEXT NOT NULL, amount FLOAT NOT NULL);
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS balance_after NUMERIC(20,2);
+CREATE INDEX IF NOT EXISTS ledger_entries_account_idx ON ledger_entries (account, tx_id);
+
+-- Входящие остатки: балансы и вклады, накопленные до появления ledger,
+-- переносятся одной транзакцией против system:external.
+DO $$
+DECLARE
+	tid BIGINT;
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM transactions WHERE kind = 'opening') THEN
+		INSERT INTO transactions (kind, memo) VALUES ('opening', 'Входящие остатки') RETURNING id INTO tid;
+		INSERT INTO ledger_entries (tx_id, account, amount, balance_after)
+			SELECT tid, user_id, amount, amount FROM balances WHERE amount <> 0;
+		INSERT INTO ledger_entries (tx_id, account, amount)
+			SELECT tid, 'system:bonds', SUM(amount) FROM bonds HAVING COALESCE(SUM(amount), 0) <> 0;
+		INSERT INTO ledger_entries (tx_id, account, amount)
+			SELECT tid, 'system:external', -COALESCE(SUM(amount), 0) FROM ledger_entries WHERE tx_id = tid;
+	END IF;
+END $$;
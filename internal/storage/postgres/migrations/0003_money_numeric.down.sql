@@ -0,0 +1,4 @@
+ALTER TABLE ledger_entries ALTER COLUMN amount TYPE FLOAT;
+ALTER TABLE available_bonds ALTER COLUMN price TYPE FLOAT;
+ALTER TABLE bonds ALTER COLUMN amount TYPE FLOAT;
+ALTER TABLE balances ALTER COLUMN amount TYPE FLOAT;
@@ -0,0 +1,5 @@
+-- Денежные колонки из FLOAT в NUMERIC(20,2); значения округляются до сотых один раз.
+ALTER TABLE balances ALTER COLUMN amount TYPE NUMERIC(20,2) USING ROUND(amount::numeric, 2);
+ALTER TABLE bonds ALTER COLUMN amount TYPE NUMERIC(20,2) USING ROUND(amount::numeric, 2);
+ALTER TABLE available_bonds ALTER COLUMN price TYPE NUMERIC(20,2) USING ROUND(price::numeric, 2);
+ALTER TABLE ledger_entries ALTER COLUMN amount TYPE NUMERIC(20,2) USING ROUND(amount::numeric, 2);
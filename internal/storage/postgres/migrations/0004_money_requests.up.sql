@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS money_requests (
+	id BIGSERIAL PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	amount NUMERIC(20,2) NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	created_at TIMESTAMP DEFAULT NOW(),
+	decided_at TIMESTAMP,
+	decided_by TEXT,
+	tx_id BIGINT REFERENCES transactions(id)
+);
+CREATE INDEX IF NOT EXISTS money_requests_user_status_idx ON money_requests (user_id, status);
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS admins (tg_id TEXT PRIMARY KEY, role TEXT NOT NULL, added_by TEXT, added_at TIMESTAMP DEFAULT NOW());
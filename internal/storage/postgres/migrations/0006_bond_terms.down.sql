@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS bonds_matures_at_idx;
+ALTER TABLE bonds DROP COLUMN IF EXISTS auto_redeem;
+ALTER TABLE bonds DROP COLUMN IF EXISTS matures_at;
+ALTER TABLE available_bonds DROP COLUMN IF EXISTS auto_redeem;
+ALTER TABLE available_bonds DROP COLUMN IF EXISTS term_days;
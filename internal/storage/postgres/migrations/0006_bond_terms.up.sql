@@ -0,0 +1,8 @@
+-- Срок облигации и дата погашения вклада. term_days = 0 — бессрочная
+-- облигация, как все продукты, созданные до появления сроков.
+ALTER TABLE available_bonds ADD COLUMN IF NOT EXISTS term_days INT NOT NULL DEFAULT 0;
+ALTER TABLE available_bonds ADD COLUMN IF NOT EXISTS auto_redeem BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE bonds ADD COLUMN IF NOT EXISTS matures_at TIMESTAMP;
+ALTER TABLE bonds ADD COLUMN IF NOT EXISTS auto_redeem BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS bonds_matures_at_idx ON bonds (matures_at) WHERE matures_at IS NOT NULL;
@@ -0,0 +1,7 @@
+ALTER TABLE bonds DROP COLUMN IF EXISTS coupons_paid;
+ALTER TABLE bonds DROP COLUMN IF EXISTS penalty_pct;
+ALTER TABLE bonds DROP COLUMN IF EXISTS early_policy;
+ALTER TABLE bonds DROP COLUMN IF EXISTS interest_model;
+ALTER TABLE available_bonds DROP COLUMN IF EXISTS penalty_pct;
+ALTER TABLE available_bonds DROP COLUMN IF EXISTS early_policy;
+ALTER TABLE available_bonds DROP COLUMN IF EXISTS interest_model;
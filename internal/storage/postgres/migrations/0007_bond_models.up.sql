@@ -0,0 +1,11 @@
+-- Модель начисления процентов и условия досрочного закрытия. Значения по
+-- умолчанию повторяют прежнее поведение: ежедневная капитализация, закрыть
+-- заблокированный вклад нельзя.
+ALTER TABLE available_bonds ADD COLUMN IF NOT EXISTS interest_model TEXT NOT NULL DEFAULT 'daily';
+ALTER TABLE available_bonds ADD COLUMN IF NOT EXISTS early_policy TEXT NOT NULL DEFAULT 'forbidden';
+ALTER TABLE available_bonds ADD COLUMN IF NOT EXISTS penalty_pct FLOAT NOT NULL DEFAULT 0;
+
+ALTER TABLE bonds ADD COLUMN IF NOT EXISTS interest_model TEXT NOT NULL DEFAULT 'daily';
+ALTER TABLE bonds ADD COLUMN IF NOT EXISTS early_policy TEXT NOT NULL DEFAULT 'forbidden';
+ALTER TABLE bonds ADD COLUMN IF NOT EXISTS penalty_pct FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE bonds ADD COLUMN IF NOT EXISTS coupons_paid INT NOT NULL DEFAULT 0;
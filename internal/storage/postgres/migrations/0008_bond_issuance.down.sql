@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS bonds_user_product_idx;
+ALTER TABLE bonds DROP COLUMN IF EXISTS product_id;
+ALTER TABLE available_bonds DROP COLUMN IF EXISTS issued;
+ALTER TABLE available_bonds DROP COLUMN IF EXISTS closes_at;
+ALTER TABLE available_bonds DROP COLUMN IF EXISTS opens_at;
+ALTER TABLE available_bonds DROP COLUMN IF EXISTS per_user_max;
+ALTER TABLE available_bonds DROP COLUMN IF EXISTS max_supply;
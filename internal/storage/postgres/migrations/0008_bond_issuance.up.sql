@@ -0,0 +1,17 @@
+-- Лимиты выпуска облигаций. 0 и NULL — без ограничения; issued — сколько
+-- всего продано, product_id связывает вклад с облигацией для лимита на игрока.
+ALTER TABLE available_bonds ADD COLUMN IF NOT EXISTS max_supply NUMERIC(20,2) NOT NULL DEFAULT 0;
+ALTER TABLE available_bonds ADD COLUMN IF NOT EXISTS per_user_max NUMERIC(20,2) NOT NULL DEFAULT 0;
+ALTER TABLE available_bonds ADD COLUMN IF NOT EXISTS opens_at TIMESTAMP;
+ALTER TABLE available_bonds ADD COLUMN IF NOT EXISTS closes_at TIMESTAMP;
+ALTER TABLE available_bonds ADD COLUMN IF NOT EXISTS issued NUMERIC(20,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE bonds ADD COLUMN IF NOT EXISTS product_id INT REFERENCES available_bonds (id);
+CREATE INDEX IF NOT EXISTS bonds_user_product_idx ON bonds (user_id, product_id);
+
+-- Старые вклады связываются с облигацией по названию (при дублях — с первой),
+-- проданный объём считается по открытым вкладам.
+UPDATE bonds SET product_id = (SELECT MIN(p.id) FROM available_bonds p WHERE p.name = bonds.name)
+	WHERE product_id IS NULL;
+UPDATE available_bonds p SET issued = COALESCE((SELECT SUM(b.amount) FROM bonds b WHERE b.product_id = p.id), 0)
+	WHERE issued = 0;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS product_changes;
+ALTER TABLE available_bonds DROP COLUMN IF EXISTS status;
@@ -0,0 +1,13 @@
+-- Состояние облигации на рынке и журнал изменений её условий.
+ALTER TABLE available_bonds ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active';
+
+CREATE TABLE IF NOT EXISTS product_changes (
+	id BIGSERIAL PRIMARY KEY,
+	product_id INT NOT NULL REFERENCES available_bonds (id),
+	admin_id TEXT NOT NULL,
+	field TEXT NOT NULL,
+	old_value TEXT,
+	new_value TEXT,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS product_changes_product_idx ON product_changes (product_id, id);
@@ -0,0 +1,20 @@
+-- Вторичный рынок вкладов и настройки банка, которые меняются из бота.
+CREATE TABLE IF NOT EXISTS bond_listings (
+	id BIGSERIAL PRIMARY KEY,
+	bond_id INT NOT NULL,
+	seller_id TEXT NOT NULL,
+	price NUMERIC(20,2) NOT NULL,
+	status TEXT NOT NULL DEFAULT 'open',
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	buyer_id TEXT,
+	closed_at TIMESTAMP
+);
+-- Один вклад — не больше одного открытого объявления.
+CREATE UNIQUE INDEX IF NOT EXISTS bond_listings_open_bond_idx ON bond_listings (bond_id) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL,
+	updated_by TEXT,
+	updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
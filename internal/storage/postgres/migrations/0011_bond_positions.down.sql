@@ -0,0 +1,2 @@
+ALTER TABLE bonds DROP COLUMN IF EXISTS accrued_since;
+ALTER TABLE bonds DROP COLUMN IF EXISTS accrued;
@@ -0,0 +1,6 @@
+-- Учёт позиции вклада для частичного снятия и пополнения: accrued —
+-- начисленные, но не выплаченные проценты, accrued_since — момент, с
+-- которого идёт начисление на amount + accrued.
+ALTER TABLE bonds ADD COLUMN IF NOT EXISTS accrued NUMERIC(20,2) NOT NULL DEFAULT 0;
+ALTER TABLE bonds ADD COLUMN IF NOT EXISTS accrued_since TIMESTAMP;
+UPDATE bonds SET accrued_since = created_at WHERE accrued_since IS NULL;
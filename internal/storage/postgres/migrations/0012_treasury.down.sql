@@ -0,0 +1,4 @@
+-- Отменяется только балансирующая транзакция переноса: остатки снова на
+-- system:interest и system:fees.
+DELETE FROM ledger_entries WHERE tx_id IN (SELECT id FROM transactions WHERE kind = 'treasury' AND initiator = 'system' AND memo = 'Перенос процентов и комиссий в казну');
+DELETE FROM transactions WHERE kind = 'treasury' AND initiator = 'system' AND memo = 'Перенос процентов и комиссий в казну';
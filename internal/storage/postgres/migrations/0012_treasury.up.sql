@@ -0,0 +1,21 @@
+-- Казна банка: остатки счетов system:interest и system:fees закрываются
+-- одной балансирующей транзакцией, старые проводки не меняются. Собранные
+-- комиссии переходят в казну. Проценты, выплаченные до казны без резерва,
+-- закрываются против system:external, чтобы казна не начинала с дефицита.
+DO $$
+DECLARE
+	tid BIGINT;
+	interest NUMERIC(20,2);
+	fees NUMERIC(20,2);
+BEGIN
+	SELECT COALESCE(SUM(amount), 0) INTO interest FROM ledger_entries WHERE account = 'system:interest';
+	SELECT COALESCE(SUM(amount), 0) INTO fees FROM ledger_entries WHERE account = 'system:fees';
+	IF interest <> 0 OR fees <> 0 THEN
+		INSERT INTO transactions (kind, initiator, memo) VALUES ('treasury', 'system', 'Перенос процентов и комиссий в казну') RETURNING id INTO tid;
+		INSERT INTO ledger_entries (tx_id, account, amount) VALUES
+			(tid, 'system:interest', -interest),
+			(tid, 'system:external', interest),
+			(tid, 'system:fees', -fees),
+			(tid, 'system:treasury', fees);
+	END IF;
+END $$;
@@ -0,0 +1 @@
+ALTER TABLE money_requests DROP COLUMN IF EXISTS fee;
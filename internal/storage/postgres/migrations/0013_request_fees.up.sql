@@ -0,0 +1,3 @@
+-- Комиссия по заявке фиксируется при её создании, чтобы игрок видел сумму
+-- до отправки, а смена тарифов не меняла уже поданные заявки.
+ALTER TABLE money_requests ADD COLUMN IF NOT EXISTS fee NUMERIC(20,2) NOT NULL DEFAULT 0;
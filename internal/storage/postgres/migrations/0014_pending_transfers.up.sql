@@ -0,0 +1,14 @@
+-- Переводы, ожидающие подтверждения отправителем. Комиссия фиксируется при
+-- создании, чтобы игрок подтверждал ту сумму, которую видел.
+CREATE TABLE IF NOT EXISTS pending_transfers (
+	id BIGSERIAL PRIMARY KEY,
+	from_id TEXT NOT NULL,
+	to_id TEXT NOT NULL,
+	amount NUMERIC(20,2) NOT NULL,
+	fee NUMERIC(20,2) NOT NULL DEFAULT 0,
+	comment TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL DEFAULT 'pending',
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	closed_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS pending_transfers_from_idx ON pending_transfers (from_id, status);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS ledger_entries_outflow_idx;
+DROP TABLE IF EXISTS user_limits;
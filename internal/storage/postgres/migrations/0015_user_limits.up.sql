@@ -0,0 +1,11 @@
+-- Ограничения операций игроков: для всех (scope = 'all'), для роли
+-- ('role:<роль>') и для отдельного игрока ('user:<ID>').
+CREATE TABLE IF NOT EXISTS user_limits (
+	scope TEXT NOT NULL,
+	name TEXT NOT NULL,
+	value NUMERIC(20,2) NOT NULL,
+	updated_by TEXT,
+	updated_at TIMESTAMP,
+	PRIMARY KEY (scope, name)
+);
+CREATE INDEX IF NOT EXISTS ledger_entries_outflow_idx ON ledger_entries (account) WHERE amount < 0;
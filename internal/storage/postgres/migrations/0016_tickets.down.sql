@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS complaint_messages;
+DROP INDEX IF EXISTS complaints_user_idx;
+DROP INDEX IF EXISTS complaints_status_idx;
+ALTER TABLE complaints DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE complaints DROP COLUMN IF EXISTS assigned_to;
+ALTER TABLE complaints DROP COLUMN IF EXISTS status;
@@ -0,0 +1,16 @@
+-- Жалобы становятся обращениями с состоянием, исполнителем и перепиской.
+ALTER TABLE complaints ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'open';
+ALTER TABLE complaints ADD COLUMN IF NOT EXISTS assigned_to TEXT NOT NULL DEFAULT '';
+ALTER TABLE complaints ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS complaints_status_idx ON complaints (status);
+CREATE INDEX IF NOT EXISTS complaints_user_idx ON complaints (user_id);
+
+CREATE TABLE IF NOT EXISTS complaint_messages (
+	id BIGSERIAL PRIMARY KEY,
+	complaint_id INT NOT NULL REFERENCES complaints(id) ON DELETE CASCADE,
+	author_id TEXT NOT NULL,
+	from_admin BOOLEAN NOT NULL DEFAULT FALSE,
+	text TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS complaint_messages_complaint_idx ON complaint_messages (complaint_id);
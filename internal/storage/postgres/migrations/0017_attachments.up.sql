@@ -0,0 +1,12 @@
+-- Скриншоты и файлы, которые игроки прикладывают к заявкам на пополнение
+-- и обращениям. Сами файлы хранит Telegram, здесь только file_id.
+CREATE TABLE IF NOT EXISTS attachments (
+	id BIGSERIAL PRIMARY KEY,
+	owner_kind TEXT NOT NULL,
+	owner_id BIGINT NOT NULL,
+	user_id TEXT NOT NULL,
+	file_id TEXT NOT NULL,
+	file_type TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS attachments_owner_idx ON attachments (owner_kind, owner_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS broadcast_deliveries;
+DROP TABLE IF EXISTS broadcasts;
+ALTER TABLE users DROP COLUMN IF EXISTS blocked_bot;
@@ -0,0 +1,30 @@
+-- Рассылки ставятся в очередь и доставляются воркером; состояние каждой
+-- доставки сохраняется, чтобы после перезапуска продолжить с того же места.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS blocked_bot BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS broadcasts (
+	id BIGSERIAL PRIMARY KEY,
+	text TEXT NOT NULL,
+	created_by TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'queued',
+	total INT NOT NULL DEFAULT 0,
+	sent INT NOT NULL DEFAULT 0,
+	failed INT NOT NULL DEFAULT 0,
+	blocked INT NOT NULL DEFAULT 0,
+	chat_id BIGINT NOT NULL DEFAULT 0,
+	message_id INT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	finished_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS broadcasts_status_idx ON broadcasts (status);
+
+CREATE TABLE IF NOT EXISTS broadcast_deliveries (
+	broadcast_id BIGINT NOT NULL REFERENCES broadcasts(id) ON DELETE CASCADE,
+	user_id TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (broadcast_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS broadcast_deliveries_pending_idx ON broadcast_deliveries (broadcast_id) WHERE status = 'pending';
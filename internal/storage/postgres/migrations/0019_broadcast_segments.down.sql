@@ -0,0 +1,6 @@
+ALTER TABLE broadcasts DROP COLUMN IF EXISTS segment;
+ALTER TABLE broadcasts DROP COLUMN IF EXISTS buttons;
+ALTER TABLE broadcasts DROP COLUMN IF EXISTS entities;
+ALTER TABLE broadcasts DROP COLUMN IF EXISTS file_type;
+ALTER TABLE broadcasts DROP COLUMN IF EXISTS file_id;
+ALTER TABLE users DROP COLUMN IF EXISTS last_seen_at;
@@ -0,0 +1,9 @@
+-- Рассылки по сегментам игроков с фото, файлами, форматированием и
+-- кнопками; перед отправкой рассылка ждёт подтверждения в статусе draft.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP;
+
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS file_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS file_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS entities TEXT NOT NULL DEFAULT '';
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS buttons TEXT NOT NULL DEFAULT '';
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS segment TEXT NOT NULL DEFAULT '';
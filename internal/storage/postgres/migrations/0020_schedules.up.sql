@@ -0,0 +1,20 @@
+-- Расписание: отложенные и повторяющиеся рассылки и информационные строки
+-- WebApp с периодом показа и приоритетом. Строка из info_line остаётся и
+-- показывается, когда в расписании нет действующих строк.
+CREATE TABLE IF NOT EXISTS schedules (
+	id BIGSERIAL PRIMARY KEY,
+	kind TEXT NOT NULL,
+	text TEXT NOT NULL DEFAULT '',
+	file_id TEXT NOT NULL DEFAULT '',
+	file_type TEXT NOT NULL DEFAULT '',
+	entities TEXT NOT NULL DEFAULT '',
+	buttons TEXT NOT NULL DEFAULT '',
+	filters TEXT NOT NULL DEFAULT '',
+	priority INT NOT NULL DEFAULT 0,
+	starts_at TIMESTAMP NOT NULL,
+	ends_at TIMESTAMP,
+	every_seconds BIGINT NOT NULL DEFAULT 0,
+	created_by TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS schedules_kind_starts_idx ON schedules (kind, starts_at);
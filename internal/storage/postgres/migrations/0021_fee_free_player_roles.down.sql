@@ -0,0 +1,5 @@
+INSERT INTO settings (key, value, updated_by, updated_at)
+SELECT 'fee_free_roles', value, updated_by, NOW() FROM settings WHERE key = 'fee_free_player_roles'
+ON CONFLICT (key) DO UPDATE SET value = concat_ws(',', NULLIF(settings.value, ''), NULLIF(EXCLUDED.value, ''));
+
+DELETE FROM settings WHERE key = 'fee_free_player_roles';
@@ -0,0 +1,15 @@
+-- Роли игроков, освобождённые от комиссий, хранятся отдельно от ролей
+-- администраторов: всё, что в fee_free_roles не роль администратора,
+-- переносится в fee_free_player_roles.
+INSERT INTO settings (key, value, updated_by, updated_at)
+SELECT 'fee_free_player_roles', string_agg(trim(r), ','), s.updated_by, NOW()
+FROM settings s, unnest(string_to_array(s.value, ',')) AS r
+WHERE s.key = 'fee_free_roles' AND trim(r) <> '' AND trim(r) NOT IN ('owner', 'finance', 'moderator', 'support')
+GROUP BY s.updated_by
+ON CONFLICT (key) DO NOTHING;
+
+UPDATE settings SET value = COALESCE((
+	SELECT string_agg(trim(r), ',') FROM unnest(string_to_array(value, ',')) AS r
+	WHERE trim(r) IN ('owner', 'finance', 'moderator', 'support')
+), '')
+WHERE key = 'fee_free_roles';
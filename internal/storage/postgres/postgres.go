@@ -0,0 +1,1062 @@
+// Package postgres — реализация storage.Store поверх PostgreSQL вместе со
+// встроенными миграциями схемы.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"mybot/internal/money"
+	"mybot/internal/storage"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Tx(ctx context.Context, fn func(tx storage.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(&pgTx{tx: tx, ctx: ctx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+type pgTx struct {
+	tx  *sql.Tx
+	ctx context.Context
+}
+
+func (t *pgTx) exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.ExecContext(t.ctx, query, args...)
+}
+
+func (t *pgTx) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.QueryContext(t.ctx, query, args...)
+}
+
+// queryRow — QueryRow с заменой sql.ErrNoRows на storage.ErrNotFound.
+func (t *pgTx) queryRow(query string, args []interface{}, dest ...interface{}) error {
+	err := t.tx.QueryRowContext(t.ctx, query, args...).Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	return err
+}
+
+func affected(res sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (t *pgTx) User(id string) (storage.User, error) {
+	u := storage.User{ID: id}
+	var seen sql.NullTime
+	err := t.queryRow("SELECT COALESCE(nickname, ''), COALESCE(role, ''), COALESCE(banned, false), blocked_bot, last_seen_at FROM users WHERE tg_id=$1", []interface{}{id}, &u.Nick, &u.Role, &u.Banned, &u.BlockedBot, &seen)
+	u.LastSeenAt = seen.Time
+	return u, err
+}
+
+func (t *pgTx) UpsertUser(u storage.User) error {
+	_, err := t.exec("INSERT INTO users (tg_id, nickname, role) VALUES ($1, $2, $3) ON CONFLICT (tg_id) DO UPDATE SET nickname = $2", u.ID, u.Nick, u.Role)
+	return err
+}
+
+func (t *pgTx) Users(includeBanned bool) ([]storage.User, error) {
+	rows, err := t.query("SELECT tg_id, COALESCE(nickname, ''), COALESCE(role, ''), COALESCE(banned, false), blocked_bot, last_seen_at FROM users WHERE $1 OR banned = false ORDER BY nickname", includeBanned)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanUsers(rows)
+}
+
+func (t *pgTx) UsersByNick(nick string) ([]storage.User, error) {
+	rows, err := t.query("SELECT tg_id, COALESCE(nickname, ''), COALESCE(role, ''), COALESCE(banned, false), blocked_bot, last_seen_at FROM users WHERE LOWER(nickname) = LOWER($1) ORDER BY tg_id", nick)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanUsers(rows)
+}
+
+func scanUsers(rows *sql.Rows) ([]storage.User, error) {
+	var res []storage.User
+	for rows.Next() {
+		var u storage.User
+		var seen sql.NullTime
+		if err := rows.Scan(&u.ID, &u.Nick, &u.Role, &u.Banned, &u.BlockedBot, &seen); err != nil {
+			return nil, err
+		}
+		u.LastSeenAt = seen.Time
+		res = append(res, u)
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) SetBanned(id string, banned bool) (bool, error) {
+	return affected(t.exec("UPDATE users SET banned = $2 WHERE tg_id = $1", id, banned))
+}
+
+func (t *pgTx) SetBlockedBot(id string, blocked bool) error {
+	_, err := t.exec("UPDATE users SET blocked_bot = $2 WHERE tg_id = $1", id, blocked)
+	return err
+}
+
+func (t *pgTx) TouchUser(id string, at, stale time.Time) error {
+	_, err := t.exec("UPDATE users SET last_seen_at = $2, blocked_bot = false WHERE tg_id = $1 AND (last_seen_at IS NULL OR last_seen_at < $3 OR blocked_bot)", id, at, stale)
+	return err
+}
+
+func (t *pgTx) Balance(id string) (money.Money, error) {
+	var a money.Money
+	err := t.queryRow("SELECT amount FROM balances WHERE user_id=$1", []interface{}{id}, &a)
+	if errors.Is(err, storage.ErrNotFound) {
+		return 0, nil
+	}
+	return a, err
+}
+
+func (t *pgTx) LockBalances(ids ...string) (map[string]money.Money, error) {
+	sorted := make([]string, 0, len(ids))
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if !seen[id] && !storage.IsSystemAccount(id) {
+			seen[id] = true
+			sorted = append(sorted, id)
+		}
+	}
+	sort.Strings(sorted)
+
+	res := make(map[string]money.Money, len(sorted))
+	for _, id := range sorted {
+		if _, err := t.exec("INSERT INTO balances (user_id, amount) VALUES ($1, 0) ON CONFLICT (user_id) DO NOTHING", id); err != nil {
+			return nil, err
+		}
+		var a money.Money
+		if err := t.queryRow("SELECT amount FROM balances WHERE user_id=$1 FOR UPDATE", []interface{}{id}, &a); err != nil {
+			return nil, err
+		}
+		res[id] = a
+	}
+	return res, nil
+}
+
+func (t *pgTx) AddBalance(id string, delta money.Money) error {
+	_, err := t.exec("INSERT INTO balances (user_id, amount) VALUES ($1, $2) ON CONFLICT (user_id) DO UPDATE SET amount = balances.amount + $2", id, delta)
+	return err
+}
+
+func (t *pgTx) Balances() ([]storage.AccountBalance, error) {
+	rows, err := t.query("SELECT b.user_id, COALESCE(u.nickname, ''), b.amount FROM balances b LEFT JOIN users u ON b.user_id = u.tg_id ORDER BY u.nickname")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.AccountBalance
+	for rows.Next() {
+		var b storage.AccountBalance
+		if err := rows.Scan(&b.UserID, &b.Nick, &b.Amount); err != nil {
+			return nil, err
+		}
+		res = append(res, b)
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) InsertTransaction(tr *storage.Transaction) error {
+	if err := t.queryRow("INSERT INTO transactions (kind, initiator, memo, created_at) VALUES ($1, $2, $3, $4) RETURNING id",
+		[]interface{}{tr.Kind, tr.Initiator, tr.Memo, tr.CreatedAt}, &tr.ID); err != nil {
+		return err
+	}
+	for _, e := range tr.Entries {
+		var after sql.Null[money.Money]
+		if e.BalanceAfter != nil {
+			after = sql.Null[money.Money]{V: *e.BalanceAfter, Valid: true}
+		}
+		if _, err := t.exec("INSERT INTO ledger_entries (tx_id, account, amount, balance_after) VALUES ($1, $2, $3, $4)", tr.ID, e.Account, e.Amount, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *pgTx) Statement(account string, limit int) ([]storage.StatementLine, error) {
+	rows, err := t.query(`SELECT t.id, t.kind, COALESCE(t.memo, ''), e.amount, t.created_at
+		FROM ledger_entries e JOIN transactions t ON t.id = e.tx_id
+		WHERE e.account = $1 ORDER BY e.id DESC LIMIT $2`, account, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []storage.StatementLine
+	for rows.Next() {
+		var l storage.StatementLine
+		if err := rows.Scan(&l.TxID, &l.Kind, &l.Memo, &l.Amount, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+func (t *pgTx) LedgerTotals() (map[string]money.Money, error) {
+	rows, err := t.query("SELECT account, SUM(amount) FROM ledger_entries WHERE account NOT LIKE 'system:%' GROUP BY account")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := map[string]money.Money{}
+	for rows.Next() {
+		var account string
+		var total money.Money
+		if err := rows.Scan(&account, &total); err != nil {
+			return nil, err
+		}
+		res[account] = total
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) AccountTotal(account string) (money.Money, error) {
+	var total money.Money
+	err := t.queryRow("SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account = $1", []interface{}{account}, &total)
+	return total, err
+}
+
+func (t *pgTx) Outflow(uid string, since time.Time) (money.Money, error) {
+	var total money.Money
+	err := t.queryRow(`SELECT COALESCE(-SUM(e.amount), 0) FROM ledger_entries e JOIN transactions t ON t.id = e.tx_id
+		WHERE e.account = $1 AND e.amount < 0 AND t.kind IN ($2, $3) AND t.created_at >= $4`,
+		[]interface{}{uid, storage.TxTransfer, storage.TxWithdraw, since}, &total)
+	return total, err
+}
+
+// History выбирает операции по счёту игрока от новых к старым. Контрагент —
+// другой участник транзакции: игрок, если он есть, иначе системный счёт.
+func (t *pgTx) History(uid string, f storage.HistoryFilter) ([]storage.HistoryItem, error) {
+	to := f.To
+	if to.IsZero() {
+		to = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	rows, err := t.query(`SELECT e.id, t.id, t.kind, COALESCE(t.memo, ''), e.amount, e.balance_after, t.created_at,
+			COALESCE(cp.account, ''), COALESCE(u.nickname, '')
+		FROM ledger_entries e
+		JOIN transactions t ON t.id = e.tx_id
+		LEFT JOIN LATERAL (
+			SELECT o.account FROM ledger_entries o
+			WHERE o.tx_id = e.tx_id AND o.account <> e.account
+			ORDER BY o.account LIKE 'system:%', o.id LIMIT 1
+		) cp ON TRUE
+		LEFT JOIN users u ON u.tg_id = cp.account
+		WHERE e.account = $1 AND ($2 = '' OR t.kind = $2) AND t.created_at >= $3 AND t.created_at < $4 AND ($5 = 0 OR e.id < $5)
+		ORDER BY e.id DESC LIMIT $6`, uid, f.Kind, f.From, to, f.Before, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.HistoryItem
+	for rows.Next() {
+		var h storage.HistoryItem
+		var after sql.Null[money.Money]
+		if err := rows.Scan(&h.ID, &h.TxID, &h.Kind, &h.Memo, &h.Amount, &after, &h.CreatedAt, &h.Counterparty, &h.CounterpartyNick); err != nil {
+			return nil, err
+		}
+		if after.Valid {
+			h.BalanceAfter = &after.V
+		}
+		res = append(res, h)
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) Products(includeRetired bool) ([]storage.Product, error) {
+	rows, err := t.query("SELECT "+productColumns+" FROM available_bonds WHERE $1 OR status <> $2 ORDER BY id", includeRetired, storage.ProductRetired)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.Product
+	for rows.Next() {
+		var s productScanner
+		if err := rows.Scan(s.fields()...); err != nil {
+			return nil, err
+		}
+		res = append(res, s.product())
+	}
+	return res, rows.Err()
+}
+
+const productColumns = "id, name, price, rate, term_days, auto_redeem, interest_model, early_policy, penalty_pct, max_supply, per_user_max, opens_at, closes_at, issued, status"
+
+// productScanner заполняет Product из строки productColumns; границы окна
+// подписки могут быть NULL.
+type productScanner struct {
+	p                 storage.Product
+	opensAt, closesAt sql.NullTime
+}
+
+func (s *productScanner) fields() []interface{} {
+	return []interface{}{&s.p.ID, &s.p.Name, &s.p.Price, &s.p.Rate, &s.p.TermDays, &s.p.AutoRedeem, &s.p.InterestModel, &s.p.EarlyPolicy, &s.p.PenaltyPct,
+		&s.p.MaxSupply, &s.p.PerUserMax, &s.opensAt, &s.closesAt, &s.p.Issued, &s.p.Status}
+}
+
+func (s *productScanner) product() storage.Product {
+	s.p.OpensAt, s.p.ClosesAt = s.opensAt.Time, s.closesAt.Time
+	return s.p
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func (t *pgTx) Product(id int) (storage.Product, error) {
+	var s productScanner
+	err := t.queryRow("SELECT "+productColumns+" FROM available_bonds WHERE id=$1", []interface{}{id}, s.fields()...)
+	return s.product(), err
+}
+
+func (t *pgTx) LockProduct(id int) (storage.Product, error) {
+	var s productScanner
+	err := t.queryRow("SELECT "+productColumns+" FROM available_bonds WHERE id=$1 FOR UPDATE", []interface{}{id}, s.fields()...)
+	return s.product(), err
+}
+
+func (t *pgTx) CreateProduct(p *storage.Product) error {
+	return t.queryRow(`INSERT INTO available_bonds (name, price, rate, term_days, auto_redeem, interest_model, early_policy, penalty_pct, max_supply, per_user_max, opens_at, closes_at, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id`,
+		[]interface{}{p.Name, p.Price, p.Rate, p.TermDays, p.AutoRedeem, p.InterestModel, p.EarlyPolicy, p.PenaltyPct,
+			p.MaxSupply, p.PerUserMax, nullTime(p.OpensAt), nullTime(p.ClosesAt), p.Status}, &p.ID)
+}
+
+func (t *pgTx) UpdateProduct(p storage.Product) error {
+	ok, err := affected(t.exec(`UPDATE available_bonds SET name = $2, price = $3, rate = $4, term_days = $5, auto_redeem = $6, interest_model = $7,
+		early_policy = $8, penalty_pct = $9, max_supply = $10, per_user_max = $11, opens_at = $12, closes_at = $13, status = $14 WHERE id = $1`,
+		p.ID, p.Name, p.Price, p.Rate, p.TermDays, p.AutoRedeem, p.InterestModel,
+		p.EarlyPolicy, p.PenaltyPct, p.MaxSupply, p.PerUserMax, nullTime(p.OpensAt), nullTime(p.ClosesAt), p.Status))
+	if err == nil && !ok {
+		return storage.ErrNotFound
+	}
+	return err
+}
+
+func (t *pgTx) InsertProductChange(c *storage.ProductChange) error {
+	return t.queryRow("INSERT INTO product_changes (product_id, admin_id, field, old_value, new_value, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		[]interface{}{c.ProductID, c.AdminID, c.Field, c.OldValue, c.NewValue, c.CreatedAt}, &c.ID)
+}
+
+func (t *pgTx) ProductChanges(productID, limit int) ([]storage.ProductChange, error) {
+	rows, err := t.query("SELECT id, product_id, admin_id, field, COALESCE(old_value, ''), COALESCE(new_value, ''), created_at FROM product_changes WHERE product_id = $1 ORDER BY id DESC LIMIT $2", productID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.ProductChange
+	for rows.Next() {
+		var c storage.ProductChange
+		if err := rows.Scan(&c.ID, &c.ProductID, &c.AdminID, &c.Field, &c.OldValue, &c.NewValue, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) AddIssued(id int, amount money.Money) error {
+	_, err := t.exec("UPDATE available_bonds SET issued = issued + $2 WHERE id = $1", id, amount)
+	return err
+}
+
+func (t *pgTx) Holdings(uid string, productID int) (money.Money, error) {
+	var sum money.Money
+	err := t.queryRow("SELECT COALESCE(SUM(amount), 0) FROM bonds WHERE user_id=$1 AND product_id=$2", []interface{}{uid, productID}, &sum)
+	return sum, err
+}
+
+const bondColumns = "id, user_id, COALESCE(name, ''), amount, rate, created_at, can_withdraw, matures_at, auto_redeem, interest_model, early_policy, penalty_pct, coupons_paid, COALESCE(product_id, 0), accrued, accrued_since"
+
+// bondScanner заполняет Bond из строки bondColumns; matures_at и
+// accrued_since могут быть NULL.
+type bondScanner struct {
+	b                       storage.Bond
+	maturesAt, accruedSince sql.NullTime
+}
+
+func (s *bondScanner) fields() []interface{} {
+	return []interface{}{&s.b.ID, &s.b.UserID, &s.b.Name, &s.b.Amount, &s.b.Rate, &s.b.CreatedAt, &s.b.CanWithdraw, &s.maturesAt, &s.b.AutoRedeem,
+		&s.b.InterestModel, &s.b.EarlyPolicy, &s.b.PenaltyPct, &s.b.CouponsPaid, &s.b.ProductID, &s.b.Accrued, &s.accruedSince}
+}
+
+func (s *bondScanner) bond() storage.Bond {
+	s.b.MaturesAt, s.b.AccruedSince = s.maturesAt.Time, s.accruedSince.Time
+	return s.b
+}
+
+func scanBonds(rows *sql.Rows) ([]storage.Bond, error) {
+	var res []storage.Bond
+	for rows.Next() {
+		var s bondScanner
+		if err := rows.Scan(s.fields()...); err != nil {
+			return nil, err
+		}
+		res = append(res, s.bond())
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) Bonds(uid string) ([]storage.Bond, error) {
+	rows, err := t.query("SELECT "+bondColumns+" FROM bonds WHERE user_id=$1 ORDER BY id", uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanBonds(rows)
+}
+
+func (t *pgTx) AllBonds() ([]storage.Bond, error) {
+	rows, err := t.query("SELECT " + bondColumns + " FROM bonds ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanBonds(rows)
+}
+
+func (t *pgTx) LockBond(id int, uid string) (storage.Bond, error) {
+	var s bondScanner
+	err := t.queryRow("SELECT "+bondColumns+" FROM bonds WHERE id=$1 AND user_id=$2 FOR UPDATE", []interface{}{id, uid}, s.fields()...)
+	return s.bond(), err
+}
+
+func (t *pgTx) InsertBond(b *storage.Bond) error {
+	productID := sql.NullInt64{Int64: int64(b.ProductID), Valid: b.ProductID != 0}
+	return t.queryRow(`INSERT INTO bonds (user_id, name, amount, rate, created_at, can_withdraw, matures_at, auto_redeem, interest_model, early_policy, penalty_pct, coupons_paid, product_id, accrued, accrued_since)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING id`,
+		[]interface{}{b.UserID, b.Name, b.Amount, b.Rate, b.CreatedAt, b.CanWithdraw, nullTime(b.MaturesAt), b.AutoRedeem, b.InterestModel, b.EarlyPolicy, b.PenaltyPct, b.CouponsPaid, productID,
+			b.Accrued, nullTime(b.AccruedSince)}, &b.ID)
+}
+
+func (t *pgTx) DeleteBond(id int) error {
+	_, err := t.exec("DELETE FROM bonds WHERE id=$1", id)
+	return err
+}
+
+func (t *pgTx) SetBondLock(id int, canWithdraw bool) (bool, error) {
+	return affected(t.exec("UPDATE bonds SET can_withdraw = $2 WHERE id = $1", id, canWithdraw))
+}
+
+func (t *pgTx) MaturedBonds(at time.Time) ([]storage.Bond, error) {
+	rows, err := t.query("SELECT "+bondColumns+" FROM bonds WHERE matures_at <= $1 AND (NOT can_withdraw OR auto_redeem) ORDER BY matures_at, id", at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanBonds(rows)
+}
+
+func (t *pgTx) CouponBonds() ([]storage.Bond, error) {
+	rows, err := t.query("SELECT "+bondColumns+" FROM bonds WHERE interest_model = $1 ORDER BY id", storage.InterestCoupon)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanBonds(rows)
+}
+
+func (t *pgTx) UpdateBondPosition(b storage.Bond) error {
+	ok, err := affected(t.exec("UPDATE bonds SET amount = $2, accrued = $3, accrued_since = $4, coupons_paid = $5 WHERE id = $1",
+		b.ID, b.Amount, b.Accrued, nullTime(b.AccruedSince), b.CouponsPaid))
+	if err == nil && !ok {
+		return storage.ErrNotFound
+	}
+	return err
+}
+
+func (t *pgTx) TransferBond(id int, to string) error {
+	_, err := t.exec("UPDATE bonds SET user_id = $2 WHERE id = $1", id, to)
+	return err
+}
+
+const listingColumns = "id, bond_id, seller_id, price, status, created_at, COALESCE(buyer_id, ''), closed_at"
+
+// listingScanner заполняет Listing из строки listingColumns; closed_at может быть NULL.
+type listingScanner struct {
+	l        storage.Listing
+	closedAt sql.NullTime
+}
+
+func (s *listingScanner) fields() []interface{} {
+	return []interface{}{&s.l.ID, &s.l.BondID, &s.l.SellerID, &s.l.Price, &s.l.Status, &s.l.CreatedAt, &s.l.BuyerID, &s.closedAt}
+}
+
+func (s *listingScanner) listing() storage.Listing {
+	s.l.ClosedAt = s.closedAt.Time
+	return s.l
+}
+
+func (t *pgTx) CreateListing(l *storage.Listing) error {
+	return t.queryRow("INSERT INTO bond_listings (bond_id, seller_id, price, status, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		[]interface{}{l.BondID, l.SellerID, l.Price, l.Status, l.CreatedAt}, &l.ID)
+}
+
+func (t *pgTx) Listing(id int64) (storage.Listing, error) {
+	var s listingScanner
+	err := t.queryRow("SELECT "+listingColumns+" FROM bond_listings WHERE id = $1", []interface{}{id}, s.fields()...)
+	return s.listing(), err
+}
+
+func (t *pgTx) LockListing(id int64) (storage.Listing, error) {
+	var s listingScanner
+	err := t.queryRow("SELECT "+listingColumns+" FROM bond_listings WHERE id = $1 FOR UPDATE", []interface{}{id}, s.fields()...)
+	return s.listing(), err
+}
+
+func (t *pgTx) OpenListing(bondID int) (storage.Listing, error) {
+	var s listingScanner
+	err := t.queryRow("SELECT "+listingColumns+" FROM bond_listings WHERE bond_id = $1 AND status = $2", []interface{}{bondID, storage.ListingOpen}, s.fields()...)
+	return s.listing(), err
+}
+
+func (t *pgTx) OpenListings() ([]storage.Listing, error) {
+	rows, err := t.query("SELECT "+listingColumns+" FROM bond_listings WHERE status = $1 ORDER BY id", storage.ListingOpen)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.Listing
+	for rows.Next() {
+		var s listingScanner
+		if err := rows.Scan(s.fields()...); err != nil {
+			return nil, err
+		}
+		res = append(res, s.listing())
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) CloseListing(id int64, status, buyer string, at time.Time) error {
+	ok, err := affected(t.exec("UPDATE bond_listings SET status = $2, buyer_id = NULLIF($3, ''), closed_at = $4 WHERE id = $1 AND status = $5",
+		id, status, buyer, at, storage.ListingOpen))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if _, err := t.LockListing(id); err != nil {
+			return err
+		}
+		return storage.ErrListingClosed
+	}
+	return nil
+}
+
+const requestColumns = "id, user_id, kind, amount, fee, status, created_at"
+
+func scanRequests(rows *sql.Rows) ([]storage.MoneyRequest, error) {
+	var res []storage.MoneyRequest
+	for rows.Next() {
+		var r storage.MoneyRequest
+		if err := rows.Scan(&r.ID, &r.UserID, &r.Kind, &r.Amount, &r.Fee, &r.Status, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) CreatePendingTransfer(p *storage.PendingTransfer) error {
+	return t.queryRow(`INSERT INTO pending_transfers (from_id, to_id, amount, fee, comment, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		[]interface{}{p.FromID, p.ToID, p.Amount, p.Fee, p.Comment, p.Status, p.CreatedAt}, &p.ID)
+}
+
+// ClosePendingTransfer закрывает перевод условным UPDATE, поэтому двойное
+// нажатие «Подтвердить» проведёт деньги только один раз.
+func (t *pgTx) ClosePendingTransfer(id int64, uid, from, to string, at time.Time) (storage.PendingTransfer, error) {
+	p := storage.PendingTransfer{ID: id}
+	var closed sql.NullTime
+	dest := []interface{}{&p.FromID, &p.ToID, &p.Amount, &p.Fee, &p.Comment, &p.Status, &p.CreatedAt, &closed}
+	err := t.queryRow(`UPDATE pending_transfers SET status=$4, closed_at=$5
+		WHERE id=$1 AND ($2 = '' OR from_id=$2) AND status=$3
+		RETURNING from_id, to_id, amount, fee, comment, status, created_at, closed_at`, []interface{}{id, uid, from, to, at}, dest...)
+	if !errors.Is(err, storage.ErrNotFound) {
+		p.ClosedAt = closed.Time
+		return p, err
+	}
+
+	err = t.queryRow("SELECT from_id, to_id, amount, fee, comment, status, created_at, closed_at FROM pending_transfers WHERE id=$1 AND ($2 = '' OR from_id=$2)", []interface{}{id, uid}, dest...)
+	if err != nil {
+		return p, err
+	}
+	p.ClosedAt = closed.Time
+	return p, storage.ErrTransferClosed
+}
+
+func (t *pgTx) Limits(scopes ...string) ([]storage.Limit, error) {
+	var res []storage.Limit
+	// Областей у игрока не больше трёх (все, роль, он сам), запрос на каждую проще массива.
+	for _, s := range scopes {
+		rows, err := t.query("SELECT scope, name, value, COALESCE(updated_by, ''), COALESCE(updated_at, NOW()) FROM user_limits WHERE scope=$1 ORDER BY name", s)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var l storage.Limit
+			if err := rows.Scan(&l.Scope, &l.Name, &l.Value, &l.UpdatedBy, &l.UpdatedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			res = append(res, l)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (t *pgTx) SetLimit(l storage.Limit) error {
+	_, err := t.exec(`INSERT INTO user_limits (scope, name, value, updated_by, updated_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (scope, name) DO UPDATE SET value = $3, updated_by = $4, updated_at = $5`, l.Scope, l.Name, l.Value, l.UpdatedBy, l.UpdatedAt)
+	return err
+}
+
+func (t *pgTx) DeleteLimit(scope, name string) (bool, error) {
+	return affected(t.exec("DELETE FROM user_limits WHERE scope=$1 AND name=$2", scope, name))
+}
+
+func (t *pgTx) CreateRequest(r *storage.MoneyRequest) error {
+	return t.queryRow("INSERT INTO money_requests (user_id, kind, amount, fee, status, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		[]interface{}{r.UserID, r.Kind, r.Amount, r.Fee, r.Status, r.CreatedAt}, &r.ID)
+}
+
+// CloseRequest выполняет переход условным UPDATE, поэтому из двух
+// одновременных решений применится только одно.
+func (t *pgTx) CloseRequest(id int64, uid, to, by string, at time.Time) (storage.MoneyRequest, error) {
+	r := storage.MoneyRequest{ID: id}
+	err := t.queryRow(`UPDATE money_requests SET status=$2, decided_at=$5, decided_by=$3
+		WHERE id=$1 AND status='pending' AND ($4 = '' OR user_id = $4)
+		RETURNING user_id, kind, amount, fee, status, created_at`, []interface{}{id, to, by, uid, at}, &r.UserID, &r.Kind, &r.Amount, &r.Fee, &r.Status, &r.CreatedAt)
+	if !errors.Is(err, storage.ErrNotFound) {
+		return r, err
+	}
+
+	err = t.queryRow("SELECT user_id, kind, amount, fee, status, created_at FROM money_requests WHERE id=$1 AND ($2 = '' OR user_id = $2)", []interface{}{id, uid}, &r.UserID, &r.Kind, &r.Amount, &r.Fee, &r.Status, &r.CreatedAt)
+	if err != nil {
+		return r, err
+	}
+	return r, storage.ErrRequestClosed
+}
+
+func (t *pgTx) SetRequestTx(id, txID int64) error {
+	_, err := t.exec("UPDATE money_requests SET tx_id=$2 WHERE id=$1", id, txID)
+	return err
+}
+
+func (t *pgTx) PendingRequests(uid string) ([]storage.MoneyRequest, error) {
+	rows, err := t.query("SELECT "+requestColumns+" FROM money_requests WHERE user_id=$1 AND status='pending' ORDER BY id", uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRequests(rows)
+}
+
+func (t *pgTx) ExpireRequests(before, at time.Time) ([]storage.MoneyRequest, error) {
+	rows, err := t.query(`UPDATE money_requests SET status='expired', decided_at=$2
+		WHERE status='pending' AND created_at < $1
+		RETURNING `+requestColumns, before, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRequests(rows)
+}
+
+func (t *pgTx) ExpireWithoutEvidence(kind string, before, at time.Time) ([]storage.MoneyRequest, error) {
+	rows, err := t.query(`UPDATE money_requests r SET status='expired', decided_at=$3
+		WHERE r.kind=$1 AND r.status='pending' AND r.created_at < $2
+			AND NOT EXISTS (SELECT 1 FROM attachments a WHERE a.owner_kind='request' AND a.owner_id=r.id)
+		RETURNING `+requestColumns, kind, before, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRequests(rows)
+}
+
+func (t *pgTx) AddAttachment(a *storage.Attachment) error {
+	return t.queryRow(`INSERT INTO attachments (owner_kind, owner_id, user_id, file_id, file_type, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		[]interface{}{a.OwnerKind, a.OwnerID, a.UserID, a.FileID, a.FileType, a.CreatedAt}, &a.ID)
+}
+
+func (t *pgTx) Attachments(ownerKind string, ownerID int64) ([]storage.Attachment, error) {
+	rows, err := t.query("SELECT id, owner_kind, owner_id, user_id, file_id, file_type, created_at FROM attachments WHERE owner_kind=$1 AND owner_id=$2 ORDER BY id", ownerKind, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.Attachment
+	for rows.Next() {
+		var a storage.Attachment
+		if err := rows.Scan(&a.ID, &a.OwnerKind, &a.OwnerID, &a.UserID, &a.FileID, &a.FileType, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) LastComplaintAt(uid string) (time.Time, error) {
+	var at sql.NullTime
+	err := t.queryRow("SELECT MAX(created_at) FROM complaints WHERE user_id=$1", []interface{}{uid}, &at)
+	return at.Time, err
+}
+
+const complaintColumns = "id, COALESCE(user_id, ''), COALESCE(nickname, ''), COALESCE(complaint, ''), status, assigned_to, created_at, COALESCE(updated_at, created_at)"
+
+// complaintScanner заполняет Complaint из строки complaintColumns.
+type complaintScanner struct{ storage.Complaint }
+
+func (c *complaintScanner) fields() []interface{} {
+	return []interface{}{&c.ID, &c.UserID, &c.Nick, &c.Text, &c.Status, &c.AssignedTo, &c.CreatedAt, &c.UpdatedAt}
+}
+
+func (t *pgTx) InsertComplaint(c *storage.Complaint) error {
+	return t.queryRow(`INSERT INTO complaints (user_id, nickname, complaint, status, assigned_to, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6) RETURNING id`,
+		[]interface{}{c.UserID, c.Nick, c.Text, c.Status, c.AssignedTo, c.CreatedAt}, &c.ID)
+}
+
+func (t *pgTx) Complaint(id int64) (storage.Complaint, error) {
+	var s complaintScanner
+	err := t.queryRow("SELECT "+complaintColumns+" FROM complaints WHERE id=$1", []interface{}{id}, s.fields()...)
+	return s.Complaint, err
+}
+
+func (t *pgTx) LockComplaint(id int64) (storage.Complaint, error) {
+	var s complaintScanner
+	err := t.queryRow("SELECT "+complaintColumns+" FROM complaints WHERE id=$1 FOR UPDATE", []interface{}{id}, s.fields()...)
+	return s.Complaint, err
+}
+
+func (t *pgTx) Complaints(f storage.ComplaintFilter) ([]storage.Complaint, error) {
+	var where []string
+	var args []interface{}
+	if f.UserID != "" {
+		args = append(args, f.UserID)
+		where = append(where, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	if f.AssignedTo != "" {
+		args = append(args, f.AssignedTo)
+		where = append(where, fmt.Sprintf("assigned_to = $%d", len(args)))
+	}
+	if len(f.Statuses) > 0 {
+		in := make([]string, len(f.Statuses))
+		for i, s := range f.Statuses {
+			args = append(args, s)
+			in[i] = fmt.Sprintf("$%d", len(args))
+		}
+		where = append(where, "status IN ("+strings.Join(in, ", ")+")")
+	}
+	q := "SELECT " + complaintColumns + " FROM complaints"
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY id DESC"
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		q += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	rows, err := t.query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.Complaint
+	for rows.Next() {
+		var s complaintScanner
+		if err := rows.Scan(s.fields()...); err != nil {
+			return nil, err
+		}
+		res = append(res, s.Complaint)
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) UpdateComplaint(c storage.Complaint) error {
+	_, err := t.exec("UPDATE complaints SET status=$2, assigned_to=$3, updated_at=$4 WHERE id=$1", c.ID, c.Status, c.AssignedTo, c.UpdatedAt)
+	return err
+}
+
+func (t *pgTx) AddComplaintMessage(m *storage.ComplaintMessage) error {
+	return t.queryRow(`INSERT INTO complaint_messages (complaint_id, author_id, from_admin, text, created_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		[]interface{}{m.ComplaintID, m.AuthorID, m.FromAdmin, m.Text, m.CreatedAt}, &m.ID)
+}
+
+func (t *pgTx) ComplaintMessages(id int64) ([]storage.ComplaintMessage, error) {
+	rows, err := t.query("SELECT id, complaint_id, author_id, from_admin, text, created_at FROM complaint_messages WHERE complaint_id=$1 ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.ComplaintMessage
+	for rows.Next() {
+		var m storage.ComplaintMessage
+		if err := rows.Scan(&m.ID, &m.ComplaintID, &m.AuthorID, &m.FromAdmin, &m.Text, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) Settings() ([]storage.Setting, error) {
+	rows, err := t.query("SELECT key, value, COALESCE(updated_by, ''), updated_at FROM settings ORDER BY key")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.Setting
+	for rows.Next() {
+		var s storage.Setting
+		if err := rows.Scan(&s.Key, &s.Value, &s.UpdatedBy, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) SetSetting(s storage.Setting) error {
+	_, err := t.exec(`INSERT INTO settings (key, value, updated_by, updated_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE SET value = $2, updated_by = $3, updated_at = $4`, s.Key, s.Value, s.UpdatedBy, s.UpdatedAt)
+	return err
+}
+
+func (t *pgTx) InfoLine() (string, error) {
+	var text string
+	err := t.queryRow("SELECT COALESCE(text, '') FROM info_line WHERE id=1", nil, &text)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", nil
+	}
+	return text, err
+}
+
+func (t *pgTx) SetInfoLine(text string) error {
+	_, err := t.exec("INSERT INTO info_line (id, text) VALUES (1, $1) ON CONFLICT (id) DO UPDATE SET text = $1", text)
+	return err
+}
+
+func (t *pgTx) Admin(id string) (storage.Admin, error) {
+	a := storage.Admin{ID: id}
+	err := t.queryRow("SELECT role, COALESCE(added_by, ''), COALESCE(added_at, NOW()) FROM admins WHERE tg_id=$1", []interface{}{id}, &a.Role, &a.AddedBy, &a.AddedAt)
+	return a, err
+}
+
+func (t *pgTx) Admins() ([]storage.Admin, error) {
+	rows, err := t.query("SELECT tg_id, role, COALESCE(added_by, ''), COALESCE(added_at, NOW()) FROM admins ORDER BY added_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.Admin
+	for rows.Next() {
+		var a storage.Admin
+		if err := rows.Scan(&a.ID, &a.Role, &a.AddedBy, &a.AddedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) SetAdmin(a storage.Admin) error {
+	_, err := t.exec("INSERT INTO admins (tg_id, role, added_by, added_at) VALUES ($1, $2, $3, $4) ON CONFLICT (tg_id) DO UPDATE SET role=$2, added_by=$3, added_at=$4", a.ID, a.Role, a.AddedBy, a.AddedAt)
+	return err
+}
+
+func (t *pgTx) AddAdminIfMissing(a storage.Admin) error {
+	_, err := t.exec("INSERT INTO admins (tg_id, role, added_by, added_at) VALUES ($1, $2, $3, $4) ON CONFLICT (tg_id) DO NOTHING", a.ID, a.Role, a.AddedBy, a.AddedAt)
+	return err
+}
+
+func (t *pgTx) RemoveAdmin(id string) (bool, error) {
+	return affected(t.exec("DELETE FROM admins WHERE tg_id=$1", id))
+}
+
+const broadcastColumns = "id, text, file_id, file_type, entities, buttons, segment, created_by, status, total, sent, failed, blocked, chat_id, message_id, created_at, finished_at"
+
+func scanBroadcast(scan func(dest ...interface{}) error) (storage.Broadcast, error) {
+	var b storage.Broadcast
+	var finished sql.NullTime
+	err := scan(&b.ID, &b.Text, &b.FileID, &b.FileType, &b.Entities, &b.Buttons, &b.Segment, &b.CreatedBy, &b.Status, &b.Total, &b.Sent, &b.Failed, &b.Blocked, &b.ChatID, &b.MessageID, &b.CreatedAt, &finished)
+	b.FinishedAt = finished.Time
+	return b, err
+}
+
+func (t *pgTx) CreateBroadcast(b *storage.Broadcast, recipients []string) error {
+	err := t.queryRow(`INSERT INTO broadcasts (text, file_id, file_type, entities, buttons, segment, created_by, status, total, chat_id, message_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`,
+		[]interface{}{b.Text, b.FileID, b.FileType, b.Entities, b.Buttons, b.Segment, b.CreatedBy, b.Status, b.Total, b.ChatID, b.MessageID, b.CreatedAt}, &b.ID)
+	if err != nil {
+		return err
+	}
+	for _, uid := range recipients {
+		if _, err := t.exec("INSERT INTO broadcast_deliveries (broadcast_id, user_id, status, updated_at) VALUES ($1, $2, $3, $4)",
+			b.ID, uid, storage.DeliveryPending, b.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *pgTx) Broadcast(id int64) (storage.Broadcast, error) {
+	return t.broadcast("SELECT "+broadcastColumns+" FROM broadcasts WHERE id=$1", id)
+}
+
+func (t *pgTx) LockBroadcast(id int64) (storage.Broadcast, error) {
+	return t.broadcast("SELECT "+broadcastColumns+" FROM broadcasts WHERE id=$1 FOR UPDATE", id)
+}
+
+func (t *pgTx) broadcast(q string, id int64) (storage.Broadcast, error) {
+	return scanBroadcast(func(dest ...interface{}) error {
+		return t.queryRow(q, []interface{}{id}, dest...)
+	})
+}
+
+func (t *pgTx) ActiveBroadcasts() ([]storage.Broadcast, error) {
+	rows, err := t.query("SELECT " + broadcastColumns + " FROM broadcasts WHERE status IN ('queued', 'running') ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.Broadcast
+	for rows.Next() {
+		b, err := scanBroadcast(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, b)
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) UpdateBroadcast(b storage.Broadcast) error {
+	_, err := t.exec(`UPDATE broadcasts SET status=$2, sent=$3, failed=$4, blocked=$5, chat_id=$6, message_id=$7, finished_at=$8 WHERE id=$1`,
+		b.ID, b.Status, b.Sent, b.Failed, b.Blocked, b.ChatID, b.MessageID, nullTime(b.FinishedAt))
+	return err
+}
+
+func (t *pgTx) PendingDeliveries(id int64, limit int) ([]storage.Delivery, error) {
+	rows, err := t.query(`SELECT broadcast_id, user_id, status, attempts, error, updated_at FROM broadcast_deliveries
+		WHERE broadcast_id=$1 AND status='pending' ORDER BY user_id LIMIT $2`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.Delivery
+	for rows.Next() {
+		var d storage.Delivery
+		if err := rows.Scan(&d.BroadcastID, &d.UserID, &d.Status, &d.Attempts, &d.Error, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) UpdateDelivery(d storage.Delivery) error {
+	_, err := t.exec("UPDATE broadcast_deliveries SET status=$3, attempts=$4, error=$5, updated_at=$6 WHERE broadcast_id=$1 AND user_id=$2",
+		d.BroadcastID, d.UserID, d.Status, d.Attempts, d.Error, d.UpdatedAt)
+	return err
+}
+
+const scheduleColumns = "id, kind, text, file_id, file_type, entities, buttons, filters, priority, starts_at, ends_at, every_seconds, created_by, created_at"
+
+func (t *pgTx) CreateSchedule(s *storage.Schedule) error {
+	return t.queryRow(`INSERT INTO schedules (kind, text, file_id, file_type, entities, buttons, filters, priority, starts_at, ends_at, every_seconds, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id`,
+		[]interface{}{s.Kind, s.Text, s.FileID, s.FileType, s.Entities, s.Buttons, s.Filters, s.Priority, s.StartsAt, nullTime(s.EndsAt), int64(s.Every / time.Second), s.CreatedBy, s.CreatedAt}, &s.ID)
+}
+
+func (t *pgTx) Schedules() ([]storage.Schedule, error) {
+	return t.schedules("SELECT " + scheduleColumns + " FROM schedules ORDER BY starts_at, id")
+}
+
+func (t *pgTx) DueSchedules(at time.Time) ([]storage.Schedule, error) {
+	return t.schedules("SELECT "+scheduleColumns+" FROM schedules WHERE kind = $1 AND starts_at <= $2 ORDER BY starts_at, id FOR UPDATE",
+		storage.ScheduleBroadcast, at)
+}
+
+func (t *pgTx) InfoLines(at time.Time) ([]storage.Schedule, error) {
+	return t.schedules("SELECT "+scheduleColumns+" FROM schedules WHERE kind = $1 AND starts_at <= $2 AND (ends_at IS NULL OR ends_at > $2) ORDER BY priority DESC, id",
+		storage.ScheduleInfo, at)
+}
+
+func (t *pgTx) schedules(q string, args ...interface{}) ([]storage.Schedule, error) {
+	rows, err := t.query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.Schedule
+	for rows.Next() {
+		var s storage.Schedule
+		var ends sql.NullTime
+		var every int64
+		if err := rows.Scan(&s.ID, &s.Kind, &s.Text, &s.FileID, &s.FileType, &s.Entities, &s.Buttons, &s.Filters, &s.Priority,
+			&s.StartsAt, &ends, &every, &s.CreatedBy, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		s.EndsAt, s.Every = ends.Time, time.Duration(every)*time.Second
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+func (t *pgTx) UpdateSchedule(s storage.Schedule) error {
+	_, err := t.exec("UPDATE schedules SET starts_at=$2, ends_at=$3 WHERE id=$1", s.ID, s.StartsAt, nullTime(s.EndsAt))
+	return err
+}
+
+func (t *pgTx) DeleteSchedule(id int64) (bool, error) {
+	return affected(t.exec("DELETE FROM schedules WHERE id=$1", id))
+}
+
+var _ storage.Store = (*Store)(nil)
+var _ storage.Tx = (*pgTx)(nil)
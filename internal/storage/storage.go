@@ -0,0 +1,662 @@
+// Package storage описывает хранилище банка: модели и интерфейс Store,
+// который реализуют Postgres (storage/postgres) и память (storage/memory).
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"mybot/internal/money"
+)
+
+var (
+	// ErrNotFound — запрошенной записи нет.
+	ErrNotFound = errors.New("не найдено")
+	// ErrRequestClosed — заявка уже не в состоянии pending (её обработал другой
+	// администратор, отменил пользователь или она истекла).
+	ErrRequestClosed = errors.New("заявка уже обработана")
+	// ErrListingClosed — объявление уже продано или снято.
+	ErrListingClosed = errors.New("объявление уже закрыто")
+	// ErrTransferClosed — перевод уже подтверждён, отменён или истёк.
+	ErrTransferClosed = errors.New("перевод уже обработан")
+)
+
+// Системные счета ledger. Всё, что не принадлежит игроку, живёт на счетах
+// с префиксом "system:", балансы игроков — на счетах, равных их tg_id.
+const (
+	AccountExternal = "system:external" // деньги, которыми администрация пополняет казну извне или изымает из неё
+	AccountBonds    = "system:bonds"    // тело открытых вкладов
+	// AccountTreasury — собственные средства банка: платит проценты по
+	// вкладам и пополнения игрокам, получает выводы, комиссии и удержания
+	// при досрочном закрытии.
+	AccountTreasury = "system:treasury"
+	// AccountInterest и AccountFees — счета процентов и комиссий до появления
+	// казны. Их остатки закрыты переносом в казну, новых проводок на них нет,
+	// но старые остаются в истории.
+	AccountInterest = "system:interest"
+	AccountFees     = "system:fees"
+)
+
+// Виды транзакций.
+const (
+	TxOpening      = "opening"
+	TxTransfer     = "transfer"
+	TxBuyBond      = "buy_bond"
+	TxSellBond     = "sell_bond"
+	TxWithdraw     = "withdraw"
+	TxDeposit      = "deposit"
+	TxAdminDeposit = "admin_deposit"
+	TxCoupon       = "coupon"
+	TxBondTrade    = "bond_trade"
+	TxBondPartial  = "bond_partial"
+	TxBondTopUp    = "bond_topup"
+	TxTreasury     = "treasury"
+)
+
+// Состояния объявления о продаже вклада на вторичном рынке.
+const (
+	ListingOpen      = "open"
+	ListingSold      = "sold"
+	ListingCancelled = "cancelled"
+)
+
+// Состояния облигации на рынке. Приостановленная видна, но не продаётся;
+// снятая с рынка скрыта. Открытые вклады живут по своим условиям в любом случае.
+const (
+	ProductActive  = "active"
+	ProductPaused  = "paused"
+	ProductRetired = "retired"
+)
+
+// Модели начисления процентов по облигации. Rate — процент за период:
+// день у daily и simple, 30 дней у monthly и coupon.
+const (
+	InterestDaily   = "daily"   // сложный процент, капитализация каждый день
+	InterestSimple  = "simple"  // простой процент за каждый полный день
+	InterestMonthly = "monthly" // сложный процент, капитализация раз в 30 дней
+	InterestCoupon  = "coupon"  // фиксированный купон раз в 30 дней, выплачивается на баланс
+)
+
+// Условия досрочного закрытия заблокированного вклада.
+const (
+	EarlyForbidden = "forbidden" // только после разблокировки или погашения
+	EarlyForfeit   = "forfeit"   // возвращается тело, невыплаченные проценты сгорают
+	EarlyPenalty   = "penalty"   // текущая стоимость за вычетом PenaltyPct процентов
+)
+
+// Виды заявок на движение денег через администрацию.
+const (
+	RequestWithdraw = "withdraw"
+	RequestDeposit  = "deposit"
+)
+
+// Состояния заявки. Из pending заявка переходит ровно в одно из конечных
+// состояний и дальше не меняется.
+const (
+	StatusPending   = "pending"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusExpired   = "expired"
+	StatusCancelled = "cancelled"
+	// StatusReview — перевод превысил лимит и ждёт решения администрации.
+	StatusReview = "review"
+)
+
+func IsSystemAccount(account string) bool {
+	return strings.HasPrefix(account, "system:")
+}
+
+type User struct {
+	ID     string `json:"id"`
+	Nick   string `json:"nick"`
+	Role   string `json:"-"`
+	Banned bool   `json:"-"`
+	// BlockedBot — игрок заблокировал бота; рассылки его пропускают.
+	BlockedBot bool `json:"-"`
+	// LastSeenAt — когда игрок последний раз писал боту или нажимал кнопки;
+	// нулевое, если ещё не писал.
+	LastSeenAt time.Time `json:"-"`
+}
+
+// Product — облигация, выставленная на рынок (available_bonds).
+type Product struct {
+	ID    int         `json:"id"`
+	Name  string      `json:"name"`
+	Price money.Money `json:"price"`
+	Rate  float64     `json:"rate"`
+	// TermDays — срок вклада в днях; 0 — бессрочный, проценты идут, пока
+	// администратор не разблокирует вклад.
+	TermDays int `json:"term_days"`
+	// AutoRedeem — в срок погашения вклад закрывается сам и деньги
+	// зачисляются на баланс.
+	AutoRedeem bool `json:"auto_redeem"`
+	// InterestModel — одна из Interest*; пустая считается InterestDaily.
+	InterestModel string `json:"interest_model"`
+	// EarlyPolicy — одна из Early*; пустая считается EarlyForbidden.
+	EarlyPolicy string  `json:"early_policy"`
+	PenaltyPct  float64 `json:"penalty_pct"`
+	// Ограничения выпуска; нулевые значения — без ограничения. MaxSupply
+	// — сколько всего можно продать, PerUserMax — сколько может держать
+	// один игрок, OpensAt/ClosesAt — окно подписки.
+	MaxSupply  money.Money `json:"max_supply"`
+	PerUserMax money.Money `json:"per_user_max"`
+	OpensAt    time.Time   `json:"-"`
+	ClosesAt   time.Time   `json:"-"`
+	// Issued — сколько всего продано по облигации.
+	Issued money.Money `json:"issued"`
+	Status string      `json:"status"`
+}
+
+// ProductChange — запись журнала изменений облигации: кто, когда и какое
+// поле поменял.
+type ProductChange struct {
+	ID        int64
+	ProductID int
+	AdminID   string
+	Field     string
+	OldValue  string
+	NewValue  string
+	CreatedAt time.Time
+}
+
+// Bond — вклад игрока (bonds).
+type Bond struct {
+	ID int `json:"id"`
+	// ProductID — облигация, по которой открыт вклад; 0 у старых вкладов,
+	// открытых до появления связи.
+	ProductID   int         `json:"product_id"`
+	UserID      string      `json:"-"`
+	Name        string      `json:"name"`
+	Amount      money.Money `json:"amount"`
+	Rate        float64     `json:"rate"`
+	CreatedAt   time.Time   `json:"-"`
+	CanWithdraw bool        `json:"can_withdraw"`
+	// MaturesAt — дата погашения; нулевая у бессрочных вкладов.
+	MaturesAt  time.Time `json:"-"`
+	AutoRedeem bool      `json:"auto_redeem"`
+	// Условия продукта на момент покупки; последующие изменения продукта
+	// на открытый вклад не влияют.
+	InterestModel string  `json:"interest_model"`
+	EarlyPolicy   string  `json:"early_policy"`
+	PenaltyPct    float64 `json:"penalty_pct"`
+	// Accrued — проценты, начисленные до AccruedSince, но ещё не выплаченные.
+	// Проценты за периоды после AccruedSince считаются от Amount (тела) и
+	// Accrued; частичное снятие и пополнение фиксируют их в Accrued.
+	Accrued      money.Money `json:"accrued"`
+	AccruedSince time.Time   `json:"-"`
+	// CouponsPaid — сколько купонов с AccruedSince уже выплачено на баланс
+	// (InterestCoupon).
+	CouponsPaid int `json:"coupons_paid"`
+}
+
+// Listing — объявление о продаже вклада другому игроку (bond_listings).
+type Listing struct {
+	ID        int64       `json:"id"`
+	BondID    int         `json:"bond_id"`
+	SellerID  string      `json:"-"`
+	Price     money.Money `json:"price"`
+	Status    string      `json:"status"`
+	CreatedAt time.Time   `json:"-"`
+	BuyerID   string      `json:"-"`
+	ClosedAt  time.Time   `json:"-"`
+}
+
+// PendingTransfer — перевод, ожидающий подтверждения отправителем
+// (pending_transfers). Состояния — те же, что у заявок: подтверждённый
+// перевод переходит в StatusApproved.
+type PendingTransfer struct {
+	ID        int64
+	FromID    string
+	ToID      string
+	Amount    money.Money
+	Fee       money.Money
+	Comment   string
+	Status    string
+	CreatedAt time.Time
+	ClosedAt  time.Time
+}
+
+// Limit — ограничение операций игрока (user_limits). Scope — "all", "role:<роль>"
+// или "user:<ID>", Name — вид ограничения.
+type Limit struct {
+	Scope     string
+	Name      string
+	Value     money.Money
+	UpdatedBy string
+	UpdatedAt time.Time
+}
+
+// Setting — настройка банка, которую администрация меняет из бота.
+type Setting struct {
+	Key       string
+	Value     string
+	UpdatedBy string
+	UpdatedAt time.Time
+}
+
+// Entry — одна проводка. Amount > 0 — зачисление (кредит) на счёт,
+// Amount < 0 — списание (дебет). Сумма проводок одной транзакции всегда 0.
+type Entry struct {
+	Account string
+	Amount  money.Money
+	// BalanceAfter — остаток счёта игрока после проводки; у системных счетов nil.
+	BalanceAfter *money.Money
+}
+
+type Transaction struct {
+	ID        int64
+	Kind      string
+	Initiator string
+	Memo      string
+	CreatedAt time.Time
+	Entries   []Entry
+}
+
+type StatementLine struct {
+	TxID      int64
+	Kind      string
+	Memo      string
+	Amount    money.Money
+	CreatedAt time.Time
+}
+
+type AccountBalance struct {
+	UserID string
+	Nick   string
+	Amount money.Money
+}
+
+// HistoryItem — одна операция в истории игрока.
+type HistoryItem struct {
+	ID           int64        `json:"id"`
+	TxID         int64        `json:"tx_id"`
+	Kind         string       `json:"type"`
+	Counterparty string       `json:"counterparty"`
+	Amount       money.Money  `json:"amount"`
+	BalanceAfter *money.Money `json:"balance_after"`
+	Memo         string       `json:"memo"`
+	CreatedAt    time.Time    `json:"created_at"`
+
+	// CounterpartyNick — ник контрагента-игрока; пусто для системных счетов.
+	CounterpartyNick string `json:"-"`
+}
+
+// HistoryFilter — фильтр и курсор истории. Нулевые поля не ограничивают
+// выборку; Before — ID проводки, с которой начинается следующая страница.
+type HistoryFilter struct {
+	Kind   string
+	From   time.Time
+	To     time.Time
+	Before int64
+	Limit  int
+}
+
+type MoneyRequest struct {
+	ID     int64       `json:"id"`
+	UserID string      `json:"-"`
+	Kind   string      `json:"kind"`
+	Amount money.Money `json:"amount"`
+	// Fee — комиссия, рассчитанная при создании заявки; при одобрении вывода
+	// списывается сверх Amount.
+	Fee       money.Money `json:"fee"`
+	Status    string      `json:"status"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Состояния обращений игроков (жалоб).
+const (
+	TicketOpen       = "open"
+	TicketInProgress = "in_progress"
+	TicketResolved   = "resolved"
+	TicketRejected   = "rejected"
+)
+
+// Complaint — обращение игрока. Text — первое сообщение, ответы и
+// дополнения хранятся в ComplaintMessage.
+type Complaint struct {
+	ID     int64  `json:"id"`
+	UserID string `json:"-"`
+	Nick   string `json:"-"`
+	Text   string `json:"text"`
+	Status string `json:"status"`
+	// AssignedTo — ID администратора, который занимается обращением.
+	AssignedTo string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Closed — обращение решено или отклонено.
+func (c Complaint) Closed() bool {
+	return c.Status == TicketResolved || c.Status == TicketRejected
+}
+
+// ComplaintMessage — сообщение в переписке по обращению.
+type ComplaintMessage struct {
+	ID          int64     `json:"id"`
+	ComplaintID int64     `json:"-"`
+	AuthorID    string    `json:"-"`
+	FromAdmin   bool      `json:"from_admin"`
+	Text        string    `json:"text"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// К чему приложен файл.
+const (
+	AttachRequest = "request"
+	AttachTicket  = "ticket"
+)
+
+// Типы приложенных файлов.
+const (
+	FilePhoto    = "photo"
+	FileDocument = "document"
+)
+
+// Attachment — скриншот или файл, приложенный игроком к заявке или
+// обращению. FileID — file_id Telegram, по нему бот пересылает файл.
+type Attachment struct {
+	ID        int64
+	OwnerKind string
+	OwnerID   int64
+	UserID    string
+	FileID    string
+	FileType  string
+	CreatedAt time.Time
+}
+
+// Состояния рассылок.
+const (
+	// BroadcastDraft — рассылка ждёт подтверждения после предпросмотра.
+	BroadcastDraft     = "draft"
+	BroadcastQueued    = "queued"
+	BroadcastRunning   = "running"
+	BroadcastDone      = "done"
+	BroadcastCancelled = "cancelled"
+)
+
+// Состояния доставки рассылки одному игроку.
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+	// DeliveryBlocked — игрок заблокировал бота или удалил аккаунт.
+	DeliveryBlocked = "blocked"
+)
+
+// Broadcast — рассылка администрации. Счётчики ведутся по мере доставки,
+// поэтому после перезапуска бот продолжает с того же места.
+type Broadcast struct {
+	ID   int64
+	Text string
+	// FileID и FileType — фото или файл рассылки; Text тогда — подпись.
+	FileID   string
+	FileType string
+	// Entities и Buttons — форматирование текста и inline-кнопки в JSON
+	// Bot API; хранилище их не разбирает.
+	Entities string
+	Buttons  string
+	// Segment — описание получателей для администратора.
+	Segment   string
+	CreatedBy string
+	Status    string
+	Total     int
+	Sent      int
+	Failed    int
+	Blocked   int
+	// ChatID и MessageID — сообщение администратора с ходом рассылки.
+	ChatID     int64
+	MessageID  int
+	CreatedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Pending — сколько сообщений рассылки ещё не доставлено.
+func (b Broadcast) Pending() int {
+	return b.Total - b.Sent - b.Failed - b.Blocked
+}
+
+// Active — рассылка в очереди или в работе.
+func (b Broadcast) Active() bool {
+	return b.Status == BroadcastQueued || b.Status == BroadcastRunning
+}
+
+// Виды записей расписания.
+const (
+	// ScheduleBroadcast — рассылка в заданное время, разовая или повторяющаяся.
+	ScheduleBroadcast = "broadcast"
+	// ScheduleInfo — информационная строка WebApp на заданный срок.
+	ScheduleInfo = "info"
+)
+
+// Schedule — запись расписания: отложенная рассылка или информационная
+// строка.
+type Schedule struct {
+	ID   int64
+	Kind string
+	// Text, FileID, FileType, Entities и Buttons — содержимое, как у Broadcast;
+	// у информационной строки только Text.
+	Text     string
+	FileID   string
+	FileType string
+	Entities string
+	Buttons  string
+	// Filters — фильтры получателей рассылки в виде аргументов /broadcast.
+	Filters string
+	// Priority — приоритет информационной строки; показываются строки с
+	// наибольшим.
+	Priority int
+	// StartsAt — когда отправить рассылку в следующий раз или с какого
+	// момента показывать строку.
+	StartsAt time.Time
+	// EndsAt — после этого момента повторы рассылки и показ строки
+	// прекращаются; нулевое — бессрочно.
+	EndsAt time.Time
+	// Every — период повтора рассылки; 0 — разовая.
+	Every     time.Duration
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// Broadcast — рассылка с содержимым записи расписания.
+func (s Schedule) Broadcast() Broadcast {
+	return Broadcast{Text: s.Text, FileID: s.FileID, FileType: s.FileType, Entities: s.Entities, Buttons: s.Buttons, CreatedBy: s.CreatedBy}
+}
+
+// Delivery — доставка рассылки одному игроку.
+type Delivery struct {
+	BroadcastID int64
+	UserID      string
+	Status      string
+	Attempts    int
+	Error       string
+	UpdatedAt   time.Time
+}
+
+// ComplaintFilter — выборка обращений; нулевые поля не ограничивают её.
+type ComplaintFilter struct {
+	UserID     string
+	AssignedTo string
+	Statuses   []string
+	Limit      int
+}
+
+type Admin struct {
+	ID      string
+	Role    string
+	AddedBy string
+	AddedAt time.Time
+}
+
+// Store — хранилище банка. Всё чтение и запись идут через Tx: fn выполняется
+// в одной транзакции, и если fn вернула ошибку, её изменения откатываются.
+type Store interface {
+	Tx(ctx context.Context, fn func(tx Tx) error) error
+}
+
+// Tx — операции хранилища внутри одной транзакции. Время событий (CreatedAt
+// и т.п.) задаёт вызывающий, хранилище его не подставляет.
+type Tx interface {
+	User(id string) (User, error)
+	// UpsertUser заводит игрока или меняет ник существующего; роль и
+	// остальные поля существующего игрока не меняются.
+	UpsertUser(u User) error
+	Users(includeBanned bool) ([]User, error)
+	// UsersByNick — игроки с ником nick без учёта регистра.
+	UsersByNick(nick string) ([]User, error)
+	SetBanned(id string, banned bool) (bool, error)
+	SetBlockedBot(id string, blocked bool) error
+	// TouchUser запоминает время последней активности игрока at, если
+	// сохранённое время раньше stale или ещё не задано, и снимает отметку
+	// BlockedBot: написавший боту игрок его не блокирует.
+	TouchUser(id string, at, stale time.Time) error
+
+	Balance(id string) (money.Money, error)
+	// LockBalances блокирует строки балансов игроков до конца транзакции,
+	// заводя недостающие с нулём, и возвращает остатки. Блокировки берутся
+	// в порядке возрастания ID, чтобы встречные операции не давали deadlock.
+	LockBalances(ids ...string) (map[string]money.Money, error)
+	AddBalance(id string, delta money.Money) error
+	Balances() ([]AccountBalance, error)
+
+	// InsertTransaction сохраняет транзакцию с проводками и заполняет ID.
+	InsertTransaction(t *Transaction) error
+	Statement(account string, limit int) ([]StatementLine, error)
+	// LedgerTotals — сумма проводок по каждому счёту игрока.
+	LedgerTotals() (map[string]money.Money, error)
+	// AccountTotal — сумма всех проводок по счёту; для системных счетов это
+	// их баланс.
+	AccountTotal(account string) (money.Money, error)
+	// Outflow — сумма списаний со счёта игрока переводами и выводами с момента since.
+	Outflow(uid string, since time.Time) (money.Money, error)
+	History(uid string, f HistoryFilter) ([]HistoryItem, error)
+
+	// Products — облигации рынка; снятые с рынка — только с includeRetired.
+	Products(includeRetired bool) ([]Product, error)
+	Product(id int) (Product, error)
+	// LockProduct блокирует облигацию до конца транзакции, чтобы
+	// параллельные покупки не превысили лимиты выпуска.
+	LockProduct(id int) (Product, error)
+	CreateProduct(p *Product) error
+	// UpdateProduct сохраняет условия и состояние облигации; Issued не меняется.
+	UpdateProduct(p Product) error
+	InsertProductChange(c *ProductChange) error
+	// ProductChanges — журнал изменений облигации, новые записи первыми.
+	ProductChanges(productID, limit int) ([]ProductChange, error)
+	AddIssued(id int, amount money.Money) error
+	// Holdings — сумма открытых вкладов игрока по облигации.
+	Holdings(uid string, productID int) (money.Money, error)
+
+	Bonds(uid string) ([]Bond, error)
+	AllBonds() ([]Bond, error)
+	// LockBond блокирует вклад игрока до конца транзакции.
+	LockBond(id int, uid string) (Bond, error)
+	InsertBond(b *Bond) error
+	DeleteBond(id int) error
+	SetBondLock(id int, canWithdraw bool) (bool, error)
+	// MaturedBonds — вклады с наступившим сроком погашения, которые ещё
+	// нужно обработать: заблокированные или с автопогашением.
+	MaturedBonds(at time.Time) ([]Bond, error)
+	// CouponBonds — открытые вклады с купонной моделью.
+	CouponBonds() ([]Bond, error)
+	// UpdateBondPosition сохраняет тело, начисленные проценты и счётчик
+	// купонов вклада после частичного снятия или пополнения.
+	UpdateBondPosition(b Bond) error
+	// TransferBond передаёт вклад другому игроку.
+	TransferBond(id int, to string) error
+
+	CreateListing(l *Listing) error
+	// Listing читает объявление без блокировки.
+	Listing(id int64) (Listing, error)
+	// LockListing блокирует объявление до конца транзакции.
+	LockListing(id int64) (Listing, error)
+	// OpenListing — открытое объявление по вкладу; ErrNotFound, если его нет.
+	OpenListing(bondID int) (Listing, error)
+	OpenListings() ([]Listing, error)
+	// CloseListing переводит открытое объявление в status; ErrListingClosed,
+	// если оно уже закрыто.
+	CloseListing(id int64, status, buyer string, at time.Time) error
+
+	CreatePendingTransfer(p *PendingTransfer) error
+	// ClosePendingTransfer переводит перевод отправителя uid (любого, если uid
+	// пуст) из состояния from в to; ErrTransferClosed и перевод в его
+	// состоянии, если он уже не в from.
+	ClosePendingTransfer(id int64, uid, from, to string, at time.Time) (PendingTransfer, error)
+
+	// Limits — ограничения с областями из scopes.
+	Limits(scopes ...string) ([]Limit, error)
+	SetLimit(l Limit) error
+	DeleteLimit(scope, name string) (bool, error)
+
+	CreateRequest(r *MoneyRequest) error
+	// CloseRequest переводит заявку из pending в состояние to. Если заявка
+	// уже закрыта, возвращает её фактическое состояние и ErrRequestClosed.
+	// Непустой uid ограничивает поиск заявками этого игрока.
+	CloseRequest(id int64, uid, to, by string, at time.Time) (MoneyRequest, error)
+	SetRequestTx(id, txID int64) error
+	PendingRequests(uid string) ([]MoneyRequest, error)
+	// ExpireRequests закрывает как expired заявки, созданные раньше before.
+	ExpireRequests(before, at time.Time) ([]MoneyRequest, error)
+	// ExpireWithoutEvidence закрывает как expired заявки вида kind, созданные
+	// раньше before, к которым не приложено ни одного файла.
+	ExpireWithoutEvidence(kind string, before, at time.Time) ([]MoneyRequest, error)
+
+	AddAttachment(a *Attachment) error
+	// Attachments — файлы заявки или обращения по порядку.
+	Attachments(ownerKind string, ownerID int64) ([]Attachment, error)
+
+	LastComplaintAt(uid string) (time.Time, error)
+	InsertComplaint(c *Complaint) error
+	Complaint(id int64) (Complaint, error)
+	// LockComplaint блокирует обращение до конца транзакции, чтобы два
+	// администратора не закрыли его одновременно.
+	LockComplaint(id int64) (Complaint, error)
+	// Complaints — обращения по фильтру, новые первыми.
+	Complaints(f ComplaintFilter) ([]Complaint, error)
+	// UpdateComplaint сохраняет состояние, исполнителя и время изменения.
+	UpdateComplaint(c Complaint) error
+	AddComplaintMessage(m *ComplaintMessage) error
+	// ComplaintMessages — переписка по обращению по порядку.
+	ComplaintMessages(id int64) ([]ComplaintMessage, error)
+
+	// Settings — настройки, заданные администрацией; остальные имеют
+	// значения по умолчанию из bank.
+	Settings() ([]Setting, error)
+	SetSetting(s Setting) error
+
+	InfoLine() (string, error)
+	SetInfoLine(text string) error
+
+	Admin(id string) (Admin, error)
+	Admins() ([]Admin, error)
+	SetAdmin(a Admin) error
+	// AddAdminIfMissing добавляет администратора, не трогая существующую запись.
+	AddAdminIfMissing(a Admin) error
+	RemoveAdmin(id string) (bool, error)
+
+	// CreateBroadcast сохраняет рассылку и ожидающую доставку каждому из recipients.
+	CreateBroadcast(b *Broadcast, recipients []string) error
+	Broadcast(id int64) (Broadcast, error)
+	// LockBroadcast блокирует рассылку до конца транзакции, чтобы отмена и
+	// учёт доставок не затирали друг друга.
+	LockBroadcast(id int64) (Broadcast, error)
+	// ActiveBroadcasts — рассылки в очереди и в работе, старые первыми.
+	ActiveBroadcasts() ([]Broadcast, error)
+	UpdateBroadcast(b Broadcast) error
+	// PendingDeliveries — до limit ещё не доставленных сообщений рассылки.
+	PendingDeliveries(id int64, limit int) ([]Delivery, error)
+	UpdateDelivery(d Delivery) error
+
+	CreateSchedule(s *Schedule) error
+	// Schedules — всё расписание по времени начала.
+	Schedules() ([]Schedule, error)
+	// DueSchedules — рассылки с наступившим временем отправки; строки
+	// блокируются до конца транзакции, чтобы рассылка не ушла дважды.
+	DueSchedules(at time.Time) ([]Schedule, error)
+	// InfoLines — информационные строки, которые показываются в момент at,
+	// по убыванию приоритета.
+	InfoLines(at time.Time) ([]Schedule, error)
+	UpdateSchedule(s Schedule) error
+	DeleteSchedule(id int64) (bool, error)
+}